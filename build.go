@@ -20,6 +20,7 @@ func onBuild(cmd *cli.Cmd) {
 			deployer.OptionBuildCacheDir(*buildCacheDir),
 			deployer.OptionSolcAllowedPaths(*solAllowedPaths),
 			deployer.OptionEnableCoverage(*coverage),
+			deployer.OptionCoverageStrategy(deployer.CoverageStrategy(*coverStrategy)),
 		)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to init deployer")
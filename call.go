@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/InjectiveLabs/etherman/deployer"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	cli "github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
 	log "github.com/xlab/suplog"
 )
 
@@ -19,10 +24,16 @@ func onCall(cmd *cli.Cmd) {
 	methodName := cmd.StringArg("METHOD", "", "Contract method to transact.")
 	methodArgs := cmd.StringsArg("ARGS", []string{}, "Method transaction arguments. Will be ABI-encoded.")
 	fromAddress := cmd.StringOpt("from", "0x0000000000000000000000000000000000000000", "Estimate transaction using specified from address.")
+	multiCallsFile := cmd.StringOpt("multi", "", "Batch calls listed in a file (or - for stdin), one 'ADDRESS METHOD [ARGS...]' per line.")
 
-	cmd.Spec = "[--bytecode] [--from] ADDRESS METHOD [ARGS...]"
+	cmd.Spec = "[--from] (--multi | [--bytecode] ADDRESS METHOD [ARGS...])"
 
 	cmd.Action = func() {
+		if len(*multiCallsFile) > 0 {
+			onCallMulti(*multiCallsFile, common.HexToAddress(*fromAddress))
+			return
+		}
+
 		d, err := deployer.New(
 			deployer.OptionRPCTimeout(duration(*rpcTimeout, defaultRPCTimeout)),
 			deployer.OptionCallTimeout(duration(*callTimeout, defaultCallTimeout)),
@@ -34,6 +45,7 @@ func onCall(cmd *cli.Cmd) {
 			deployer.OptionBuildCacheDir(*buildCacheDir),
 			deployer.OptionSolcAllowedPaths(*solAllowedPaths),
 			deployer.OptionEnableCoverage(*coverage),
+			deployer.OptionCoverageStrategy(deployer.CoverageStrategy(*coverStrategy)),
 		)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to init deployer")
@@ -47,7 +59,9 @@ func onCall(cmd *cli.Cmd) {
 		}
 		if *coverage {
 			callOpts.CoverageAgent = deployer.NewCoverageDataCollector(deployer.CoverageModeDefault)
-
+		}
+		if *coverage && deployer.CoverageStrategy(*coverStrategy) == deployer.CoverageStrategyInstrument {
+			// instrumented calls are sent as transactions to collect coverage events
 			client, err := d.Backend()
 			if err != nil {
 				log.Fatalln(err)
@@ -61,14 +75,7 @@ func onCall(cmd *cli.Cmd) {
 				log.WithError(err).Fatalln("failed get valid chain ID")
 			}
 
-			fromAddress, signerFn, err := initEthereumAccountsManager(
-				chainID.Uint64(),
-				keystoreDir,
-				from,
-				fromPassphrase,
-				fromPrivKey,
-				useLedger,
-			)
+			fromAddress, signerFn, err := initEthereumAccountsManager(chainID.Uint64(), signerOpts)
 			if err != nil {
 				log.WithError(err).Fatalln("failed init SignerFn")
 			}
@@ -105,5 +112,114 @@ func onCall(cmd *cli.Cmd) {
 
 		v, _ := json.MarshalIndent(output, "", "\t")
 		fmt.Println(string(v))
+
+		if callOpts.CoverageAgent != nil {
+			reportCoverage(callOpts.CoverageAgent, *contractName)
+		}
+	}
+}
+
+type callMultiResult struct {
+	Contract string        `json:"contract"`
+	Method   string        `json:"method"`
+	Output   []interface{} `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func onCallMulti(callsFile string, fromAddress common.Address) {
+	d, err := deployer.New(
+		deployer.OptionRPCTimeout(duration(*rpcTimeout, defaultRPCTimeout)),
+		deployer.OptionCallTimeout(duration(*callTimeout, defaultCallTimeout)),
+
+		// only options applicable to call
+		deployer.OptionEVMRPCEndpoint(*evmEndpoint),
+		deployer.OptionNoCache(*noCache),
+		deployer.OptionBuildCacheDir(*buildCacheDir),
+		deployer.OptionSolcAllowedPaths(*solAllowedPaths),
+	)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to init deployer")
+	}
+
+	var in io.Reader = os.Stdin
+	if callsFile != "-" {
+		f, err := os.Open(callsFile)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to open calls file")
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	calls, err := readMultiCalls(in)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to read calls")
+	}
+
+	results, err := d.CallBatch(
+		context.Background(),
+		deployer.ContractCallBatchOpts{
+			From:         fromAddress,
+			SolSource:    *solSource,
+			ContractName: *contractName,
+		},
+		calls,
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	out := make([]callMultiResult, len(results))
+	for idx, res := range results {
+		out[idx] = callMultiResult{
+			Contract: res.Contract.Hex(),
+			Method:   res.Method,
+			Output:   res.Output,
+		}
+
+		if res.Err != nil {
+			out[idx].Error = res.Err.Error()
+		}
+	}
+
+	v, _ := json.MarshalIndent(out, "", "\t")
+	fmt.Println(string(v))
+}
+
+// readMultiCalls parses call specs, one 'ADDRESS METHOD [ARGS...]' per line.
+// Empty lines and lines starting with # are skipped.
+func readMultiCalls(in io.Reader) ([]deployer.ContractBatchCall, error) {
+	var calls []deployer.ContractBatchCall
+
+	s := bufio.NewScanner(in)
+	lineNum := 0
+	for s.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			err := errors.Errorf("line %d: expected ADDRESS METHOD [ARGS...]", lineNum)
+			return nil, err
+		} else if !common.IsHexAddress(fields[0]) {
+			err := errors.Errorf("line %d: wrong contract address: %s", lineNum, fields[0])
+			return nil, err
+		}
+
+		methodArgs := fields[2:]
+		calls = append(calls, deployer.ContractBatchCall{
+			Contract:   common.HexToAddress(fields[0]),
+			MethodName: fields[1],
+			MethodInputMapper: func(args abi.Arguments) ([]interface{}, error) {
+				return mapStringArgs(args, methodArgs)
+			},
+		})
 	}
+
+	return calls, s.Err()
 }
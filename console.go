@@ -0,0 +1,647 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	cli "github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+	"golang.org/x/term"
+
+	"github.com/InjectiveLabs/etherman/deployer"
+	"github.com/InjectiveLabs/etherman/keystore"
+	"github.com/InjectiveLabs/etherman/sol"
+)
+
+func onConsole(cmd *cli.Cmd) {
+	contractAddress := cmd.StringArg("ADDRESS", "", "Contract address to interact with. Can be switched later using 'at'.")
+
+	cmd.Spec = "[ADDRESS]"
+
+	cmd.Action = func() {
+		d, err := deployer.New(
+			deployer.OptionRPCTimeout(duration(*rpcTimeout, defaultRPCTimeout)),
+			deployer.OptionCallTimeout(duration(*callTimeout, defaultCallTimeout)),
+			deployer.OptionTxTimeout(duration(*txTimeout, defaultTxTimeout)),
+
+			// all options applicable to call, tx and logs
+			deployer.OptionEVMRPCEndpoint(*evmEndpoint),
+			deployer.OptionGasPrice(big.NewInt(int64(*gasPrice))),
+			deployer.OptionGasLimit(gasLimitValue(*gasLimit)),
+			deployer.OptionGasEstimateAdjustment(*gasMultiplier, gasBufferValue(*gasBuffer)),
+			deployer.OptionNoCache(*noCache),
+			deployer.OptionBuildCacheDir(*buildCacheDir),
+			deployer.OptionSolcAllowedPaths(*solAllowedPaths),
+			deployer.OptionEnableCoverage(*coverage),
+			deployer.OptionCoverageStrategy(deployer.CoverageStrategy(*coverStrategy)),
+		)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to init deployer")
+		}
+
+		// the contract is built once, later commands will reuse the compiled contract
+		contract, err := d.Build(context.Background(), *solSource, *contractName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		contractABI, err := abi.JSON(bytes.NewReader(contract.ABI))
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse contract ABI")
+		}
+
+		session := &consoleSession{
+			d:           d,
+			contract:    contract,
+			contractABI: contractABI,
+			address:     common.HexToAddress(*contractAddress),
+			out:         os.Stdout,
+		}
+
+		if *coverage {
+			session.coverageAgent = deployer.NewCoverageDataCollector(deployer.CoverageModeDefault)
+		}
+
+		if len(*keystoreDir) > 0 {
+			// the keystore is watched, so keys added during the session can be used with 'from'
+			session.ks = openKeystore(false)
+
+			watchCtx, cancelFn := context.WithCancel(context.Background())
+			defer cancelFn()
+			go session.ks.Watch(watchCtx)
+		}
+
+		// without from address, the sender can be selected from keystore later
+		if hasEthereumKeyDetails() && (session.ks == nil || len(*from) > 0) {
+			if err := session.initSigner(); err != nil {
+				log.WithError(err).Fatalln("failed init SignerFn")
+			}
+		}
+
+		if err := session.run(); err != nil {
+			log.Fatalln(err)
+		}
+
+		if session.coverageAgent != nil {
+			reportCoverage(session.coverageAgent, *contractName)
+		}
+	}
+}
+
+func hasEthereumKeyDetails() bool {
+	return *useLedger ||
+		hasPrivateKey(fromPrivKey, fromPrivKeyFile, fromPrivKeyFD) ||
+		len(*clefEndpoint) > 0 ||
+		len(*signerURL) > 0 ||
+		len(*mnemonic) > 0 ||
+		len(*mnemonicFile) > 0 ||
+		len(*keystoreDir) > 0
+}
+
+type consoleSession struct {
+	d             deployer.Deployer
+	contract      *sol.Contract
+	contractABI   abi.ABI
+	address       common.Address
+	coverageAgent deployer.CoverageDataCollector
+
+	chainID  uint64
+	from     common.Address
+	signerFn bind.SignerFn
+	ks       keystore.EthKeyStore
+
+	term *term.Terminal
+	out  io.Writer
+}
+
+type consoleCommand struct {
+	usage  string
+	desc   string
+	action func(s *consoleSession, args []string) error
+}
+
+var consoleCommands map[string]consoleCommand
+
+func init() {
+	// initialized in init to avoid initialization loop through the help command
+	consoleCommands = map[string]consoleCommand{
+		"help":     {"help", "Show this help.", (*consoleSession).onHelp},
+		"at":       {"at ADDRESS", "Switch the contract address of the session.", (*consoleSession).onAt},
+		"call":     {"call METHOD [ARGS...]", "Call a contract method.", (*consoleSession).onCall},
+		"tx":       {"tx [--value=WEI] METHOD [ARGS...]", "Send a transaction to a contract method, awaits confirmation.", (*consoleSession).onTx},
+		"logs":     {"logs TX_HASH [EVENT_NAME]", "Load logs of a transaction, optionally filtered by event.", (*consoleSession).onLogs},
+		"decode":   {"decode HEX", "Decode calldata or revert data using the contract ABI.", (*consoleSession).onDecode},
+		"methods":  {"methods", "List contract methods.", (*consoleSession).onMethods},
+		"events":   {"events", "List contract events.", (*consoleSession).onEvents},
+		"accounts": {"accounts", "List accounts of the keystore, including keys added during the session.", (*consoleSession).onAccounts},
+		"from":     {"from ADDRESS", "Switch the sender to a keystore account.", (*consoleSession).onFrom},
+		"exit":     {"exit", "Exit the console.", nil},
+	}
+}
+
+// loadChainID requests chain ID once per session.
+func (s *consoleSession) loadChainID() (uint64, error) {
+	if s.chainID > 0 {
+		return s.chainID, nil
+	}
+
+	client, err := s.d.Backend()
+	if err != nil {
+		return 0, err
+	}
+
+	chainCtx, cancelFn := context.WithTimeout(context.Background(), duration(*rpcTimeout, defaultRPCTimeout))
+	defer cancelFn()
+
+	chainID, err := client.ChainID(chainCtx)
+	if err != nil {
+		err = errors.Wrap(err, "failed get valid chain ID")
+		return 0, err
+	}
+
+	s.chainID = chainID.Uint64()
+	return s.chainID, nil
+}
+
+func (s *consoleSession) initSigner() error {
+	chainID, err := s.loadChainID()
+	if err != nil {
+		return err
+	}
+
+	s.from, s.signerFn, err = initEthereumAccountsManager(chainID, signerOpts)
+
+	return err
+}
+
+func (s *consoleSession) run() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// non-interactive mode, commands are piped in
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if !s.exec(scanner.Text()) {
+				return nil
+			}
+		}
+
+		return scanner.Err()
+	}
+
+	s.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, fmt.Sprintf("%s> ", s.contract.Name))
+	s.term.AutoCompleteCallback = s.complete
+
+	fmt.Fprintf(s.out, "Console for %s at %s, type 'help' for commands.\n", s.contract.Name, s.address.Hex())
+
+	for {
+		// raw mode is only enabled during line editing, so commands and logs output normally
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			err = errors.Wrap(err, "failed to set terminal into raw mode")
+			return err
+		}
+
+		line, err := s.term.ReadLine()
+		_ = term.Restore(fd, oldState)
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !s.exec(line) {
+			return nil
+		}
+	}
+}
+
+// exec runs a single console line and reports whether the session should continue.
+func (s *consoleSession) exec(line string) bool {
+	args := splitConsoleArgs(line)
+	if len(args) == 0 {
+		return true
+	}
+
+	name := args[0]
+	if name == "exit" || name == "quit" {
+		return false
+	}
+
+	command, ok := consoleCommands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command: %s, type 'help' for commands\n", name)
+		return true
+	}
+
+	if err := command.action(s, args[1:]); err != nil {
+		fmt.Fprintln(s.out, "error:", err)
+	}
+
+	return true
+}
+
+func (s *consoleSession) onHelp(_ []string) error {
+	names := make([]string, 0, len(consoleCommands))
+	for name := range consoleCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.out, "  %-36s %s\n", consoleCommands[name].usage, consoleCommands[name].desc)
+	}
+
+	return nil
+}
+
+func (s *consoleSession) onAccounts(_ []string) error {
+	if s.ks == nil {
+		return errors.New("no keystore configured, restart the console with --keystore-dir")
+	}
+
+	for _, account := range s.ks.Accounts() {
+		fmt.Fprintln(s.out, account.Hex())
+	}
+
+	return nil
+}
+
+func (s *consoleSession) onFrom(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: from ADDRESS")
+	} else if !common.IsHexAddress(args[0]) {
+		return errors.Errorf("wrong address: %s", args[0])
+	} else if s.ks == nil {
+		return errors.New("no keystore configured, restart the console with --keystore-dir")
+	}
+
+	account := common.HexToAddress(args[0])
+
+	chainID, err := s.loadChainID()
+	if err != nil {
+		return err
+	}
+
+	pass := *fromPassphrase
+	if len(pass) == 0 {
+		if s.term == nil {
+			// stdin is used for commands
+			return errors.New("--from-passphrase must be specified in non-interactive mode")
+		}
+
+		pass, err = ethPassFromStdin()
+		fmt.Fprintln(s.out)
+		if err != nil {
+			return err
+		}
+	}
+
+	signerFn, err := s.ks.SignerFn(chainID, account, pass)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to load key for %s", account.Hex())
+		return err
+	}
+
+	s.from = account
+	s.signerFn = signerFn
+
+	fmt.Fprintf(s.out, "Sending from %s\n", account.Hex())
+	return nil
+}
+
+func (s *consoleSession) onAt(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: at ADDRESS")
+	} else if !common.IsHexAddress(args[0]) {
+		return errors.Errorf("wrong address: %s", args[0])
+	}
+
+	s.address = common.HexToAddress(args[0])
+	fmt.Fprintln(s.out, "using contract at", s.address.Hex())
+
+	return nil
+}
+
+func (s *consoleSession) mapMethodArgs(methodName string, args []string) ([]interface{}, error) {
+	method, ok := s.contractABI.Methods[methodName]
+	if !ok {
+		return nil, errors.Errorf("method not found: %s", methodName)
+	}
+
+	return mapStringArgs(method.Inputs, args)
+}
+
+func (s *consoleSession) onCall(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: call METHOD [ARGS...]")
+	}
+
+	// args are mapped upfront, so mapping errors don't terminate the session
+	mappedArgs, err := s.mapMethodArgs(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+
+	callOpts := deployer.ContractCallOpts{
+		From:          s.from,
+		SolSource:     *solSource,
+		ContractName:  *contractName,
+		Contract:      s.address,
+		CoverageAgent: s.coverageAgent,
+	}
+	callOpts.CoverageCall.SignerFn = s.signerFn
+
+	output, _, err := s.d.Call(
+		context.Background(),
+		callOpts,
+		args[0],
+		func(abi.Arguments) []interface{} {
+			return mappedArgs
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	v, _ := json.MarshalIndent(output, "", "\t")
+	fmt.Fprintln(s.out, string(v))
+
+	return nil
+}
+
+func (s *consoleSession) onTx(args []string) error {
+	if s.signerFn == nil {
+		return errors.New("no signer configured, use 'from' or restart the console with Ethereum key options")
+	}
+
+	value := new(big.Int)
+	if len(args) > 0 && strings.HasPrefix(args[0], "--value=") {
+		var ok bool
+		if value, ok = value.SetString(strings.TrimPrefix(args[0], "--value="), 10); !ok {
+			return errors.Errorf("failed to parse value: %s", args[0])
+		}
+
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		return errors.New("usage: tx [--value=WEI] METHOD [ARGS...]")
+	}
+
+	mappedArgs, err := s.mapMethodArgs(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+
+	txOpts := deployer.ContractTxOpts{
+		From:          s.from,
+		SignerFn:      s.signerFn,
+		SolSource:     *solSource,
+		ContractName:  *contractName,
+		Contract:      s.address,
+		Await:         true,
+		Value:         value,
+		CoverageAgent: s.coverageAgent,
+	}
+
+	txHash, _, err := s.d.Tx(
+		context.Background(),
+		txOpts,
+		args[0],
+		func(abi.Arguments) []interface{} {
+			return mappedArgs
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(s.out, txHash.Hex())
+
+	return nil
+}
+
+func (s *consoleSession) onLogs(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("usage: logs TX_HASH [EVENT_NAME]")
+	}
+
+	var eventName string
+	if len(args) == 2 {
+		eventName = args[1]
+	}
+
+	logsOpts := deployer.ContractLogsOpts{
+		From:          s.from,
+		SolSource:     *solSource,
+		ContractName:  *contractName,
+		Contract:      s.address,
+		CoverageAgent: s.coverageAgent,
+	}
+
+	events, err := s.d.Logs(
+		context.Background(),
+		logsOpts,
+		common.HexToHash(args[0]),
+		eventName,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	v, _ := json.MarshalIndent(events, "", "\t")
+	fmt.Fprintln(s.out, string(v))
+
+	return nil
+}
+
+func (s *consoleSession) onDecode(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: decode HEX")
+	}
+
+	data := common.FromHex(args[0])
+
+	decoded, err := deployer.DecodeCalldata(&s.contractABI, data)
+	if err == nil {
+		v, _ := json.MarshalIndent(decoded, "", "\t")
+		fmt.Fprintln(s.out, string(v))
+		return nil
+	} else if err != deployer.ErrUnknownSelector && len(data) >= 4 {
+		return err
+	}
+
+	// not a method calldata, try as revert data
+	fmt.Fprintln(s.out, deployer.DecodeRevertData(&s.contractABI, data))
+
+	return nil
+}
+
+func (s *consoleSession) onMethods(_ []string) error {
+	for _, name := range s.methodNames() {
+		method := s.contractABI.Methods[name]
+		fmt.Fprintf(s.out, "  %s %s\n", method.Sig, method.StateMutability)
+	}
+
+	return nil
+}
+
+func (s *consoleSession) onEvents(_ []string) error {
+	for _, name := range s.eventNames() {
+		fmt.Fprintf(s.out, "  %s\n", s.contractABI.Events[name].Sig)
+	}
+
+	return nil
+}
+
+func (s *consoleSession) methodNames() []string {
+	names := make([]string, 0, len(s.contractABI.Methods))
+	for name := range s.contractABI.Methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *consoleSession) eventNames() []string {
+	names := make([]string, 0, len(s.contractABI.Events))
+	for name := range s.contractABI.Events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// complete implements tab-completion of command, method and event names.
+func (s *consoleSession) complete(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	head := line[:pos]
+	words := strings.Fields(head)
+	if len(words) == 0 || strings.HasSuffix(head, " ") {
+		words = append(words, "")
+	}
+
+	// position of the completed word, not counting flags
+	wordIdx := 0
+	for _, word := range words[:len(words)-1] {
+		if !strings.HasPrefix(word, "--") {
+			wordIdx++
+		}
+	}
+
+	var candidates []string
+	switch {
+	case wordIdx == 0:
+		for name := range consoleCommands {
+			candidates = append(candidates, name)
+		}
+	case wordIdx == 1 && (words[0] == "call" || words[0] == "tx"):
+		candidates = s.methodNames()
+	case wordIdx == 2 && words[0] == "logs":
+		candidates = s.eventNames()
+	case wordIdx == 1 && words[0] == "from" && s.ks != nil:
+		for _, account := range s.ks.Accounts() {
+			candidates = append(candidates, account.Hex())
+		}
+	}
+
+	current := words[len(words)-1]
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return line, pos, true
+	case 1:
+		completed := head[:len(head)-len(current)] + matches[0] + " "
+		return completed + line[pos:], len(completed), true
+	default:
+		if s.term != nil {
+			fmt.Fprintln(s.term, strings.Join(matches, "  "))
+		}
+
+		prefix := commonPrefix(matches)
+		completed := head[:len(head)-len(current)] + prefix
+		return completed + line[pos:], len(completed), true
+	}
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+// splitConsoleArgs splits the line by whitespace, keeping double-quoted parts together.
+// A backslash escapes the next character, e.g. a quote within quotes or a space outside of them.
+func splitConsoleArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	var inQuotes, escaped, hasArg bool
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			hasArg = true
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case !inQuotes && (r == ' ' || r == '\t'):
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+
+	if escaped {
+		// trailing backslash is kept as is
+		current.WriteRune('\\')
+	}
+
+	if hasArg {
+		args = append(args, current.String())
+	}
+
+	return args
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/etherman/keystore"
+)
+
+func TestSplitConsoleArgs(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"call getCounter", []string{"call", "getCounter"}},
+		{"  tx\taddValue   10 ", []string{"tx", "addValue", "10"}},
+		{`call setName "Alice Smith"`, []string{"call", "setName", "Alice Smith"}},
+		{`call setName ""`, []string{"call", "setName", ""}},
+		{`call setName pre"fix suf"fix`, []string{"call", "setName", "prefix suffix"}},
+		{`call setName "Alice \"A\" Smith"`, []string{"call", "setName", `Alice "A" Smith`}},
+		{`call setName Alice\ Smith`, []string{"call", "setName", "Alice Smith"}},
+		{`call setPath C:\\dir`, []string{"call", "setPath", `C:\dir`}},
+		{`call setName \"`, []string{"call", "setName", `"`}},
+		{`call setName trailing\`, []string{"call", "setName", `trailing\`}},
+		{`call setName "unterminated quote`, []string{"call", "setName", "unterminated quote"}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, splitConsoleArgs(tc.line), tc.line)
+	}
+}
+
+func TestConsoleComplete(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(`[
+		{"name": "getCounter", "type": "function", "stateMutability": "view", "inputs": [], "outputs": [{"name": "", "type": "uint256"}]},
+		{"name": "getOwner", "type": "function", "stateMutability": "view", "inputs": [], "outputs": [{"name": "", "type": "address"}]},
+		{"name": "addValue", "type": "function", "stateMutability": "nonpayable", "inputs": [{"name": "value", "type": "uint256"}], "outputs": []},
+		{"name": "Increased", "type": "event", "inputs": [{"name": "value", "type": "uint256", "indexed": false}]}
+	]`))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	ks, err := keystore.New(dir)
+	require.NoError(t, err)
+
+	account, err := ks.NewKey(dir, "pass", keystore.LightScryptParams)
+	require.NoError(t, err)
+
+	s := &consoleSession{
+		contractABI: contractABI,
+		ks:          ks,
+	}
+
+	testCases := []struct {
+		line     string
+		expected string
+	}{
+		// commands
+		{"ca", "call "},
+		{"e", "e"},
+		{"ex", "exit "},
+		{"zz", "zz"},
+		// method names, the common prefix of ambiguous ones
+		{"call get", "call get"},
+		{"call getC", "call getCounter "},
+		{"tx add", "tx addValue "},
+		{"tx --value=1 add", "tx --value=1 addValue "},
+		{"call getCounter ", "call getCounter "},
+		// event names after the tx hash
+		{"logs 0x01 Inc", "logs 0x01 Increased "},
+		// keystore accounts
+		{"from " + account.Hex()[:6], "from " + account.Hex() + " "},
+		{"from 0xzz", "from 0xzz"},
+	}
+
+	for _, tc := range testCases {
+		line, pos, ok := s.complete(tc.line, len(tc.line), '\t')
+		require.True(t, ok, tc.line)
+		assert.Equal(t, tc.expected, line, tc.line)
+		assert.Equal(t, len(tc.expected), pos, tc.line)
+	}
+
+	// the rest of the line after the cursor is kept
+	line, pos, ok := s.complete("call getC 10", len("call getC"), '\t')
+	require.True(t, ok)
+	assert.Equal(t, "call getCounter  10", line)
+	assert.Equal(t, len("call getCounter "), pos)
+
+	_, _, ok = s.complete("call", 4, 'a')
+	assert.False(t, ok, "only tab is handled")
+}
+
+func TestCommonPrefix(t *testing.T) {
+	assert.Equal(t, "get", commonPrefix([]string{"getCounter", "getOwner"}))
+	assert.Equal(t, "", commonPrefix([]string{"call", "tx"}))
+	assert.Equal(t, "events", commonPrefix([]string{"events"}))
+	assert.Equal(t, "exit", commonPrefix([]string{"exit", "exit2"}))
+}
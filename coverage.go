@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+	exec "golang.org/x/sys/execabs"
+
+	"github.com/InjectiveLabs/etherman/deployer"
+)
+
+// reportCoverage prints the summary into stderr, so command output stays parseable,
+// writes LCOV data and the HTML report into --cover-html directory if requested.
+// Exits with non-zero code if coverage thresholds are not met.
+func reportCoverage(agent deployer.CoverageDataCollector, contractName string) {
+	if changed, ok := coverageChangedLines(); ok {
+		agent.SetChangedLines(changed)
+	}
+
+	if err := agent.ReportTextSummary(os.Stderr, contractName); err != nil {
+		log.WithError(err).Warningln("failed to report coverage summary")
+	}
+
+	if err := agent.ReportDiffCoverage(os.Stderr, contractName); err != nil {
+		log.WithError(err).Warningln("failed to report coverage of changed lines")
+	}
+
+	if *gasProfile {
+		fmt.Fprintln(os.Stderr)
+		if err := agent.ReportGasProfile(os.Stderr, *gasProfileTop, contractName); err != nil {
+			log.WithError(err).Warningln("failed to report gas profile")
+		}
+	}
+
+	if len(*coverLCOV) > 0 {
+		f, err := os.Create(*coverLCOV)
+		if err != nil {
+			log.WithError(err).Errorln("failed to create LCOV file")
+		} else {
+			if err := agent.ReportLCOV(f, contractName); err != nil {
+				log.WithError(err).Warningln("failed to report coverage in LCOV format")
+			}
+
+			_ = f.Close()
+		}
+	}
+
+	if len(*coverHTML) > 0 {
+		if err := agent.ReportHTMLDir(*coverHTML, contractName); err != nil {
+			log.WithError(err).Warningln("failed to report coverage in HTML")
+		}
+	}
+
+	thresholds, ok := coverageThresholds()
+	if !ok {
+		return
+	}
+
+	shortfalls, err := agent.CheckThresholds(thresholds, contractName)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to check coverage thresholds")
+	}
+
+	exitOnShortfalls(shortfalls)
+}
+
+// enableGasProfile turns on coverage with the trace strategy for --gas-profile, gas is attributed
+// to statements using source maps, the instrumented code would distort it.
+func enableGasProfile() {
+	if !*gasProfile {
+		return
+	}
+
+	if *coverage && *coverStrategy != string(deployer.CoverageStrategyTrace) {
+		log.Warningf("--gas-profile uses the %s coverage strategy instead of %s", deployer.CoverageStrategyTrace, *coverStrategy)
+	}
+
+	*coverage = true
+	*coverStrategy = string(deployer.CoverageStrategyTrace)
+}
+
+// coverageChangedLines reads changed lines from --cover-diff or runs git diff against --cover-diff-base.
+func coverageChangedLines() (deployer.ChangedLines, bool) {
+	if len(*coverDiffBase) == 0 && len(*coverDiff) == 0 {
+		return nil, false
+	}
+
+	changed, err := readChangedLines(*coverDiffBase, *coverDiff)
+	if err != nil {
+		log.WithError(err).Warningln("failed to get changed lines")
+		return nil, false
+	}
+
+	return changed, true
+}
+
+// readChangedLines runs git diff against the base ref, if set, otherwise parses the diff file,
+// where '-' stands for stdin.
+func readChangedLines(base, diffPath string) (deployer.ChangedLines, error) {
+	if len(base) > 0 {
+		return gitDiffChangedLines(base)
+	}
+
+	var diff io.Reader = os.Stdin
+	if diffPath != "-" {
+		f, err := os.Open(diffPath)
+		if err != nil {
+			err = errors.Wrap(err, "failed to open diff file")
+			return nil, err
+		}
+		defer f.Close()
+
+		diff = f
+	}
+
+	changed, err := deployer.ParseUnifiedDiff(diff, "")
+	if err != nil {
+		err = errors.Wrap(err, "failed to parse diff")
+		return nil, err
+	}
+
+	return changed, nil
+}
+
+// gitDiffChangedLines diffs .sol files of the working tree against the base ref,
+// paths are resolved from the repository root.
+func gitDiffChangedLines(base string) (deployer.ChangedLines, error) {
+	root, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		err = errors.Wrap(err, "failed to find git repository root")
+		return nil, err
+	}
+
+	diff, err := exec.Command("git", "diff", "--no-color", "--no-ext-diff", "--unified=0", base, "--", "*.sol").Output()
+	if err != nil {
+		err = errors.Wrapf(err, "failed to diff against %s", base)
+		return nil, err
+	}
+
+	return deployer.ParseUnifiedDiff(bytes.NewReader(diff), strings.TrimSpace(string(root)))
+}
+
+// coverageThresholds combines --cover-min with thresholds from --cover-thresholds config,
+// nothing is checked if neither is set.
+func coverageThresholds() (*deployer.CoverageThresholds, bool) {
+	thresholds := new(deployer.CoverageThresholds)
+	if len(*coverThresholds) > 0 {
+		loaded, err := deployer.LoadCoverageThresholds(*coverThresholds)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to load coverage thresholds")
+		}
+
+		thresholds = loaded
+	}
+
+	if *coverMin > 0 {
+		thresholds.Total = *coverMin
+	}
+
+	ok := thresholds.Total > 0 || len(thresholds.Files) > 0 || len(thresholds.Contracts) > 0
+	return thresholds, ok
+}
+
+func exitOnShortfalls(shortfalls []deployer.CoverageShortfall) {
+	if len(shortfalls) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "coverage is below thresholds:")
+	for _, shortfall := range shortfalls {
+		fmt.Fprintln(os.Stderr, "  "+shortfall.String())
+	}
+
+	os.Exit(1)
+}
+
+func onCoverCheck(cmd *cli.Cmd) {
+	tracefiles := cmd.StringsArg("LCOV", []string{}, "LCOV tracefiles to merge, e.g. written with --cover-lcov by separate runs.")
+
+	cmd.Spec = "LCOV..."
+
+	cmd.Action = func() {
+		thresholds, ok := coverageThresholds()
+		if !ok {
+			log.Fatalln("no coverage thresholds set, use --cover-min or --cover-thresholds")
+		}
+
+		if len(thresholds.Contracts) > 0 {
+			log.Warningln("contract thresholds are not checked for LCOV tracefiles")
+		}
+
+		readers, closeAll := openTracefiles(*tracefiles)
+		defer closeAll()
+
+		shortfalls, err := deployer.CheckLCOVThresholds(thresholds, readers...)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to check coverage thresholds")
+		}
+
+		exitOnShortfalls(shortfalls)
+	}
+}
+
+func onCoverage(cmd *cli.Cmd) {
+	cmd.Command("diff", "Reports coverage of changed lines of .sol sources from merged LCOV tracefiles.", onCoverageDiff)
+}
+
+func onCoverageDiff(cmd *cli.Cmd) {
+	base := cmd.StringOpt("base", "", "Git ref to diff .sol sources of the working tree against.")
+	diffPath := cmd.StringOpt("diff", "", "Path to unified diff of .sol sources, or '-' for stdin.")
+	min := cmd.Float64Opt("min", 0, "Minimum percent of changed executable lines to be covered, fails otherwise.")
+	tracefiles := cmd.StringsArg("LCOV", []string{}, "LCOV tracefiles to merge, e.g. written with --cover-lcov by separate runs.")
+
+	cmd.Spec = "(--base | --diff) [--min] LCOV..."
+
+	cmd.Action = func() {
+		changed, err := readChangedLines(*base, *diffPath)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to get changed lines")
+		}
+
+		readers, closeAll := openTracefiles(*tracefiles)
+		defer closeAll()
+
+		covered, total, err := deployer.ReportLCOVDiffCoverage(os.Stdout, changed, readers...)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to report coverage of changed lines")
+		}
+
+		if *min > 0 && total > 0 {
+			if pct := float64(covered) * 100 / float64(total); pct < *min {
+				fmt.Fprintf(os.Stderr, "coverage of changed lines %.1f%% is below %.1f%%\n", pct, *min)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// openTracefiles opens LCOV tracefiles for reading, exits on failure.
+func openTracefiles(paths []string) (readers []io.Reader, closeAll func()) {
+	files := make([]*os.File, 0, len(paths))
+	closeAll = func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			log.WithError(err).Fatalln("failed to open LCOV tracefile")
+		}
+
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+
+	return readers, closeAll
+}
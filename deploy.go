@@ -15,9 +15,10 @@ import (
 func onDeploy(cmd *cli.Cmd) {
 	bytecodeOnly := cmd.BoolOpt("bytecode", false, "Produce hex-encoded contract bytecode only. Do not interact with RPC.")
 	await := cmd.BoolOpt("await", true, "Await transaction confirmation from the RPC.")
+	dryRun := cmd.BoolOpt("dry-run", false, "Simulate deployment at pending state and print the result with predicted contract address. Nothing is signed or sent.")
 	contractArgs := cmd.StringsArg("ARGS", []string{}, "Contract constructor's arguments. Will be ABI-encoded.")
 
-	cmd.Spec = "[--bytecode | --await] [ARGS...]"
+	cmd.Spec = "[--bytecode | --await | --dry-run] [ARGS...]"
 
 	cmd.Action = func() {
 		d, err := deployer.New(
@@ -28,16 +29,51 @@ func onDeploy(cmd *cli.Cmd) {
 			// only options applicable to tx
 			deployer.OptionEVMRPCEndpoint(*evmEndpoint),
 			deployer.OptionGasPrice(big.NewInt(int64(*gasPrice))),
-			deployer.OptionGasLimit(uint64(*gasLimit)),
+			deployer.OptionGasLimit(gasLimitValue(*gasLimit)),
+			deployer.OptionGasEstimateAdjustment(*gasMultiplier, gasBufferValue(*gasBuffer)),
 			deployer.OptionNoCache(*noCache),
 			deployer.OptionBuildCacheDir(*buildCacheDir),
 			deployer.OptionSolcAllowedPaths(*solAllowedPaths),
 			deployer.OptionEnableCoverage(*coverage),
+			deployer.OptionCoverageStrategy(deployer.CoverageStrategy(*coverStrategy)),
 		)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to init deployer")
 		}
 
+		constructorInputMapper := func(args abi.Arguments) []interface{} {
+			mappedArgs, err := mapStringArgs(args, *contractArgs)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to map constructor args")
+				return nil
+			}
+
+			return mappedArgs
+		}
+
+		if *dryRun {
+			fromAddress, err := resolveFromAddress(signerOpts)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to get from address")
+			}
+
+			result, err := d.SimulateDeploy(
+				context.Background(),
+				deployer.ContractDeployOpts{
+					From:         fromAddress,
+					SolSource:    *solSource,
+					ContractName: *contractName,
+				},
+				constructorInputMapper,
+			)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			printSimulationResult(result)
+			return
+		}
+
 		client, err := d.Backend()
 		if err != nil {
 			log.Fatalln(err)
@@ -51,14 +87,7 @@ func onDeploy(cmd *cli.Cmd) {
 			log.WithError(err).Fatalln("failed get valid chain ID")
 		}
 
-		fromAddress, signerFn, err := initEthereumAccountsManager(
-			chainID.Uint64(),
-			keystoreDir,
-			from,
-			fromPassphrase,
-			fromPrivKey,
-			useLedger,
-		)
+		fromAddress, signerFn, err := initEthereumAccountsManager(chainID.Uint64(), signerOpts)
 		if err != nil {
 			log.WithError(err).Fatalln("failed init SignerFn")
 		}
@@ -80,15 +109,7 @@ func onDeploy(cmd *cli.Cmd) {
 		txHash, contract, err := d.Deploy(
 			context.Background(),
 			deployOpts,
-			func(args abi.Arguments) []interface{} {
-				mappedArgs, err := mapStringArgs(args, *contractArgs)
-				if err != nil {
-					log.WithError(err).Fatalln("failed to map constructor args")
-					return nil
-				}
-
-				return mappedArgs
-			},
+			constructorInputMapper,
 		)
 		if err != nil {
 			log.Fatalln(err)
@@ -104,5 +125,9 @@ func onDeploy(cmd *cli.Cmd) {
 		}
 
 		fmt.Println(contract.Address.Hex())
+
+		if deployOpts.CoverageAgent != nil {
+			reportCoverage(deployOpts.CoverageAgent, *contractName)
+		}
 	}
 }
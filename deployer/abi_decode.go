@@ -0,0 +1,93 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/pkg/errors"
+)
+
+var ErrUnknownSelector = errors.New("selector not found in ABI")
+
+type DecodedCalldata struct {
+	Method    string                 `json:"method"`
+	Signature string                 `json:"signature"`
+	Args      map[string]interface{} `json:"args"`
+}
+
+// DecodeCalldata matches the 4-byte selector of data against methods in the contract ABI
+// and unpacks the arguments.
+func DecodeCalldata(contractABI *abi.ABI, data []byte) (*DecodedCalldata, error) {
+	if len(data) < 4 {
+		return nil, errors.New("calldata is shorter than a selector")
+	}
+
+	method, err := contractABI.MethodById(data[:4])
+	if err != nil {
+		return nil, ErrUnknownSelector
+	}
+
+	args, err := unpackArgumentsIntoMap(method.Inputs, data[4:])
+	if err != nil {
+		err = errors.Wrapf(err, "failed to unpack arguments of %s", method.Sig)
+		return nil, err
+	}
+
+	decoded := &DecodedCalldata{
+		Method:    method.RawName,
+		Signature: method.Sig,
+		Args:      args,
+	}
+
+	return decoded, nil
+}
+
+// DecodeReturnData unpacks output of the method call into a map of named values.
+func DecodeReturnData(contractABI *abi.ABI, methodName string, data []byte) (map[string]interface{}, error) {
+	method, ok := contractABI.Methods[methodName]
+	if !ok {
+		return nil, errors.Errorf("method not found: %s", methodName)
+	}
+
+	out, err := unpackArgumentsIntoMap(method.Outputs, data)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to unpack output of %s", method.Sig)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// unpackArgumentsIntoMap is like abi.Arguments.UnpackIntoMap, but keeps unnamed
+// arguments by using their position as a key.
+func unpackArgumentsIntoMap(args abi.Arguments, data []byte) (map[string]interface{}, error) {
+	values, err := args.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+
+	names := ArgumentNames(args)
+
+	out := make(map[string]interface{}, len(values))
+	for idx := range values {
+		out[names[idx]] = values[idx]
+	}
+
+	return out, nil
+}
+
+// ArgumentNames returns keys of non-indexed arguments in ABI order, as used in
+// decoded maps of values. Unnamed arguments are keyed by their position.
+func ArgumentNames(args abi.Arguments) []string {
+	nonIndexed := args.NonIndexed()
+
+	names := make([]string, len(nonIndexed))
+	for idx, arg := range nonIndexed {
+		names[idx] = arg.Name
+		if len(names[idx]) == 0 {
+			names[idx] = fmt.Sprintf("arg%d", idx)
+		}
+	}
+
+	return names
+}
@@ -21,20 +21,29 @@ var ErrNoCache = errors.New("no cached version")
 
 type BuildCache interface {
 	StoreContract(absSolPath string, contract *sol.Contract) error
-	LoadContract(absSolPath, contractName string, coverage bool) (contract *sol.Contract, err error)
+	LoadContract(absSolPath, contractName string, coverage CoverageStrategy) (contract *sol.Contract, err error)
+	Entries() ([]*BuildCacheEntry, error)
 	Clear() error
 }
 
 type BuildCacheEntry struct {
-	Timestamp       time.Time       `json:"timestamp"`
-	CodeHash        string          `json:"codeHash"`
-	AllPaths        []string        `json:"allPaths"`
-	ContractName    string          `json:"contractName"`
-	CompilerVersion string          `json:"compilerVersion"`
-	Coverage        bool            `json:"coverage"`
-	Statements      [][]int         `json:"statements"`
-	ABI             json.RawMessage `json:"abi"`
-	Bin             string          `json:"bin"`
+	Timestamp        time.Time         `json:"timestamp"`
+	CodeHash         string            `json:"codeHash"`
+	AllPaths         []string          `json:"allPaths"`
+	FileIndex        int               `json:"fileIndex"`
+	ContractName     string            `json:"contractName"`
+	CompilerVersion  string            `json:"compilerVersion"`
+	Coverage         bool              `json:"coverage"`
+	TraceCoverage    bool              `json:"traceCoverage,omitempty"`
+	Statements       [][]int           `json:"statements"`
+	Branches         []sol.Branch      `json:"branches,omitempty"`
+	Functions        []sol.Function    `json:"functions,omitempty"`
+	CoverageEventIDs map[string]uint64 `json:"coverageEventIDs,omitempty"`
+	ABI              json.RawMessage   `json:"abi"`
+	Bin              string            `json:"bin"`
+	BinRuntime       string            `json:"binRuntime,omitempty"`
+	SrcMap           string            `json:"srcMap,omitempty"`
+	SrcMapRuntime    string            `json:"srcMapRuntime,omitempty"`
 }
 
 type buildCache struct {
@@ -62,23 +71,35 @@ func (b *buildCache) StoreContract(absSolPath string, contract *sol.Contract) er
 	}
 
 	entry := &BuildCacheEntry{
-		Timestamp:       time.Now().UTC(),
-		CodeHash:        hash,
-		AllPaths:        contract.AllPaths,
-		ContractName:    contract.Name,
-		CompilerVersion: contract.CompilerVersion,
-		Coverage:        contract.Coverage,
-		Statements:      contract.Statements,
-		ABI:             json.RawMessage(contract.ABI),
-		Bin:             contract.Bin,
+		Timestamp:        time.Now().UTC(),
+		CodeHash:         hash,
+		AllPaths:         contract.AllPaths,
+		FileIndex:        contract.FileIndex,
+		ContractName:     contract.Name,
+		CompilerVersion:  contract.CompilerVersion,
+		Coverage:         contract.Coverage,
+		TraceCoverage:    contract.TraceCoverage,
+		Statements:       contract.Statements,
+		Branches:         contract.Branches,
+		Functions:        contract.Functions,
+		CoverageEventIDs: contract.CoverageEventIDs,
+		ABI:              json.RawMessage(contract.ABI),
+		Bin:              contract.Bin,
+		BinRuntime:       contract.BinRuntime,
+		SrcMap:           contract.SrcMap,
+		SrcMapRuntime:    contract.SrcMapRuntime,
 	}
 
-	entryContents, _ := json.MarshalIndent(entry, "", "\t")
-	entryFileName := fmt.Sprintf("sol_%s_%s.json", strings.ToLower(contract.Name), hash)
-	if contract.Coverage {
-		entryFileName = fmt.Sprintf("sol_%s_%s_coverage.json", strings.ToLower(contract.Name), hash)
+	var coverage CoverageStrategy
+	if contract.TraceCoverage {
+		coverage = CoverageStrategyTrace
+	} else if contract.Coverage {
+		coverage = CoverageStrategyInstrument
 	}
 
+	entryContents, _ := json.MarshalIndent(entry, "", "\t")
+	entryFileName := cacheEntryFileName(contract.Name, hash, coverage)
+
 	err = ioutil.WriteFile(filepath.Join(b.prefix, entryFileName), entryContents, 0655)
 	if err != nil {
 		err = errors.Wrap(err, "failed write cache entry file")
@@ -88,17 +109,14 @@ func (b *buildCache) StoreContract(absSolPath string, contract *sol.Contract) er
 	return nil
 }
 
-func (b *buildCache) LoadContract(absSolPath, contractName string, coverage bool) (contract *sol.Contract, err error) {
+func (b *buildCache) LoadContract(absSolPath, contractName string, coverage CoverageStrategy) (contract *sol.Contract, err error) {
 	hash, err := sha3file(absSolPath)
 	if err != nil {
 		err = errors.Wrap(err, "failed to hash source")
 		return nil, err
 	}
 
-	entryFileName := fmt.Sprintf("sol_%s_%s.json", strings.ToLower(contractName), hash)
-	if coverage {
-		entryFileName = fmt.Sprintf("sol_%s_%s_coverage.json", strings.ToLower(contractName), hash)
-	}
+	entryFileName := cacheEntryFileName(contractName, hash, coverage)
 
 	entryContents, err := ioutil.ReadFile(filepath.Join(b.prefix, entryFileName))
 	if err != nil {
@@ -120,19 +138,55 @@ func (b *buildCache) LoadContract(absSolPath, contractName string, coverage bool
 	}
 
 	contract = &sol.Contract{
-		SourcePath:      absSolPath,
-		AllPaths:        entry.AllPaths,
-		Name:            entry.ContractName,
-		CompilerVersion: entry.CompilerVersion,
-		Coverage:        entry.Coverage,
-		Statements:      entry.Statements,
-		ABI:             []byte(entry.ABI),
-		Bin:             entry.Bin,
+		SourcePath:       absSolPath,
+		AllPaths:         entry.AllPaths,
+		FileIndex:        entry.FileIndex,
+		Name:             entry.ContractName,
+		CompilerVersion:  entry.CompilerVersion,
+		Coverage:         entry.Coverage,
+		TraceCoverage:    entry.TraceCoverage,
+		Statements:       entry.Statements,
+		Branches:         entry.Branches,
+		Functions:        entry.Functions,
+		CoverageEventIDs: entry.CoverageEventIDs,
+		ABI:              []byte(entry.ABI),
+		Bin:              entry.Bin,
+		BinRuntime:       entry.BinRuntime,
+		SrcMap:           entry.SrcMap,
+		SrcMapRuntime:    entry.SrcMapRuntime,
 	}
 
 	return contract, nil
 }
 
+// Entries returns all entries found in the cache dir, regardless of source hashes.
+func (b *buildCache) Entries() ([]*BuildCacheEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(b.prefix, "sol_*.json"))
+	if err != nil {
+		err = errors.Wrap(err, "failed to list cache entries")
+		return nil, err
+	}
+
+	entries := make([]*BuildCacheEntry, 0, len(matches))
+	for _, path := range matches {
+		entryContents, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.WithError(err).Warningln("failed to read cache entry", path)
+			continue
+		}
+
+		var entry BuildCacheEntry
+		if err := json.Unmarshal(entryContents, &entry); err != nil {
+			log.WithError(err).Warningln("failed to unmarshal cache entry", path)
+			continue
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
 func (b *buildCache) Clear() error {
 	return filepath.Walk(b.prefix, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -153,6 +207,17 @@ func (b *buildCache) Clear() error {
 	})
 }
 
+func cacheEntryFileName(contractName, hash string, coverage CoverageStrategy) string {
+	switch coverage {
+	case CoverageStrategyInstrument:
+		return fmt.Sprintf("sol_%s_%s_coverage.json", strings.ToLower(contractName), hash)
+	case CoverageStrategyTrace:
+		return fmt.Sprintf("sol_%s_%s_trace_coverage.json", strings.ToLower(contractName), hash)
+	default:
+		return fmt.Sprintf("sol_%s_%s.json", strings.ToLower(contractName), hash)
+	}
+}
+
 func sha3file(path string) (string, error) {
 	contents, err := ioutil.ReadFile(path)
 	if err != nil {
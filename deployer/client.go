@@ -2,7 +2,9 @@ package deployer
 
 import (
 	"context"
+	"math/big"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -39,6 +41,91 @@ func (ec *Client) SendTransactionWithRet(ctx context.Context, tx *types.Transact
 	return txHash, nil
 }
 
+// CallContractBatch executes multiple eth_call requests in a single JSON-RPC batch.
+// Per-call failures are returned in callErrs, while err reports a failure of the batch itself.
+func (ec *Client) CallContractBatch(
+	ctx context.Context,
+	msgs []ethereum.CallMsg,
+	blockNumber *big.Int,
+) (results [][]byte, callErrs []error, err error) {
+	blockArg := "latest"
+	if blockNumber != nil {
+		blockArg = hexutil.EncodeBig(blockNumber)
+	}
+
+	outputs := make([]hexutil.Bytes, len(msgs))
+	batch := make([]rpc.BatchElem, len(msgs))
+	for i, msg := range msgs {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{toCallArg(msg), blockArg},
+			Result: &outputs[i],
+		}
+	}
+
+	if err := ec.rc.BatchCallContext(ctx, batch); err != nil {
+		return nil, nil, err
+	}
+
+	results = make([][]byte, len(msgs))
+	callErrs = make([]error, len(msgs))
+	for i := range batch {
+		results[i] = outputs[i]
+		callErrs[i] = batch[i].Error
+	}
+
+	return results, callErrs, nil
+}
+
+// TraceTransaction replays the transaction using debug_traceTransaction, the tracer config
+// is passed as is and the result is decoded into the provided value.
+func (ec *Client) TraceTransaction(
+	ctx context.Context,
+	txHash common.Hash,
+	config interface{},
+	result interface{},
+) error {
+	return ec.rc.CallContext(ctx, result, "debug_traceTransaction", txHash, config)
+}
+
+// TraceCall executes the call using debug_traceCall at the given block, the tracer config
+// is passed as is and the result is decoded into the provided value.
+func (ec *Client) TraceCall(
+	ctx context.Context,
+	msg ethereum.CallMsg,
+	blockNumber *big.Int,
+	config interface{},
+	result interface{},
+) error {
+	blockArg := "latest"
+	if blockNumber != nil {
+		blockArg = hexutil.EncodeBig(blockNumber)
+	}
+
+	return ec.rc.CallContext(ctx, result, "debug_traceCall", toCallArg(msg), blockArg, config)
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["input"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+
+	return arg
+}
+
 var ErrClientNotAvailable = errors.New("EVM RPC client is not available due to connection issue")
 
 func (d *deployer) Backend() (*Client, error) {
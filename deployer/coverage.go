@@ -1,32 +1,58 @@
 package deployer
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
-	"strings"
+	"sort"
 	"sync"
 
 	"github.com/InjectiveLabs/etherman/sol"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	ctypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
 	"golang.org/x/tools/cover"
 )
 
 type CoverageDataCollector interface {
 	LoadContract(contract *sol.Contract) error
 	AddStatement(contractName string, start, end, file uint64) error
+	AddBranch(contractName string, kind sol.BranchKind, start, end, file uint64) error
+	AddFunction(contractName string, fn sol.Function) error
 	CollectCoverageEvent(contractName string, coverageEventABI abi.Event, log *ctypes.Log) error
-	CollectCoverageRevert(contractName string, err error) error
+	CollectCoverageRevert(contractName string, start, end, file uint64) error
+	CollectStatementHits(contractName string, start, end, file uint64, hits int) error
+	CollectBranchHits(contractName string, start, end, file uint64, arm, hits int) error
+	CollectFunctionHits(contractName string, start, end, file uint64, hits int) error
+	CollectStatementGas(contractName string, start, end, file uint64, gas uint64) error
+	CollectFunctionGas(contractName string, start, end, file uint64, gas uint64) error
 	ReportTextSummary(out io.Writer, filterNames ...string) error
 	ReportTextCoverfile(out io.Writer, filterNames ...string) error
+	ReportLCOV(out io.Writer, filterNames ...string) error
 	ReportHTML(out io.Writer, filterNames ...string) error
+	ReportHTMLDir(dir string, filterNames ...string) error
+	CheckThresholds(thresholds *CoverageThresholds, filterNames ...string) ([]CoverageShortfall, error)
+	SetChangedLines(changed ChangedLines)
+	ReportDiffCoverage(out io.Writer, filterNames ...string) error
+	ReportGasProfile(out io.Writer, limit int, filterNames ...string) error
 }
 
+type CoverageStrategy string
+
+const (
+	// CoverageStrategyInstrument injects coverage markers into the sources, so executed
+	// statements emit events. View and pure methods become transactions.
+	CoverageStrategyInstrument CoverageStrategy = "instrument"
+	// CoverageStrategyTrace compiles sources as is, with source maps, and replays executed
+	// transactions and calls using debug tracing. Requires debug API on the node.
+	CoverageStrategyTrace CoverageStrategy = "trace"
+)
+
 type CoverageMode string
 
 const (
@@ -40,7 +66,11 @@ func NewCoverageDataCollector(mode CoverageMode) CoverageDataCollector {
 		mux:          new(sync.RWMutex),
 		paths:        make(map[string][]string),
 		srcFiles:     make(map[string][]*fileMapping),
+		fileMappings: make(map[string]*fileMapping),
 		statements:   make(map[statementDescriptor]int),
+		branches:     make(map[statementDescriptor]*branchRecord),
+		functions:    make(map[statementDescriptor]*functionRecord),
+		statementGas: make(map[statementDescriptor]uint64),
 		coverageMode: mode,
 	}
 }
@@ -49,10 +79,32 @@ type coverageDataCollector struct {
 	mux          *sync.RWMutex
 	paths        map[string][]string
 	srcFiles     map[string][]*fileMapping
+	fileMappings map[string]*fileMapping
 	statements   map[statementDescriptor]int
+	branches     map[statementDescriptor]*branchRecord
+	functions    map[statementDescriptor]*functionRecord
+	statementGas map[statementDescriptor]uint64
+	changedLines ChangedLines
 	coverageMode CoverageMode
 }
 
+// branchRecord counts hits of both branch arms, see sol.Branch for the arms order.
+type branchRecord struct {
+	Kind sol.BranchKind
+	Hits [2]int
+}
+
+// functionRecord counts entries into a function or modifier, Contract is where it's defined.
+type functionRecord struct {
+	Kind     string
+	Name     string
+	Contract string
+	Hits     int
+
+	// Gas used by the function body itself, collected from traces only
+	Gas uint64
+}
+
 type coverageEvent struct {
 	Start uint64
 	End   uint64
@@ -91,17 +143,16 @@ func (c *coverageDataCollector) LoadContract(contract *sol.Contract) error {
 	c.srcFiles[contract.Name] = make([]*fileMapping, len(contract.AllPaths))
 
 	for idx, solPath := range contract.AllPaths {
-		lines, err := readLines(solPath)
-		if err != nil {
-			openErr = multierror.Append(openErr, err)
-			continue
-		}
+		// sources are shared by contracts, so each file is indexed once
+		mapping, ok := c.fileMappings[solPath]
+		if !ok {
+			var err error
+			if mapping, err = readFileMapping(solPath); err != nil {
+				openErr = multierror.Append(openErr, err)
+				continue
+			}
 
-		pos := 0
-		mapping := new(fileMapping)
-		for lineNum, line := range lines {
-			mapping.AddLine(lineNum, pos, pos+len(line)+1)
-			pos = pos + len(line) + 1
+			c.fileMappings[solPath] = mapping
 		}
 
 		c.srcFiles[contract.Name][idx] = mapping
@@ -139,6 +190,64 @@ func (c *coverageDataCollector) AddStatement(contractName string, start, end, fi
 	return nil
 }
 
+func (c *coverageDataCollector) AddBranch(contractName string, kind sol.BranchKind, start, end, file uint64) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	branch, err := c.locate(contractName, start, end, file)
+	if err != nil {
+		return err
+	}
+
+	if _, existing := c.branches[branch]; !existing {
+		c.branches[branch] = &branchRecord{
+			Kind: kind,
+		}
+	}
+
+	return nil
+}
+
+func (c *coverageDataCollector) AddFunction(contractName string, fn sol.Function) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	desc, err := c.locate(contractName, uint64(fn.Start), uint64(fn.Length), uint64(fn.File))
+	if err != nil {
+		return err
+	}
+
+	if _, existing := c.functions[desc]; !existing {
+		c.functions[desc] = &functionRecord{
+			Kind:     fn.Kind,
+			Name:     fn.Name,
+			Contract: fn.Contract,
+		}
+	}
+
+	return nil
+}
+
+// locate maps source range onto line and column positions, expects the lock to be held.
+func (c *coverageDataCollector) locate(contractName string, start, end, file uint64) (desc statementDescriptor, err error) {
+	if _, ok := c.paths[contractName]; !ok {
+		err = errors.Errorf("contract sources not found: %s", contractName)
+		return desc, err
+	} else if int(file) >= len(c.paths[contractName]) || c.srcFiles[contractName][int(file)] == nil {
+		err = errors.Errorf("contract source file not found: %d", file)
+		return desc, err
+	}
+
+	desc = statementDescriptor{
+		SrcLocation:  c.paths[contractName][file],
+		ContractName: contractName,
+	}
+	desc.LineStart, desc.ColStart = c.srcFiles[contractName][int(file)].PosToLine(int(start))
+	desc.LineEnd, desc.ColEnd = c.srcFiles[contractName][int(file)].PosToLine(int(start + end))
+
+	return desc, nil
+}
+
 func (c *coverageDataCollector) CollectCoverageEvent(contractName string, coverageEventABI abi.Event, log *ctypes.Log) error {
 	values, err := coverageEventABI.Inputs.Unpack(log.Data)
 	if err != nil {
@@ -155,89 +264,166 @@ func (c *coverageDataCollector) CollectCoverageEvent(contractName string, covera
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	if _, ok := c.paths[contractName]; !ok {
-		err = errors.Errorf("contract sources not found: %s", contractName)
-		return err
+	return c.collectMarker(contractName, ev.Start, ev.End, ev.File)
+}
+
+// collectMarker records a hit of a statement, branch arm or function entry,
+// depending on the encoded marker file. Expects the lock to be held.
+func (c *coverageDataCollector) collectMarker(contractName string, start, end, markerFile uint64) error {
+	file, arm, function := sol.DecodeMarkerFile(markerFile)
+	if function {
+		return c.collectFunctionHits(contractName, start, end, uint64(file), 1)
+	} else if arm >= 0 {
+		return c.collectBranchMarker(contractName, start, end, uint64(file), arm)
 	}
 
-	statement := statementDescriptor{
-		SrcLocation:  c.paths[contractName][ev.File],
-		ContractName: contractName,
+	statement, err := c.locate(contractName, start, end, uint64(file))
+	if err != nil {
+		return err
 	}
-	statement.LineStart, statement.ColStart = c.srcFiles[contractName][int(ev.File)].PosToLine(int(ev.Start))
-	statement.LineEnd, statement.ColEnd = c.srcFiles[contractName][int(ev.File)].PosToLine(int(ev.Start + ev.End))
 
 	c.statements[statement] += 1
 
 	return nil
 }
 
-const coverageRevertTag = " @coverage"
+func (c *coverageDataCollector) collectBranchMarker(contractName string, start, end, file uint64, arm int) error {
+	desc, err := c.locate(contractName, start, end, file)
+	if err != nil {
+		return err
+	}
+
+	branch, ok := c.branches[desc]
+	if !ok || arm > 1 {
+		err = errors.Errorf("unknown branch marker: %s arm %d", desc.String(), arm)
+		return err
+	}
+
+	branch.Hits[arm]++
 
-func hasCoverageReport(err error) bool {
-	if err == nil {
-		return false
+	// require and assert have no statement markers, both pass and revert mean the statement was executed
+	if branch.Kind == sol.BranchKindRequire || branch.Kind == sol.BranchKindAssert {
+		if _, ok := c.statements[desc]; ok {
+			c.statements[desc]++
+		}
 	}
 
-	return strings.Contains(err.Error(), coverageRevertTag)
+	return nil
 }
 
-func trimCoverageReport(err error) error {
-	idx := strings.LastIndex(err.Error(), coverageRevertTag)
-	if idx == -1 {
+// CollectStatementHits marks the statement as executed number of times, it's used
+// when statement hits are obtained from execution traces.
+func (c *coverageDataCollector) CollectStatementHits(contractName string, start, end, file uint64, hits int) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if _, ok := c.paths[contractName]; !ok {
+		err := errors.Errorf("contract sources not found: %s", contractName)
+		return err
+	} else if int(file) >= len(c.paths[contractName]) || c.srcFiles[contractName][int(file)] == nil {
+		err := errors.Errorf("contract source file not found: %d", file)
 		return err
 	}
 
-	return errors.New(err.Error()[:idx])
+	statement := statementDescriptor{
+		SrcLocation:  c.paths[contractName][file],
+		ContractName: contractName,
+	}
+	statement.LineStart, statement.ColStart = c.srcFiles[contractName][int(file)].PosToLine(int(start))
+	statement.LineEnd, statement.ColEnd = c.srcFiles[contractName][int(file)].PosToLine(int(start + end))
+
+	c.statements[statement] += hits
+
+	return nil
 }
 
-func (c *coverageDataCollector) CollectCoverageRevert(contractName string, err error) error {
-	idx := strings.LastIndex(err.Error(), coverageRevertTag)
-	if idx == -1 {
-		return errors.New("not a @coverage revert message")
-	} else {
-		idx += len(coverageRevertTag) + 1 // @coverage,1,2,3
-	}
+// CollectBranchHits marks the branch arm as taken number of times, it's used
+// when branch outcomes are obtained from execution traces.
+func (c *coverageDataCollector) CollectBranchHits(contractName string, start, end, file uint64, arm, hits int) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
 
-	locationParts := strings.Split(err.Error()[idx:], ",")
-	if len(locationParts) != 3 {
-		return errors.New("@coverage revert message contains wrong location")
+	desc, err := c.locate(contractName, start, end, file)
+	if err != nil {
+		return err
 	}
 
-	var (
-		start, _ = strconv.Atoi(locationParts[0])
-		end, _   = strconv.Atoi(locationParts[1])
-		file, _  = strconv.Atoi(locationParts[2])
-	)
-	if start < 0 || end < 0 || file < 0 {
-		return nil
+	branch, ok := c.branches[desc]
+	if !ok || arm < 0 || arm > 1 {
+		err = errors.Errorf("unknown branch: %s arm %d", desc.String(), arm)
+		return err
 	}
 
+	branch.Hits[arm] += hits
+
+	return nil
+}
+
+// CollectFunctionHits marks the function as entered number of times, it's used
+// when function entries are obtained from execution traces.
+func (c *coverageDataCollector) CollectFunctionHits(contractName string, start, end, file uint64, hits int) error {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	if _, ok := c.paths[contractName]; !ok {
-		err = errors.Errorf("contract sources not found: %s", contractName)
+	return c.collectFunctionHits(contractName, start, end, file, hits)
+}
+
+func (c *coverageDataCollector) collectFunctionHits(contractName string, start, end, file uint64, hits int) error {
+	desc, err := c.locate(contractName, start, end, file)
+	if err != nil {
 		return err
 	}
 
-	statement := statementDescriptor{
-		SrcLocation:  c.paths[contractName][file],
-		ContractName: contractName,
+	fn, ok := c.functions[desc]
+	if !ok {
+		err = errors.Errorf("unknown function: %s", desc.String())
+		return err
 	}
-	statement.LineStart, statement.ColStart = c.srcFiles[contractName][file].PosToLine(start)
-	statement.LineEnd, statement.ColEnd = c.srcFiles[contractName][file].PosToLine(start + end)
 
-	c.statements[statement] += 1
+	fn.Hits += hits
 
 	return nil
 }
 
-func (c *coverageDataCollector) ReportTextSummary(out io.Writer, filterNames ...string) (err error) {
-	c.mux.RLock()
-	defer c.mux.RUnlock()
+// CollectCoverageRevert records the statement or branch arm that reverted the execution,
+// the location comes from the coverage error in the revert data, see unwrapCoverageRevert.
+func (c *coverageDataCollector) CollectCoverageRevert(contractName string, start, end, file uint64) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
 
-	return nil
+	return c.collectMarker(contractName, start, end, file)
+}
+
+// collectCoverageRevert looks for the coverage error in revert data of the error returned by the node,
+// records the reverted location and replaces the error with the original revert reason.
+func collectCoverageRevert(agent CoverageDataCollector, contract *sol.Contract, err error) error {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err
+	}
+
+	hexData, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+
+	location, original, ok := unwrapCoverageRevert(contract, common.FromHex(hexData))
+	if !ok {
+		return err
+	}
+
+	if agent != nil {
+		if err := agent.CollectCoverageRevert(contract.Name, location.Start, location.End, location.File); err != nil {
+			log.WithError(err).Warningln("failed to collect coverage revert")
+		}
+	}
+
+	var contractABI *abi.ABI
+	if parsedABI, err := abi.JSON(bytes.NewReader(contract.ABI)); err == nil {
+		contractABI = &parsedABI
+	}
+
+	return DecodeRevertData(contractABI, original)
 }
 
 func (c *coverageDataCollector) ReportTextCoverfile(out io.Writer, filterNames ...string) (err error) {
@@ -282,16 +468,44 @@ func (c *coverageDataCollector) ReportTextCoverfile(out io.Writer, filterNames .
 	return nil
 }
 
+// ReportHTML writes a single page HTML report, if out is nil, the report is written into
+// a temporary file and opened in a web browser.
 func (c *coverageDataCollector) ReportHTML(out io.Writer, filterNames ...string) error {
 	c.mux.RLock()
 	defer c.mux.RUnlock()
 
+	profiles, files, err := c.htmlProfiles(filterNames...)
+	if err != nil {
+		return err
+	}
+
+	return c.htmlOutput(profiles, files, out)
+}
+
+// ReportHTMLDir writes an index page with coverage per source file and a page per file
+// into the directory, suitable for archiving as a CI artifact.
+func (c *coverageDataCollector) ReportHTMLDir(dir string, filterNames ...string) error {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	profiles, files, err := c.htmlProfiles(filterNames...)
+	if err != nil {
+		return err
+	}
+
+	return c.htmlDirOutput(profiles, files, dir)
+}
+
+// htmlProfiles builds cover profiles and coverage of source files, expects the read lock to be held.
+func (c *coverageDataCollector) htmlProfiles(
+	filterNames ...string,
+) (profiles map[string]*cover.Profile, filesByPath map[string]*fileCoverage, err error) {
 	filters := make(map[string]struct{}, len(filterNames))
 	for _, name := range filterNames {
 		filters[name] = struct{}{}
 	}
 
-	profiles := make(map[string]*cover.Profile, len(c.statements))
+	profiles = make(map[string]*cover.Profile, len(c.statements))
 	for desc, count := range c.statements {
 		if len(filters) > 0 {
 			if _, ok := filters[desc.ContractName]; !ok {
@@ -304,7 +518,7 @@ func (c *coverageDataCollector) ReportHTML(out io.Writer, filterNames ...string)
 				count = 1
 			}
 		} else if c.coverageMode != CoverageModeCount {
-			return errors.Errorf("unsupported coverageMode: %s", c.coverageMode)
+			return nil, nil, errors.Errorf("unsupported coverageMode: %s", c.coverageMode)
 		}
 
 		if profiles[desc.SrcLocation] == nil {
@@ -323,43 +537,63 @@ func (c *coverageDataCollector) ReportHTML(out io.Writer, filterNames ...string)
 		})
 	}
 
-	return c.htmlOutput(profiles, out)
-}
-
-// readLines reads a whole file into memory
-// and returns a slice of its lines.
-func readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
+	files, err := c.filesCoverage(filterNames...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	filesByPath = make(map[string]*fileCoverage, len(files))
+	for _, f := range files {
+		filesByPath[f.Path] = f
 	}
-	return lines, scanner.Err()
-}
 
-type newlineBoundary [2]int
+	return profiles, filesByPath, nil
+}
 
+// fileMapping maps byte offsets of a source file, as solc reports them, onto lines and byte columns.
 type fileMapping struct {
-	initOnce          sync.Once
-	newlineBoundaries []newlineBoundary
+	// lineStarts are offsets of the first byte of each line, in ascending order
+	lineStarts []int
+	size       int
 }
 
-func (f *fileMapping) AddLine(n, start, end int) {
-	f.newlineBoundaries = append(f.newlineBoundaries, newlineBoundary{start, end})
+// newFileMapping indexes line starts of the source. Lines end with LF, a CR before it stays
+// within the line, so offsets past it are not shifted.
+func newFileMapping(src []byte) *fileMapping {
+	f := &fileMapping{
+		lineStarts: make([]int, 1, bytes.Count(src, []byte{'\n'})+1),
+		size:       len(src),
+	}
+
+	for pos, b := range src {
+		if b == '\n' {
+			f.lineStarts = append(f.lineStarts, pos+1)
+		}
+	}
+
+	return f
 }
 
+// readFileMapping reads the whole source file, there is no limit on line length.
+func readFileMapping(path string) (*fileMapping, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileMapping(src), nil
+}
+
+// PosToLine returns 1-based line and byte column of the offset, end offsets of the last line
+// are valid too. Returns -1, -1 if the offset is out of the file.
 func (f *fileMapping) PosToLine(pos int) (line, column int) {
-	for line, boundary := range f.newlineBoundaries {
-		if pos >= boundary[0] && pos <= boundary[1] {
-			return line + 1, pos - boundary[0] + 1
-		}
+	if pos < 0 || pos > f.size {
+		return -1, -1
 	}
 
-	return -1, -1
+	idx := sort.Search(len(f.lineStarts), func(i int) bool {
+		return f.lineStarts[i] > pos
+	}) - 1
+
+	return idx + 1, pos - f.lineStarts[idx] + 1
 }
@@ -0,0 +1,378 @@
+package deployer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// ChangedLines maps paths of changed .sol files onto line numbers added or modified in the new version.
+type ChangedLines map[string]map[int]struct{}
+
+// ParseUnifiedDiff reads changed lines of .sol files from a unified diff, e.g. produced by git diff.
+// Relative paths from the diff are joined with root, if set.
+func ParseUnifiedDiff(diff io.Reader, root string) (ChangedLines, error) {
+	changed := make(ChangedLines)
+
+	var (
+		path    string
+		newLine int
+
+		// lines of the current hunk left to read in the old and new versions,
+		// so removed or added lines looking like file headers are not mistaken for them
+		oldLeft int
+		newLeft int
+	)
+
+	scanner := bufio.NewScanner(diff)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				if len(path) > 0 {
+					if changed[path] == nil {
+						changed[path] = make(map[int]struct{})
+					}
+
+					changed[path][newLine] = struct{}{}
+				}
+
+				newLine++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, "\\"):
+				// no newline at end of file
+			default:
+				// context line, empty ones may have lost the leading space
+				newLine++
+				newLeft--
+				oldLeft--
+			}
+
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			// next file, it may have no +++ header, e.g. renamed without changes or binary
+			path = ""
+		case strings.HasPrefix(line, "+++ "):
+			path = diffPath(strings.TrimPrefix(line, "+++ "), root)
+		case strings.HasPrefix(line, "@@"):
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+
+			newLine = hunk.newStart
+			oldLeft = hunk.oldCount
+			newLeft = hunk.newCount
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		err = errors.Wrap(err, "failed to read diff")
+		return nil, err
+	}
+
+	return changed, nil
+}
+
+// diffPath strips the b/ prefix git puts on new file paths, deleted files and other than .sol files are skipped.
+func diffPath(header, root string) string {
+	// git may add a tab with timestamp after the path
+	path := strings.TrimSpace(strings.SplitN(header, "\t", 2)[0])
+	if path == "/dev/null" || filepath.Ext(path) != ".sol" {
+		return ""
+	}
+
+	path = strings.TrimPrefix(path, "b/")
+	if len(root) > 0 && !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	return filepath.Clean(path)
+}
+
+type diffHunk struct {
+	newStart int
+	oldCount int
+	newCount int
+}
+
+// parseHunkHeader gets line ranges from a hunk header like @@ -10,7 +12,8 @@, the count is 1 if omitted.
+func parseHunkHeader(header string) (hunk diffHunk, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		err = errors.Errorf("malformed hunk header: %s", header)
+		return hunk, err
+	}
+
+	parseRange := func(field string) (start, count int, err error) {
+		parts := strings.SplitN(field[1:], ",", 2)
+
+		if start, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, 0, err
+		}
+
+		count = 1
+		if len(parts) > 1 {
+			if count, err = strconv.Atoi(parts[1]); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		return start, count, nil
+	}
+
+	if _, hunk.oldCount, err = parseRange(fields[1]); err != nil {
+		err = errors.Errorf("malformed hunk header: %s", header)
+		return hunk, err
+	}
+
+	if hunk.newStart, hunk.newCount, err = parseRange(fields[2]); err != nil {
+		err = errors.Errorf("malformed hunk header: %s", header)
+		return hunk, err
+	}
+
+	return hunk, nil
+}
+
+// sameSourcePath compares paths of a source file, one of them may be relative to an unknown root.
+func sameSourcePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if a == b {
+		return true
+	}
+
+	sep := string(filepath.Separator)
+	return strings.HasSuffix(a, sep+b) || strings.HasSuffix(b, sep+a)
+}
+
+// fileLines finds changed lines of the source file, paths of the diff may be relative.
+func (changed ChangedLines) fileLines(path string) map[int]struct{} {
+	for changedPath, lines := range changed {
+		if sameSourcePath(changedPath, path) {
+			return lines
+		}
+	}
+
+	return nil
+}
+
+// SetChangedLines sets lines changed against the base version, they're checked by ReportDiffCoverage
+// and annotated in the HTML report.
+func (c *coverageDataCollector) SetChangedLines(changed ChangedLines) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.changedLines = changed
+}
+
+// fileChange is a changed line within statement ranges, so it's executable. Hits are max hits
+// of the innermost statements containing the line.
+type fileChange struct {
+	Line int
+	Hits int
+}
+
+// fileChanges intersects changed lines with statement ranges, expects the read lock to be held.
+func (c *coverageDataCollector) fileChanges(path string, statements map[statementDescriptor]int) (changes []fileChange) {
+	lines := c.changedLines.fileLines(path)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	for line := range lines {
+		var candidates []statementDescriptor
+		for desc := range statements {
+			if desc.SrcLocation == path && line >= desc.LineStart && line <= desc.LineEnd {
+				candidates = append(candidates, desc)
+			}
+		}
+
+		if len(candidates) == 0 {
+			continue
+		}
+
+		change := fileChange{
+			Line: line,
+		}
+
+		for _, desc := range candidates {
+			var outer bool
+			for _, other := range candidates {
+				if other != desc && desc.contains(other) {
+					outer = true
+					break
+				}
+			}
+
+			// blocks and if statements span their bodies, nested statements decide
+			if !outer && statements[desc] > change.Hits {
+				change.Hits = statements[desc]
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Line < changes[j].Line
+	})
+
+	return changes
+}
+
+// ReportDiffCoverage writes a table of changed executable lines covered per source file,
+// listing changed lines that were not covered.
+func (c *coverageDataCollector) ReportDiffCoverage(out io.Writer, filterNames ...string) error {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	if len(c.changedLines) == 0 {
+		return nil
+	}
+
+	files, err := c.filesCoverage(filterNames...)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGED FILE\tLINES COVERED\tNOT COVERED")
+
+	var total, totalCovered int
+	for _, f := range files {
+		if len(f.Changes) == 0 {
+			continue
+		}
+
+		covered, uncovered := changesSummary(f.Changes)
+
+		total += len(f.Changes)
+		totalCovered += covered
+
+		fmt.Fprintf(w, "%s\t%d/%d (%.1f%%)\t%s\n",
+			limitPath(f.Path, reportPathSegments),
+			covered, len(f.Changes), percent(covered, len(f.Changes)),
+			uncovered,
+		)
+	}
+
+	fmt.Fprintf(w, "TOTAL\t%d/%d (%.1f%%)\t\n", totalCovered, total, percent(totalCovered, total))
+
+	return w.Flush()
+}
+
+// ReportLCOVDiffCoverage writes the table of changed lines covered per source file, like ReportDiffCoverage,
+// using line hits of merged LCOV tracefiles. Changed lines without line records are not executable.
+func ReportLCOVDiffCoverage(
+	out io.Writer,
+	changed ChangedLines,
+	tracefiles ...io.Reader,
+) (covered, total int, err error) {
+	lines := make(map[string]map[int]int)
+
+	for _, tracefile := range tracefiles {
+		if err := readLCOVLines(tracefile, lines); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	paths := make([]string, 0, len(lines))
+	for path := range lines {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGED FILE\tLINES COVERED\tNOT COVERED")
+
+	for _, path := range paths {
+		var changes []fileChange
+		for line := range changed.fileLines(path) {
+			if hits, ok := lines[path][line]; ok {
+				changes = append(changes, fileChange{
+					Line: line,
+					Hits: hits,
+				})
+			}
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Line < changes[j].Line
+		})
+
+		fileCovered, uncovered := changesSummary(changes)
+
+		total += len(changes)
+		covered += fileCovered
+
+		fmt.Fprintf(w, "%s\t%d/%d (%.1f%%)\t%s\n",
+			limitPath(path, reportPathSegments),
+			fileCovered, len(changes), percent(fileCovered, len(changes)),
+			uncovered,
+		)
+	}
+
+	fmt.Fprintf(w, "TOTAL\t%d/%d (%.1f%%)\t\n", covered, total, percent(covered, total))
+
+	return covered, total, w.Flush()
+}
+
+// changesSummary counts covered changed lines and formats uncovered ones.
+func changesSummary(changes []fileChange) (covered int, uncovered string) {
+	var uncoveredLines []int
+	for _, change := range changes {
+		if change.Hits > 0 {
+			covered++
+			continue
+		}
+
+		uncoveredLines = append(uncoveredLines, change.Line)
+	}
+
+	return covered, formatLineRanges(uncoveredLines)
+}
+
+// formatLineRanges joins sorted line numbers, collapsing consecutive ones: 3,7-9,12.
+func formatLineRanges(lines []int) string {
+	if len(lines) == 0 {
+		return "-"
+	}
+
+	var ranges []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+
+		i = j + 1
+	}
+
+	return strings.Join(ranges, ",")
+}
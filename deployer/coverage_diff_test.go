@@ -0,0 +1,171 @@
+package deployer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		diff     string
+		root     string
+		expected ChangedLines
+	}{
+		{
+			name: "multiple hunks and files",
+			diff: `diff --git a/contracts/Token.sol b/contracts/Token.sol
+index 1111111..2222222 100644
+--- a/contracts/Token.sol
++++ b/contracts/Token.sol
+@@ -3,2 +3,3 @@ contract Token {
+ uint256 a;
++uint256 b;
+ uint256 c;
+@@ -20 +21,2 @@
+-return a;
++return b;
++
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-old
++new
+diff --git a/contracts/Vault.sol b/contracts/Vault.sol
+--- a/contracts/Vault.sol
++++ b/contracts/Vault.sol
+@@ -7,0 +8 @@
++emit Deposit();
+`,
+			expected: ChangedLines{
+				"contracts/Token.sol": {4: {}, 21: {}, 22: {}},
+				"contracts/Vault.sol": {8: {}},
+			},
+		},
+		{
+			name: "rename",
+			diff: `diff --git a/contracts/Old.sol b/contracts/New.sol
+similarity index 90%
+rename from contracts/Old.sol
+rename to contracts/New.sol
+--- a/contracts/Old.sol
++++ b/contracts/New.sol
+@@ -1,2 +1,2 @@
+-contract Old {
++contract New {
+ }
+`,
+			expected: ChangedLines{
+				"contracts/New.sol": {1: {}},
+			},
+		},
+		{
+			name: "new and deleted files",
+			diff: `diff --git a/contracts/New.sol b/contracts/New.sol
+new file mode 100644
+--- /dev/null
++++ b/contracts/New.sol
+@@ -0,0 +1,2 @@
++contract New {
++}
+diff --git a/contracts/Gone.sol b/contracts/Gone.sol
+deleted file mode 100644
+--- a/contracts/Gone.sol
++++ /dev/null
+@@ -1,2 +0,0 @@
+-contract Gone {
+-}
+`,
+			expected: ChangedLines{
+				"contracts/New.sol": {1: {}, 2: {}},
+			},
+		},
+		{
+			name: "state is reset by the next file header",
+			diff: `diff --git a/contracts/A.sol b/contracts/A.sol
+--- a/contracts/A.sol
++++ b/contracts/A.sol
+@@ -1 +1 @@
+-uint256 a;
++uint256 b;
+diff --git a/contracts/B.sol b/contracts/C.sol
+similarity index 100%
+rename from contracts/B.sol
+rename to contracts/C.sol
+diff --git a/image.png b/image.png
+Binary files a/image.png and b/image.png differ
+@@ -5 +5 @@
++not a hunk of A.sol
+`,
+			expected: ChangedLines{
+				"contracts/A.sol": {1: {}},
+			},
+		},
+		{
+			name: "lines looking like headers within hunks",
+			diff: `diff --git a/contracts/A.sol b/contracts/A.sol
+--- a/contracts/A.sol
++++ b/contracts/A.sol
+@@ -1,3 +1,3 @@
+--- removed comment
++++ added comment
+ context
+\ No newline at end of file
+`,
+			expected: ChangedLines{
+				"contracts/A.sol": {1: {}},
+			},
+		},
+		{
+			name: "joined with root",
+			diff: `--- a/contracts/A.sol	2024-01-01 00:00:00
++++ b/contracts/A.sol	2024-01-01 00:00:00
+@@ -2 +2 @@
+-a
++b
+`,
+			root: "/src",
+			expected: ChangedLines{
+				"/src/contracts/A.sol": {2: {}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed, err := ParseUnifiedDiff(strings.NewReader(tc.diff), tc.root)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, changed)
+		})
+	}
+
+	_, err := ParseUnifiedDiff(strings.NewReader("+++ b/A.sol\n@@ -x +1 @@\n"), "")
+	assert.Error(t, err)
+}
+
+func TestReportLCOVDiffCoverage(t *testing.T) {
+	tracefiles := []string{
+		"TN:\nSF:/src/contracts/Token.sol\nDA:1,1\nDA:2,0\nDA:3,0\nDA:5,2\nend_of_record\n" +
+			"SF:/src/contracts/Vault.sol\nDA:1,1\nend_of_record\n",
+		"TN:\nSF:/src/contracts/Token.sol\nDA:2,1\nend_of_record\n",
+	}
+
+	// line 4 has no record, so it is not executable, Vault.sol has no changes
+	changed := ChangedLines{
+		"contracts/Token.sol": {2: {}, 3: {}, 4: {}, 5: {}},
+	}
+
+	out := new(bytes.Buffer)
+	covered, total, err := ReportLCOVDiffCoverage(out, changed, strings.NewReader(tracefiles[0]), strings.NewReader(tracefiles[1]))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, covered)
+	assert.Equal(t, 3, total)
+	assert.Regexp(t, `Token.sol +2/3 \(66.7%\) +3\n`, out.String())
+	assert.NotContains(t, out.String(), "Vault.sol")
+}
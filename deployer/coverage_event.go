@@ -11,6 +11,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/etherman/sol"
 )
 
 var (
@@ -74,6 +76,51 @@ func (d *deployer) GetCoverageEventInfo(
 	return eventName, coverageEventABI.Events[eventName], nil
 }
 
+// contractCoverageEvents collects ABI of coverage events the contract may emit, keyed by topic. Code
+// inherited from other contracts emits their events, so all contracts of the build are included.
+func contractCoverageEvents(contract *sol.Contract, deployedEventABI abi.Event) map[common.Hash]abi.Event {
+	events := make(map[common.Hash]abi.Event, len(contract.CoverageEventIDs)+1)
+	events[deployedEventABI.ID] = deployedEventABI
+
+	for _, definitionID := range contract.CoverageEventIDs {
+		eventName, eventABI := NewCoverageMarkerEvent(definitionID)
+		events[eventABI.Events[eventName].ID] = eventABI.Events[eventName]
+	}
+
+	return events
+}
+
+// unwrapCoverageRevert checks the revert data for the coverage error of any contract of the build,
+// it carries the marker location of the reverted statement and the original revert data.
+func unwrapCoverageRevert(contract *sol.Contract, data []byte) (location coverageEvent, original []byte, ok bool) {
+	if len(data) < 4 {
+		return location, nil, false
+	}
+
+	for _, definitionID := range contract.CoverageEventIDs {
+		errorName, errorABI := NewCoverageRevertError(definitionID)
+
+		coverageError := errorABI.Errors[errorName]
+		if !bytes.Equal(coverageError.ID[:4], data[:4]) {
+			continue
+		}
+
+		values, err := coverageError.Inputs.Unpack(data[4:])
+		if err != nil || len(values) != 4 {
+			return location, nil, false
+		}
+
+		location.Start, _ = values[0].(uint64)
+		location.End, _ = values[1].(uint64)
+		location.File, _ = values[2].(uint64)
+		original, _ = values[3].([]byte)
+
+		return location, original, true
+	}
+
+	return location, nil, false
+}
+
 type coverageMarkerEventOpts struct {
 	EventDefinitionID uint64
 }
@@ -93,6 +140,21 @@ func NewCoverageMarkerEvent(definitionID uint64) (name string, eventABI abi.ABI)
 	return name, eventABI
 }
 
+func NewCoverageRevertError(definitionID uint64) (name string, errorABI abi.ABI) {
+	buf := new(bytes.Buffer)
+
+	if err := coverageRevertErrorTemplate.Execute(buf, coverageMarkerEventOpts{
+		EventDefinitionID: definitionID,
+	}); err != nil {
+		panic(err)
+	}
+
+	errorABI, _ = abi.JSON(buf)
+	name = sol.CoverageRevertErrorName(definitionID)
+
+	return name, errorABI
+}
+
 type coverageDefinitionIDABIOpts struct {
 	ContractName string
 }
@@ -143,6 +205,33 @@ var coverageMarkerEventTemplate = template.Must(template.New("coverageMarkerEven
 	"type": "event"
 }]`))
 
+var coverageRevertErrorTemplate = template.Must(template.New("coverageRevertError").Parse(`[{
+	"inputs": [
+		{
+			"internalType": "uint64",
+			"name": "start",
+			"type": "uint64"
+		},
+		{
+			"internalType": "uint64",
+			"name": "end",
+			"type": "uint64"
+		},
+		{
+			"internalType": "uint64",
+			"name": "file",
+			"type": "uint64"
+		},
+		{
+			"internalType": "bytes",
+			"name": "data",
+			"type": "bytes"
+		}
+	],
+	"name": "___coverage_revert_{{.EventDefinitionID}}",
+	"type": "error"
+}]`))
+
 var coverageDefinitionIDABITemplate = template.Must(template.New("coverageDefinitionIDABI").Parse(`[{
 	"inputs": [],
 	"name": "___coverage_id_{{.ContractName}}",
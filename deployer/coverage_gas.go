@@ -0,0 +1,160 @@
+package deployer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// CollectStatementGas adds gas used by instructions of the statement, it's obtained from execution traces.
+func (c *coverageDataCollector) CollectStatementGas(contractName string, start, end, file uint64, gas uint64) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	desc, err := c.locate(contractName, start, end, file)
+	if err != nil {
+		return err
+	}
+
+	c.statementGas[desc] += gas
+
+	return nil
+}
+
+// CollectFunctionGas adds gas used by the function body, excluding internal functions it calls.
+func (c *coverageDataCollector) CollectFunctionGas(contractName string, start, end, file uint64, gas uint64) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	desc, err := c.locate(contractName, start, end, file)
+	if err != nil {
+		return err
+	}
+
+	fn, ok := c.functions[desc]
+	if !ok {
+		err = errors.Errorf("unknown function: %s", desc.String())
+		return err
+	}
+
+	fn.Gas += gas
+
+	return nil
+}
+
+// gasSpot is a statement with gas used by all its executions.
+type gasSpot struct {
+	desc statementDescriptor
+	hits int
+	gas  uint64
+}
+
+// ReportGasProfile writes tables of statements and functions ordered by gas used, limited
+// to top entries if limit is positive. Gas is collected by the trace coverage strategy only.
+func (c *coverageDataCollector) ReportGasProfile(out io.Writer, limit int, filterNames ...string) error {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	files, err := c.filesCoverage(filterNames...)
+	if err != nil {
+		return err
+	}
+
+	// hits of statements shared by contracts are summed, like gas
+	hits := make(map[statementDescriptor]int, len(c.statements))
+	for desc, count := range c.statements {
+		desc.ContractName = ""
+		hits[desc] += count
+	}
+
+	var total uint64
+	var spots []gasSpot
+	var functions []*fileFunction
+	for _, f := range files {
+		for desc, gas := range f.StatementGas {
+			total += gas
+			spots = append(spots, gasSpot{
+				desc: desc,
+				hits: hits[desc],
+				gas:  gas,
+			})
+		}
+
+		for _, fn := range f.Functions {
+			if fn.Record.Gas > 0 {
+				functions = append(functions, fn)
+			}
+		}
+	}
+
+	if total == 0 {
+		_, err := fmt.Fprintln(out, "no gas profile collected, it requires the trace coverage strategy")
+		return err
+	}
+
+	sort.Slice(spots, func(i, j int) bool {
+		if spots[i].gas != spots[j].gas {
+			return spots[i].gas > spots[j].gas
+		}
+
+		return spots[i].desc.String() < spots[j].desc.String()
+	})
+
+	sort.SliceStable(functions, func(i, j int) bool {
+		return functions[i].Record.Gas > functions[j].Record.Gas
+	})
+
+	if limit > 0 && len(spots) > limit {
+		spots = spots[:limit]
+	}
+
+	if limit > 0 && len(functions) > limit {
+		functions = functions[:limit]
+	}
+
+	avg := func(gas uint64, hits int) uint64 {
+		if hits == 0 {
+			return gas
+		}
+
+		return gas / uint64(hits)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STATEMENT\tHITS\tGAS\tAVG\tSHARE")
+
+	for _, spot := range spots {
+		fmt.Fprintf(w, "%s:%d.%d\t%d\t%d\t%d\t%.1f%%\n",
+			limitPath(spot.desc.SrcLocation, reportPathSegments),
+			spot.desc.LineStart, spot.desc.ColStart,
+			spot.hits,
+			spot.gas,
+			avg(spot.gas, spot.hits),
+			float64(spot.gas)/float64(total)*100,
+		)
+	}
+
+	fmt.Fprintf(w, "TOTAL\t\t%d\t\t\n", total)
+
+	if len(functions) == 0 {
+		return w.Flush()
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "FUNCTION\tCONTRACT\tCALLS\tGAS\tAVG")
+
+	for _, fn := range functions {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n",
+			fn.DisplayName(),
+			fn.Record.Contract,
+			fn.Record.Hits,
+			fn.Record.Gas,
+			avg(fn.Record.Gas, fn.Record.Hits),
+		)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,93 @@
+package deployer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepGasCosts(t *testing.T) {
+	// the CALL uses gas of the callee, up to the next step of the same frame
+	assert.Equal(t, []uint64{3, 3, 3, 1, 90, 3, 3, 0, 0}, stepGasCosts(traceTestLogs()))
+
+	// gas reported after the step can't exceed gas before it, the cost is used instead
+	logs := []structLog{
+		{PC: 0, Op: "PUSH1", Gas: 100, GasCost: 3, Depth: 1},
+		{PC: 2, Op: "STOP", Gas: 200, GasCost: 0, Depth: 1},
+	}
+	assert.Equal(t, []uint64{3, 0}, stepGasCosts(logs))
+}
+
+func TestTraceCoverageGas(t *testing.T) {
+	m, err := newTraceCoverageMapper(newTraceTestContract())
+	require.NoError(t, err)
+
+	m.replay(traceTestLogs(), m.runtime)
+
+	// gas of the nested frame counts for its statements, not for the CALL statement
+	assert.Equal(t, map[int]uint64{0: 84, 1: 12, 2: 1}, m.gas)
+	assert.Equal(t, map[int]uint64{0: 100}, m.functionGas)
+
+	// calls into other contracts count for the calling statement
+	m, err = newTraceCoverageMapper(newTraceTestContract())
+	require.NoError(t, err)
+
+	logs := traceTestLogs()
+	logs[4].Stack = []string{"0x70997970C51812dc3A010C7d01b50e0d17dc79C8", "0x5208"}
+	m.replay(logs, m.runtime)
+
+	assert.Equal(t, map[int]uint64{0: 90, 1: 6, 2: 1}, m.gas)
+	assert.Equal(t, map[int]uint64{0: 100}, m.functionGas)
+}
+
+func TestReportGasProfile(t *testing.T) {
+	// statements of the test contract start on lines 1 and 2
+	srcPath := filepath.Join(t.TempDir(), "Test.sol")
+	src := strings.Repeat(strings.Repeat("x", 29)+"\n", 4)
+	require.NoError(t, ioutil.WriteFile(srcPath, []byte(src), 0644))
+
+	contract := newTraceTestContract()
+	contract.Coverage = true
+	contract.AllPaths = []string{srcPath}
+
+	c := NewCoverageDataCollector(CoverageModeCount)
+	require.NoError(t, c.LoadContract(contract))
+
+	for _, statement := range contract.Statements {
+		require.NoError(t, c.AddStatement(contract.Name, uint64(statement[0]), uint64(statement[1]), uint64(statement[2])))
+	}
+
+	for _, fn := range contract.Functions {
+		require.NoError(t, c.AddFunction(contract.Name, fn))
+	}
+
+	out := new(bytes.Buffer)
+	require.NoError(t, c.ReportGasProfile(out, 0))
+	assert.Contains(t, out.String(), "no gas profile collected")
+
+	m, err := newTraceCoverageMapper(contract)
+	require.NoError(t, err)
+
+	m.replay(traceTestLogs(), m.runtime)
+	require.NoError(t, m.report(c))
+
+	out.Reset()
+	require.NoError(t, c.ReportGasProfile(out, 2))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 7)
+
+	// statements by gas used, limited to top two
+	assert.Regexp(t, `^STATEMENT +HITS +GAS +AVG +SHARE$`, lines[0])
+	assert.Regexp(t, `Test.sol:1\.11 +1 +84 +84 +86\.6%$`, lines[1])
+	assert.Regexp(t, `Test.sol:1\.21 +2 +12 +6 +12\.4%$`, lines[2])
+	assert.Regexp(t, `^TOTAL +97`, lines[3])
+
+	assert.Regexp(t, `^FUNCTION +CONTRACT +CALLS +GAS +AVG$`, lines[5])
+	assert.Regexp(t, `^run +Test +2 +100 +50$`, lines[6])
+}
@@ -27,8 +27,15 @@ import (
 // htmlOutput reads the profile data from profile and generates an HTML
 // coverage report, writing it to outfile. If outfile is empty,
 // it writes the report to a temporary file and opens it in a web browser.
-func (c *coverageDataCollector) htmlOutput(profiles map[string]*cover.Profile, out io.Writer) (err error) {
-	var d templateData
+func (c *coverageDataCollector) htmlOutput(
+	profiles map[string]*cover.Profile,
+	files map[string]*fileCoverage,
+	out io.Writer,
+) (err error) {
+	d, err := c.htmlTemplateData(profiles, files)
+	if err != nil {
+		return err
+	}
 
 	var shouldStartBrowser bool
 	var tempFile *os.File
@@ -39,51 +46,183 @@ func (c *coverageDataCollector) htmlOutput(profiles map[string]*cover.Profile, o
 		if err != nil {
 			return err
 		}
+		defer tempFile.Close()
 
 		out = tempFile
 	}
 
-	mergedProfiles, err := mergeSortProfiles(c.coverageMode, profiles)
+	err = htmlTemplate.Execute(out, d)
 	if err != nil {
 		return err
 	}
 
-	for _, profile := range mergedProfiles {
+	if shouldStartBrowser {
+		if !startBrowser("file://" + tempFile.Name()) {
+			log.Warningf("HTML output written to %s", tempFile.Name())
+		}
+	}
+
+	return nil
+}
+
+// htmlDirOutput writes a self-contained report into the directory, index.html lists
+// source files with their coverage and links to a page per file. No browser is started.
+func (c *coverageDataCollector) htmlDirOutput(
+	profiles map[string]*cover.Profile,
+	files map[string]*fileCoverage,
+	dir string,
+) error {
+	d, err := c.htmlTemplateData(profiles, files)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	writePage := func(name string, tpl *template.Template, data interface{}) error {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+
+		if err := tpl.Execute(f, data); err != nil {
+			_ = f.Close()
+			return err
+		}
+
+		return f.Close()
+	}
+
+	for _, file := range d.Files {
+		if err := writePage(file.Page, htmlPageTemplate, templatePage{
+			File: file,
+			Set:  d.Set,
+			Gas:  len(file.GasBody) > 0,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := writePage("index.html", htmlIndexTemplate, d); err != nil {
+		return err
+	}
+
+	log.Infof("HTML output written to %s", filepath.Join(dir, "index.html"))
+
+	return nil
+}
+
+func (c *coverageDataCollector) htmlTemplateData(
+	profiles map[string]*cover.Profile,
+	files map[string]*fileCoverage,
+) (d templateData, err error) {
+	mergedProfiles, err := mergeSortProfiles(c.coverageMode, profiles)
+	if err != nil {
+		return d, err
+	}
+
+	for idx, profile := range mergedProfiles {
 		if profile.Mode == "set" {
 			d.Set = true
 		}
 
 		src, err := ioutil.ReadFile(profile.FileName)
 		if err != nil {
-			return fmt.Errorf("can't read %q: %v", profile.FileName, err)
+			return d, fmt.Errorf("can't read %q: %v", profile.FileName, err)
 		}
+
+		var branches []*fileBranch
+		var functions []*fileFunction
+		var changes []fileChange
+		var statementGas map[statementDescriptor]uint64
+		var branchCoverage float64
+		var statements, statementsCovered int
+		if f, ok := files[profile.FileName]; ok {
+			branches = f.Branches
+			functions = f.Functions
+			changes = f.Changes
+			statementGas = f.StatementGas
+			branchCoverage = percent(f.BranchArmsCovered, f.BranchArms)
+			statements, statementsCovered = f.Statements, f.StatementsCovered
+		}
+
 		profile.FileName = limitPath(profile.FileName, reportPathSegments)
 
 		var buf bytes.Buffer
-		err = htmlGen(&buf, src, profile.Boundaries(src))
+		err = htmlGen(&buf, src, profile.Boundaries(src), changes)
 		if err != nil {
-			return err
+			return d, err
 		}
 
+		var functionsHit int
+		for _, fn := range functions {
+			if fn.Record.Hits > 0 {
+				functionsHit++
+			}
+		}
+
+		gasBody, gas, err := htmlGasGen(src, statementGas)
+		if err != nil {
+			return d, err
+		}
+
+		d.Gas = d.Gas || gas > 0
+
+		changesCovered, changesUncovered := changesSummary(changes)
 		d.Files = append(d.Files, &templateFile{
-			Name:     profile.FileName,
-			Body:     template.HTML(buf.String()),
-			Coverage: percentCovered(profile),
+			Name:              profile.FileName,
+			Page:              fmt.Sprintf("%03d-%s.html", idx, filepath.Base(profile.FileName)),
+			Body:              template.HTML(buf.String()),
+			Coverage:          percentCovered(profile),
+			Statements:        statements,
+			StatementsCovered: statementsCovered,
+			Branches:          branches,
+			BranchCoverage:    branchCoverage,
+			Functions:         functions,
+			FunctionsHit:      functionsHit,
+			Changes:           len(changes),
+			ChangesCovered:    changesCovered,
+			ChangesUncovered:  changesUncovered,
+			GasBody:           gasBody,
+			Gas:               gas,
 		})
 	}
 
-	err = htmlTemplate.Execute(out, d)
-	if err != nil {
-		return err
+	return d, nil
+}
+
+// htmlGasGen renders the source with statements highlighted by gas used, hot spots are red.
+func htmlGasGen(src []byte, statementGas map[statementDescriptor]uint64) (body template.HTML, total uint64, err error) {
+	if len(statementGas) == 0 {
+		return "", 0, nil
 	}
 
-	if shouldStartBrowser {
-		if !startBrowser("file://" + tempFile.Name()) {
-			log.Warningln(os.Stderr, "HTML output written to %s\n", tempFile.Name())
-		}
+	profile := &cover.Profile{
+		Mode: string(CoverageModeCount),
 	}
 
-	return nil
+	for desc, gas := range statementGas {
+		total += gas
+		profile.Blocks = append(profile.Blocks, cover.ProfileBlock{
+			StartLine: desc.LineStart,
+			StartCol:  desc.ColStart,
+			EndLine:   desc.LineEnd,
+			EndCol:    desc.ColEnd,
+			NumStmt:   1,
+			Count:     int(gas),
+		})
+	}
+
+	sort.Sort(blocksByStart(profile.Blocks))
+
+	var buf bytes.Buffer
+	if err := htmlGen(&buf, src, profile.Boundaries(src), nil); err != nil {
+		return "", 0, err
+	}
+
+	return template.HTML(buf.String()), total, nil
 }
 
 const reportPathSegments = 3
@@ -180,9 +319,26 @@ func percentCovered(p *cover.Profile) float64 {
 
 // htmlGen generates an HTML coverage report with the provided filename,
 // source code, and tokens, and writes it to the given Writer.
-func htmlGen(w io.Writer, src []byte, boundaries []cover.Boundary) error {
+// Changed lines get a marker in the left margin.
+func htmlGen(w io.Writer, src []byte, boundaries []cover.Boundary, changes []fileChange) error {
+	changedLines := make(map[int]int, len(changes))
+	for _, change := range changes {
+		changedLines[change.Line] = change.Hits
+	}
+
 	dst := bufio.NewWriter(w)
+	line := 1
 	for i := range src {
+		if i > 0 && src[i-1] == '\n' {
+			line++
+		}
+		if i == 0 || src[i-1] == '\n' {
+			if hits, ok := changedLines[line]; ok && hits > 0 {
+				dst.WriteString(`<span class="diff-hit" title="changed, covered"></span>`)
+			} else if ok {
+				dst.WriteString(`<span class="diff-miss" title="changed, not covered"></span>`)
+			}
+		}
 		for len(boundaries) > 0 && boundaries[0].Offset == i {
 			b := boundaries[0]
 			if b.Start {
@@ -242,6 +398,27 @@ func rgb(n int) string {
 	return fmt.Sprintf("rgb(%v, %v, %v)", r, g, b)
 }
 
+// rgbGas returns an rgb value for the specified gas heat value
+// between 0 (no gas used) and 10 (the hottest statement).
+func rgbGas(n int) string {
+	if n == 0 {
+		return "rgb(80, 80, 80)" // Gray
+	}
+	// Gradient from yellow to red.
+	g := 220 - 20*(n-1)
+	b := 120 - 12*(n-1)
+	return fmt.Sprintf("rgb(255, %v, %v)", g, b)
+}
+
+// gasColors generates the CSS rules for the gas heat map layer.
+func gasColors() template.CSS {
+	var buf bytes.Buffer
+	for i := 0; i < 11; i++ {
+		fmt.Fprintf(&buf, "pre.gas .cov%v { color: %v }\n", i, rgbGas(i))
+	}
+	return template.CSS(buf.String())
+}
+
 // colors generates the CSS rules for coverage colors.
 func colors() template.CSS {
 	var buf bytes.Buffer
@@ -251,25 +428,57 @@ func colors() template.CSS {
 	return template.CSS(buf.String())
 }
 
-var htmlTemplate = template.Must(template.New("html").Funcs(template.FuncMap{
-	"colors": colors,
-}).Parse(tmplHTML))
+var htmlFuncs = template.FuncMap{
+	"colors":    colors,
+	"gasColors": gasColors,
+}
+
+var (
+	htmlTemplate      = template.Must(template.New("html").Funcs(htmlFuncs).Parse(tmplHTMLCommon + tmplHTML))
+	htmlIndexTemplate = template.Must(template.New("index").Funcs(htmlFuncs).Parse(tmplHTMLCommon + tmplHTMLIndex))
+	htmlPageTemplate  = template.Must(template.New("page").Funcs(htmlFuncs).Parse(tmplHTMLCommon + tmplHTMLPage))
+)
 
 type templateData struct {
 	Files []*templateFile
 	Set   bool
+	// Gas is set if any file has the gas heat map layer
+	Gas bool
+}
+
+// templatePage is a single file page of the report written to a directory.
+type templatePage struct {
+	File *templateFile
+	Set  bool
+	Gas  bool
 }
 
 type templateFile struct {
-	Name     string
-	Body     template.HTML
-	Coverage float64
+	Name string
+	// Page is the file name of the file page in the report directory
+	Page              string
+	Body              template.HTML
+	Coverage          float64
+	Statements        int
+	StatementsCovered int
+	Branches          []*fileBranch
+	BranchCoverage    float64
+	Functions         []*fileFunction
+	FunctionsHit      int
+
+	// Changes is the number of executable lines changed against the base version
+	Changes          int
+	ChangesCovered   int
+	ChangesUncovered string
+
+	// GasBody is the source highlighted by gas used, Gas is the total used by statements
+	GasBody template.HTML
+	Gas     uint64
 }
 
-const tmplHTML = `
-<!DOCTYPE html>
-<html>
-	<head>
+// tmplHTMLCommon defines parts shared by the single page report and the report directory pages.
+const tmplHTMLCommon = `
+{{define "head"}}
 		<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
 		<style>
 			body {
@@ -280,6 +489,9 @@ const tmplHTML = `
 				font-family: Menlo, monospace;
 				font-weight: bold;
 			}
+			a {
+				color: rgb(160, 160, 160);
+			}
 			#topbar {
 				background: black;
 				position: fixed;
@@ -303,18 +515,48 @@ const tmplHTML = `
 			#legend span {
 				margin: 0 5px;
 			}
+			table.branches, table.functions, table.files {
+				margin: 20px 0;
+				border-collapse: collapse;
+			}
+			table.branches td, table.branches th, table.functions td, table.functions th, table.files td, table.files th {
+				padding: 2px 12px;
+				text-align: left;
+			}
+			pre {
+				position: relative;
+				padding-left: 16px;
+			}
+			.diff-hit::before, .diff-miss::before {
+				position: absolute;
+				left: 0;
+				content: "\25B6";
+			}
+			.diff-hit::before {
+				color: rgb(20, 200, 20);
+			}
+			.diff-miss::before {
+				color: rgb(192, 0, 0);
+			}
+			p.changes {
+				margin: 20px 0;
+			}
+			#layers {
+				float: left;
+				margin: 12px 0 0 20px;
+			}
+			pre.gas, body.gas-layer pre.has-gas {
+				display: none;
+			}
+			body.gas-layer pre.gas {
+				display: block;
+			}
 			{{colors}}
+			{{gasColors}}
 		</style>
-	</head>
-	<body>
-		<div id="topbar">
-			<div id="nav">
-				<select id="files">
-				{{range $i, $f := .Files}}
-				<option value="file{{$i}}">{{$f.Name}} ({{printf "%.1f" $f.Coverage}}%)</option>
-				{{end}}
-				</select>
-			</div>
+{{end}}
+
+{{define "legend"}}
 			<div id="legend">
 				<span>not tracked</span>
 			{{if .Set}}
@@ -334,10 +576,82 @@ const tmplHTML = `
 				<span class="cov10">high coverage</span>
 			{{end}}
 			</div>
+{{end}}
+
+{{define "layers"}}
+			{{if .Gas}}
+			<label id="layers"><input type="checkbox" id="gas-layer"> gas heat map</label>
+			<script>
+			(function() {
+				var layer = document.getElementById('gas-layer');
+				layer.addEventListener('change', function() {
+					document.body.classList.toggle('gas-layer', layer.checked);
+				}, false);
+			})();
+			</script>
+			{{end}}
+{{end}}
+
+{{define "file"}}
+		{{if .Functions}}
+		<table class="functions">
+			<tr><th>line</th><th>function</th><th>contract</th><th>hits</th><th>statements</th>{{if $.GasBody}}<th>gas</th>{{end}}</tr>
+			{{range .Functions}}
+			<tr class="{{if .Record.Hits}}cov8{{else}}cov0{{end}}">
+				<td>{{.Line}}</td>
+				<td>{{.DisplayName}}</td>
+				<td>{{.Record.Contract}}</td>
+				<td>{{.Record.Hits}}</td>
+				<td>{{.StatementsCovered}}/{{.Statements}}</td>
+				{{if $.GasBody}}<td>{{.Record.Gas}}</td>{{end}}
+			</tr>
+			{{end}}
+		</table>
+		{{end}}
+		{{if .Changes}}
+		<p class="changes">changed lines covered: {{.ChangesCovered}}/{{.Changes}}, not covered: <span class="cov0">{{.ChangesUncovered}}</span></p>
+		{{end}}
+		<pre{{if .GasBody}} class="has-gas"{{end}}>{{.Body}}</pre>
+		{{if .GasBody}}<pre class="gas">{{.GasBody}}</pre>{{end}}
+		{{if .Branches}}
+		<table class="branches">
+			<tr><th>line</th><th>branch</th><th>true / pass</th><th>false / fail</th></tr>
+			{{range .Branches}}
+			<tr>
+				<td>{{.Line}}</td>
+				<td>{{.Record.Kind}}</td>
+				<td class="{{if index .Record.Hits 0}}cov8{{else}}cov0{{end}}">{{index .Record.Hits 0}}</td>
+				<td class="{{if index .Record.Hits 1}}cov8{{else}}cov0{{end}}">{{index .Record.Hits 1}}</td>
+			</tr>
+			{{end}}
+		</table>
+		{{end}}
+{{end}}
+`
+
+const tmplHTML = `
+<!DOCTYPE html>
+<html>
+	<head>
+		{{template "head"}}
+	</head>
+	<body>
+		<div id="topbar">
+			<div id="nav">
+				<select id="files">
+				{{range $i, $f := .Files}}
+				<option value="file{{$i}}">{{$f.Name}} ({{printf "%.1f" $f.Coverage}}%{{if $f.Branches}}, branches {{printf "%.1f" $f.BranchCoverage}}%{{end}})</option>
+				{{end}}
+				</select>
+			</div>
+			{{template "legend" .}}
+			{{template "layers" .}}
 		</div>
 		<div id="content">
 		{{range $i, $f := .Files}}
-		<pre class="file" id="file{{$i}}" {{if $i}}style="display: none"{{end}}>{{$f.Body}}</pre>
+		<div class="file" id="file{{$i}}" {{if $i}}style="display: none"{{end}}>
+		{{template "file" $f}}
+		</div>
 		{{end}}
 		</div>
 	</body>
@@ -356,3 +670,51 @@ const tmplHTML = `
 	</script>
 </html>
 `
+
+const tmplHTMLIndex = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<title>Coverage report</title>
+		{{template "head"}}
+	</head>
+	<body>
+		<table class="files">
+			<tr><th>file</th><th>statements</th><th>branches</th><th>functions</th><th>changed lines</th>{{if $.Gas}}<th>gas</th>{{end}}</tr>
+			{{range .Files}}
+			<tr>
+				<td><a href="{{.Page}}">{{.Name}}</a></td>
+				<td class="{{if .StatementsCovered}}cov8{{else}}cov0{{end}}" title="{{.StatementsCovered}}/{{.Statements}}">{{printf "%.1f" .Coverage}}%</td>
+				<td>{{if .Branches}}{{printf "%.1f" .BranchCoverage}}%{{else}}-{{end}}</td>
+				<td>{{if .Functions}}{{.FunctionsHit}}/{{len .Functions}}{{else}}-{{end}}</td>
+				<td>{{if .Changes}}{{.ChangesCovered}}/{{.Changes}}{{else}}-{{end}}</td>
+				{{if $.Gas}}<td>{{if .Gas}}{{.Gas}}{{else}}-{{end}}</td>{{end}}
+			</tr>
+			{{end}}
+		</table>
+	</body>
+</html>
+`
+
+const tmplHTMLPage = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<title>{{.File.Name}}</title>
+		{{template "head"}}
+	</head>
+	<body>
+		<div id="topbar">
+			<div id="nav">
+				<a href="index.html">index</a>
+				{{.File.Name}} ({{printf "%.1f" .File.Coverage}}%{{if .File.Branches}}, branches {{printf "%.1f" .File.BranchCoverage}}%{{end}})
+			</div>
+			{{template "legend" .}}
+			{{template "layers" .}}
+		</div>
+		<div id="content">
+		{{template "file" .File}}
+		</div>
+	</body>
+</html>
+`
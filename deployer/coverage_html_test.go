@@ -0,0 +1,93 @@
+package deployer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/cover"
+)
+
+func TestHTMLDirOutput(t *testing.T) {
+	srcDir := t.TempDir()
+
+	vaultPath := filepath.Join(srcDir, "Vault.sol")
+	require.NoError(t, ioutil.WriteFile(vaultPath, []byte("contract Vault {\n    uint256 x = 1;\n}\n"), 0644))
+
+	tokenPath := filepath.Join(srcDir, "Token.sol")
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("contract Token {\n    uint256 y = <2>;\n}\n"), 0644))
+
+	profiles := map[string]*cover.Profile{
+		vaultPath: {
+			FileName: vaultPath,
+			Mode:     string(CoverageModeCount),
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 2, StartCol: 5, EndLine: 2, EndCol: 19, NumStmt: 1, Count: 3},
+			},
+		},
+		tokenPath: {
+			FileName: tokenPath,
+			Mode:     string(CoverageModeCount),
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 2, StartCol: 5, EndLine: 2, EndCol: 21, NumStmt: 1, Count: 0},
+			},
+		},
+	}
+
+	files := map[string]*fileCoverage{
+		vaultPath: {
+			Path:              vaultPath,
+			Statements:        1,
+			StatementsCovered: 1,
+			Functions: []*fileFunction{{
+				Line:   1,
+				Record: functionRecord{Kind: "function", Name: "deposit", Contract: "Vault", Hits: 3},
+			}},
+			Changes: []fileChange{{Line: 2, Hits: 3}},
+		},
+		tokenPath: {
+			Path:       tokenPath,
+			Statements: 1,
+		},
+	}
+
+	c := &coverageDataCollector{coverageMode: CoverageModeCount}
+
+	dir := filepath.Join(t.TempDir(), "report")
+	require.NoError(t, c.htmlDirOutput(profiles, files, dir))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	// pages are ordered by coverage, the most covered first
+	assert.Equal(t, []string{"000-Vault.sol.html", "001-Token.sol.html", "index.html"}, names)
+
+	index, err := ioutil.ReadFile(filepath.Join(dir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<a href="000-Vault.sol.html">`)
+	assert.Contains(t, string(index), `<a href="001-Token.sol.html">`)
+	assert.Contains(t, string(index), `title="1/1">100.0%`)
+	assert.Contains(t, string(index), `title="0/1">0.0%`)
+	assert.Contains(t, string(index), "<td>1/1</td>", "functions and changed lines of Vault.sol")
+
+	vaultPage, err := ioutil.ReadFile(filepath.Join(dir, "000-Vault.sol.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(vaultPage), `<a href="index.html">index</a>`)
+	assert.Contains(t, string(vaultPage), "<td>deposit</td>")
+	assert.Contains(t, string(vaultPage), "changed lines covered: 1/1")
+	assert.Contains(t, string(vaultPage), "uint256 x = 1;")
+
+	// source is escaped
+	tokenPage, err := ioutil.ReadFile(filepath.Join(dir, "001-Token.sol.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(tokenPage), "uint256 y = &lt;2&gt;;")
+	assert.NotContains(t, string(tokenPage), `class="changes"`)
+}
@@ -0,0 +1,398 @@
+package deployer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// fileCoverage aggregates statement, branch and function coverage of a single source file.
+type fileCoverage struct {
+	Path string
+
+	Statements        int
+	StatementsCovered int
+	BranchArms        int
+	BranchArmsCovered int
+
+	// Lines maps line numbers onto max hits of statements starting there
+	Lines     map[int]int
+	Branches  []*fileBranch
+	Functions []*fileFunction
+
+	// Changes are executable lines changed against the base version, see SetChangedLines
+	Changes []fileChange
+
+	// StatementGas maps statements onto gas used, collected from traces only
+	StatementGas map[statementDescriptor]uint64
+}
+
+type fileBranch struct {
+	Line, Col int
+	Record    branchRecord
+}
+
+type fileFunction struct {
+	Line, Col int
+	Record    functionRecord
+
+	// Statements within the function body
+	Statements        int
+	StatementsCovered int
+}
+
+// contains checks that the other source range is within this one, both are in the same file.
+func (s statementDescriptor) contains(other statementDescriptor) bool {
+	if s.SrcLocation != other.SrcLocation {
+		return false
+	}
+
+	startsAfter := other.LineStart > s.LineStart || other.LineStart == s.LineStart && other.ColStart >= s.ColStart
+	endsBefore := other.LineEnd < s.LineEnd || other.LineEnd == s.LineEnd && other.ColEnd <= s.ColEnd
+
+	return startsAfter && endsBefore
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(covered) / float64(total) * 100
+}
+
+// filesCoverage groups collected data by source files, sorted by path. Hits are
+// limited to 1 in the set coverage mode. Expects the read lock to be held.
+func (c *coverageDataCollector) filesCoverage(filterNames ...string) ([]*fileCoverage, error) {
+	if c.coverageMode != CoverageModeSet && c.coverageMode != CoverageModeCount {
+		return nil, errors.Errorf("unsupported coverageMode: %s", c.coverageMode)
+	}
+
+	filters := make(map[string]struct{}, len(filterNames))
+	for _, name := range filterNames {
+		filters[name] = struct{}{}
+	}
+
+	skip := func(desc statementDescriptor) bool {
+		if len(filters) == 0 {
+			return false
+		}
+
+		_, ok := filters[desc.ContractName]
+		return !ok
+	}
+
+	hits := func(count int) int {
+		if c.coverageMode == CoverageModeSet && count > 0 {
+			return 1
+		}
+
+		return count
+	}
+
+	files := make(map[string]*fileCoverage)
+	fileOf := func(path string) *fileCoverage {
+		if files[path] == nil {
+			files[path] = &fileCoverage{
+				Path:  path,
+				Lines: make(map[int]int),
+			}
+		}
+
+		return files[path]
+	}
+
+	// statements are collected per contract, the same source may be shared by many
+	seenStatements := make(map[statementDescriptor]int)
+	for desc, count := range c.statements {
+		if skip(desc) {
+			continue
+		}
+
+		desc.ContractName = ""
+		seenStatements[desc] += count
+	}
+
+	for desc, count := range seenStatements {
+		f := fileOf(desc.SrcLocation)
+		f.Statements++
+		if count > 0 {
+			f.StatementsCovered++
+		}
+
+		if lineHits, ok := f.Lines[desc.LineStart]; !ok || hits(count) > lineHits {
+			f.Lines[desc.LineStart] = hits(count)
+		}
+	}
+
+	seenBranches := make(map[statementDescriptor]*branchRecord)
+	for desc, record := range c.branches {
+		if skip(desc) {
+			continue
+		}
+
+		desc.ContractName = ""
+		if seen, ok := seenBranches[desc]; ok {
+			seen.Hits[0] += record.Hits[0]
+			seen.Hits[1] += record.Hits[1]
+			continue
+		}
+
+		merged := *record
+		seenBranches[desc] = &merged
+	}
+
+	for desc, record := range seenBranches {
+		f := fileOf(desc.SrcLocation)
+		branch := &fileBranch{
+			Line: desc.LineStart,
+			Col:  desc.ColStart,
+			Record: branchRecord{
+				Kind: record.Kind,
+				Hits: [2]int{hits(record.Hits[0]), hits(record.Hits[1])},
+			},
+		}
+
+		f.Branches = append(f.Branches, branch)
+		f.BranchArms += 2
+		for _, armHits := range branch.Record.Hits {
+			if armHits > 0 {
+				f.BranchArmsCovered++
+			}
+		}
+	}
+
+	seenFunctions := make(map[statementDescriptor]*functionRecord)
+	for desc, record := range c.functions {
+		if skip(desc) {
+			continue
+		}
+
+		desc.ContractName = ""
+		if seen, ok := seenFunctions[desc]; ok {
+			seen.Hits += record.Hits
+			seen.Gas += record.Gas
+			continue
+		}
+
+		merged := *record
+		seenFunctions[desc] = &merged
+	}
+
+	for desc, record := range seenFunctions {
+		fn := &fileFunction{
+			Line:   desc.LineStart,
+			Col:    desc.ColStart,
+			Record: *record,
+		}
+		fn.Record.Hits = hits(record.Hits)
+
+		for statement, count := range seenStatements {
+			if !desc.contains(statement) {
+				continue
+			}
+
+			fn.Statements++
+			if count > 0 {
+				fn.StatementsCovered++
+			}
+		}
+
+		f := fileOf(desc.SrcLocation)
+		f.Functions = append(f.Functions, fn)
+	}
+
+	for desc, gas := range c.statementGas {
+		if skip(desc) {
+			continue
+		}
+
+		desc.ContractName = ""
+		f := fileOf(desc.SrcLocation)
+		if f.StatementGas == nil {
+			f.StatementGas = make(map[statementDescriptor]uint64)
+		}
+
+		f.StatementGas[desc] += gas
+	}
+
+	sorted := make([]*fileCoverage, 0, len(files))
+	for _, f := range files {
+		f.Changes = c.fileChanges(f.Path, seenStatements)
+
+		sort.Slice(f.Branches, func(i, j int) bool {
+			a, b := f.Branches[i], f.Branches[j]
+			return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+		})
+
+		sort.Slice(f.Functions, func(i, j int) bool {
+			a, b := f.Functions[i], f.Functions[j]
+			return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+		})
+
+		sorted = append(sorted, f)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	return sorted, nil
+}
+
+// ReportTextSummary writes a table with statement and branch coverage per source file,
+// followed by a table of functions with their hits.
+func (c *coverageDataCollector) ReportTextSummary(out io.Writer, filterNames ...string) error {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	files, err := c.filesCoverage(filterNames...)
+	if err != nil {
+		return err
+	}
+
+	ratio := func(covered, total int) string {
+		return fmt.Sprintf("%d/%d (%.1f%%)", covered, total, percent(covered, total))
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSTATEMENTS\tBRANCHES")
+
+	var total fileCoverage
+	for _, f := range files {
+		total.Statements += f.Statements
+		total.StatementsCovered += f.StatementsCovered
+		total.BranchArms += f.BranchArms
+		total.BranchArmsCovered += f.BranchArmsCovered
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			limitPath(f.Path, reportPathSegments),
+			ratio(f.StatementsCovered, f.Statements),
+			ratio(f.BranchArmsCovered, f.BranchArms),
+		)
+	}
+
+	fmt.Fprintf(w, "TOTAL\t%s\t%s\n",
+		ratio(total.StatementsCovered, total.Statements),
+		ratio(total.BranchArmsCovered, total.BranchArms),
+	)
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var hasFunctions bool
+	for _, f := range files {
+		hasFunctions = hasFunctions || len(f.Functions) > 0
+	}
+
+	if !hasFunctions {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "FUNCTION\tCONTRACT\tHITS\tSTATEMENTS")
+
+	for _, f := range files {
+		for _, fn := range f.Functions {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
+				fn.DisplayName(),
+				fn.Record.Contract,
+				fn.Record.Hits,
+				ratio(fn.StatementsCovered, fn.Statements),
+			)
+		}
+	}
+
+	return w.Flush()
+}
+
+func (fn *fileFunction) DisplayName() string {
+	if fn.Record.Kind == "modifier" {
+		return "modifier " + fn.Record.Name
+	}
+
+	return fn.Record.Name
+}
+
+// ReportLCOV writes the tracefile in LCOV format, with function (FN), line (DA) and branch (BRDA) records.
+func (c *coverageDataCollector) ReportLCOV(out io.Writer, filterNames ...string) (err error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	files, err := c.filesCoverage(filterNames...)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if writeErr := writeLCOVRecord(out, f); writeErr != nil {
+			err = multierror.Append(err, writeErr)
+		}
+	}
+
+	return err
+}
+
+func writeLCOVRecord(out io.Writer, f *fileCoverage) error {
+	lines := make([]int, 0, len(f.Lines))
+	for line := range f.Lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	var linesHit int
+	if _, err := fmt.Fprintf(out, "TN:\nSF:%s\n", f.Path); err != nil {
+		return err
+	}
+
+	var functionsHit int
+	for _, fn := range f.Functions {
+		name := fn.Record.Contract + "." + fn.Record.Name
+		if fn.Record.Hits > 0 {
+			functionsHit++
+		}
+
+		if _, err := fmt.Fprintf(out, "FN:%d,%s\nFNDA:%d,%s\n", fn.Line, name, fn.Record.Hits, name); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(out, "FNF:%d\nFNH:%d\n", len(f.Functions), functionsHit); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if f.Lines[line] > 0 {
+			linesHit++
+		}
+
+		if _, err := fmt.Fprintf(out, "DA:%d,%d\n", line, f.Lines[line]); err != nil {
+			return err
+		}
+	}
+
+	for block, branch := range f.Branches {
+		for arm, armHits := range branch.Record.Hits {
+			taken := fmt.Sprintf("%d", armHits)
+			if branch.Record.Hits[0] == 0 && branch.Record.Hits[1] == 0 {
+				// the branch itself was never reached
+				taken = "-"
+			}
+
+			if _, err := fmt.Fprintf(out, "BRDA:%d,%d,%d,%s\n", branch.Line, block, arm, taken); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(out, "BRF:%d\nBRH:%d\nLF:%d\nLH:%d\nend_of_record\n",
+		f.BranchArms, f.BranchArmsCovered, len(lines), linesHit,
+	)
+
+	return err
+}
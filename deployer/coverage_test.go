@@ -0,0 +1,167 @@
+package deployer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ctypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/etherman/sol"
+)
+
+func TestFileMappingPosToLine(t *testing.T) {
+	assert := assert.New(t)
+
+	// "ü" takes 2 bytes, solc offsets are in bytes
+	src := []byte("a\r\n// ü\nuint x;\n\nlast")
+	f := newFileMapping(src)
+
+	type lineCol [2]int
+	posToLine := func(pos int) lineCol {
+		line, col := f.PosToLine(pos)
+		return lineCol{line, col}
+	}
+
+	assert.Equal(lineCol{1, 1}, posToLine(0))
+	assert.Equal(lineCol{1, 2}, posToLine(1)) // CR stays within the line
+	assert.Equal(lineCol{2, 1}, posToLine(3))
+	assert.Equal(lineCol{3, 1}, posToLine(bytes.Index(src, []byte("uint"))))
+	assert.Equal(lineCol{3, 7}, posToLine(bytes.Index(src, []byte(";"))))
+	assert.Equal(lineCol{4, 1}, posToLine(bytes.Index(src, []byte("\n\n"))+1))
+	assert.Equal(lineCol{5, 5}, posToLine(len(src)))
+	assert.Equal(lineCol{-1, -1}, posToLine(len(src)+1))
+	assert.Equal(lineCol{-1, -1}, posToLine(-1))
+
+	long := newFileMapping([]byte(strings.Repeat("x", 1<<20) + "\ny"))
+	line, col := long.PosToLine(1<<20 + 1)
+	assert.Equal(2, line)
+	assert.Equal(1, col)
+}
+
+func BenchmarkFileMappingPosToLine(b *testing.B) {
+	for _, lines := range []int{1000, 100000} {
+		src := flattenedSource(lines)
+		f := newFileMapping(src)
+
+		b.Run(fmt.Sprintf("lines=%d", lines), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f.PosToLine(i % len(src))
+			}
+		})
+	}
+}
+
+func BenchmarkNewFileMapping(b *testing.B) {
+	for _, lines := range []int{1000, 100000} {
+		src := flattenedSource(lines)
+
+		b.Run(fmt.Sprintf("lines=%d", lines), func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for i := 0; i < b.N; i++ {
+				newFileMapping(src)
+			}
+		})
+	}
+}
+
+// flattenedSource resembles a big flattened contract with CRLF line endings.
+func flattenedSource(lines int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&buf, "        balances[msg.sender] = balances[msg.sender] + %d; // ünïcode\r\n", i)
+	}
+
+	return buf.Bytes()
+}
+
+func TestUnwrapCoverageRevert(t *testing.T) {
+	assert := assert.New(t)
+
+	contract := &sol.Contract{
+		CoverageEventIDs: map[string]uint64{"Base": 3, "Token": 7},
+	}
+
+	errorName, errorABI := NewCoverageRevertError(7)
+	original := []byte{0x82, 0xb4, 0x29, 0x00}
+
+	data, err := errorABI.Errors[errorName].Inputs.Pack(uint64(10), uint64(20), uint64(1), original)
+	if !assert.NoError(err) {
+		return
+	}
+	errorID := errorABI.Errors[errorName].ID
+	data = append(errorID[:4:4], data...)
+
+	location, unwrapped, ok := unwrapCoverageRevert(contract, data)
+	assert.True(ok)
+	assert.Equal(uint64(10), location.Start)
+	assert.Equal(uint64(20), location.End)
+	assert.Equal(uint64(1), location.File)
+	assert.Equal(original, unwrapped)
+
+	// revert data of other errors is kept as is
+	_, _, ok = unwrapCoverageRevert(contract, original)
+	assert.False(ok)
+
+	_, _, ok = unwrapCoverageRevert(contract, data[:4])
+	assert.False(ok)
+}
+
+func TestContractCoverageEvents(t *testing.T) {
+	srcDir := t.TempDir()
+
+	// Token inherits Base, so its code emits coverage events of both contracts
+	basePath := filepath.Join(srcDir, "Base.sol")
+	require.NoError(t, ioutil.WriteFile(basePath, []byte("contract Base {\n    function a() {}\n}\n"), 0644))
+
+	tokenPath := filepath.Join(srcDir, "Token.sol")
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("contract Token is Base {\n    function b() {}\n}\n"), 0644))
+
+	// file indexes refer to the source list of the build, where Token.sol comes second
+	contract := &sol.Contract{
+		Name:             "Token",
+		Coverage:         true,
+		AllPaths:         []string{basePath, tokenPath},
+		FileIndex:        1,
+		Statements:       [][]int{{20, 15, 0}, {29, 15, 1}},
+		CoverageEventIDs: map[string]uint64{"Base": 3, "Token": 7},
+	}
+
+	deployedName, deployedABI := NewCoverageMarkerEvent(7)
+	events := contractCoverageEvents(contract, deployedABI.Events[deployedName])
+	require.Len(t, events, 2)
+
+	baseName, baseABI := NewCoverageMarkerEvent(3)
+	baseEvent := baseABI.Events[baseName]
+	assert.Equal(t, baseEvent.ID, events[baseEvent.ID].ID)
+
+	c := NewCoverageDataCollector(CoverageModeCount)
+	loadCoverageStatements(c, contract)
+
+	emit := func(event abi.Event, start, end, file uint64) {
+		data, err := event.Inputs.Pack(start, end, file)
+		require.NoError(t, err)
+
+		ethLog := &ctypes.Log{Topics: []common.Hash{event.ID}, Data: data}
+		eventABI, ok := events[ethLog.Topics[0]]
+		require.True(t, ok)
+		require.NoError(t, c.CollectCoverageEvent(contract.Name, eventABI, ethLog))
+	}
+
+	// the inherited statement is hit in Base.sol, not in the file of the contract
+	emit(baseEvent, 20, 15, 0)
+	emit(deployedABI.Events[deployedName], 29, 15, uint64(contract.FileIndex))
+	emit(deployedABI.Events[deployedName], 29, 15, uint64(contract.FileIndex))
+
+	out := new(bytes.Buffer)
+	require.NoError(t, c.ReportLCOV(out))
+	assert.Regexp(t, "SF:"+basePath+"\n(.+\n)*DA:2,1\n", out.String())
+	assert.Regexp(t, "SF:"+tokenPath+"\n(.+\n)*DA:2,2\n", out.String())
+}
@@ -0,0 +1,276 @@
+package deployer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CoverageThresholds sets minimal statement coverage in percent, overall and per source file or contract.
+// Zero values are not checked. File keys match full paths, path suffixes or glob patterns of them.
+type CoverageThresholds struct {
+	Total     float64            `json:"total"`
+	Files     map[string]float64 `json:"files"`
+	Contracts map[string]float64 `json:"contracts"`
+}
+
+// LoadCoverageThresholds reads thresholds from a JSON config file.
+func LoadCoverageThresholds(path string) (*CoverageThresholds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = errors.Wrap(err, "failed to read coverage thresholds")
+		return nil, err
+	}
+
+	thresholds := new(CoverageThresholds)
+	if err := json.Unmarshal(data, thresholds); err != nil {
+		err = errors.Wrapf(err, "failed to parse coverage thresholds from %s", path)
+		return nil, err
+	}
+
+	return thresholds, nil
+}
+
+// CoverageShortfall is a coverage target below its threshold.
+type CoverageShortfall struct {
+	Kind    string
+	Target  string
+	Percent float64
+	Min     float64
+}
+
+func (s CoverageShortfall) String() string {
+	return fmt.Sprintf("%s %s: %.1f%% < %.1f%%", s.Kind, s.Target, s.Percent, s.Min)
+}
+
+// fileThreshold finds the threshold for a source file, exact path matches take precedence. Other keys are
+// matched against the path and its suffixes, so relative patterns like contracts/*.sol match absolute paths.
+// Plain paths are tried before glob patterns, and longer keys before shorter ones, as more specific.
+func (t *CoverageThresholds) fileThreshold(path string) (min float64, ok bool) {
+	if min, ok = t.Files[path]; ok {
+		return min, true
+	}
+
+	patterns := make([]string, 0, len(t.Files))
+	for pattern := range t.Files {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		iGlob, jGlob := isGlobPattern(patterns[i]), isGlobPattern(patterns[j])
+		if iGlob != jGlob {
+			return jGlob
+		} else if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+
+		return patterns[i] < patterns[j]
+	})
+
+	suffixes := pathSuffixes(filepath.Clean(path))
+	for _, pattern := range patterns {
+		clean := filepath.Clean(pattern)
+
+		for _, suffix := range suffixes {
+			if suffix == clean {
+				return t.Files[pattern], true
+			} else if matched, _ := filepath.Match(clean, suffix); matched {
+				return t.Files[pattern], true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// pathSuffixes returns the path followed by its suffixes starting after each separator,
+// e.g. /src/contracts/Token.sol, src/contracts/Token.sol, contracts/Token.sol and Token.sol.
+func pathSuffixes(path string) []string {
+	suffixes := []string{path}
+
+	for idx := 0; idx < len(path)-1; idx++ {
+		if path[idx] == filepath.Separator {
+			suffixes = append(suffixes, path[idx+1:])
+		}
+	}
+
+	return suffixes
+}
+
+func (t *CoverageThresholds) check(totalCovered, total int, files []*fileCoverage) (shortfalls []CoverageShortfall) {
+	if t.Total > 0 && percent(totalCovered, total) < t.Total {
+		shortfalls = append(shortfalls, CoverageShortfall{
+			Kind:    "total",
+			Target:  "all files",
+			Percent: percent(totalCovered, total),
+			Min:     t.Total,
+		})
+	}
+
+	for _, f := range files {
+		min, ok := t.fileThreshold(f.Path)
+		if !ok || min <= 0 {
+			continue
+		}
+
+		if p := percent(f.StatementsCovered, f.Statements); p < min {
+			shortfalls = append(shortfalls, CoverageShortfall{
+				Kind:    "file",
+				Target:  f.Path,
+				Percent: p,
+				Min:     min,
+			})
+		}
+	}
+
+	return shortfalls
+}
+
+// CheckThresholds evaluates collected statement coverage against the thresholds,
+// contracts are checked by statements loaded for them, including inherited code.
+func (c *coverageDataCollector) CheckThresholds(
+	thresholds *CoverageThresholds,
+	filterNames ...string,
+) (shortfalls []CoverageShortfall, err error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	files, err := c.filesCoverage(filterNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	var total, totalCovered int
+	for _, f := range files {
+		total += f.Statements
+		totalCovered += f.StatementsCovered
+	}
+
+	shortfalls = thresholds.check(totalCovered, total, files)
+
+	contracts := make(map[string][2]int)
+	for desc, count := range c.statements {
+		counts := contracts[desc.ContractName]
+		counts[1]++
+		if count > 0 {
+			counts[0]++
+		}
+
+		contracts[desc.ContractName] = counts
+	}
+
+	names := make([]string, 0, len(thresholds.Contracts))
+	for name := range thresholds.Contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		counts, ok := contracts[name]
+		if !ok || thresholds.Contracts[name] <= 0 {
+			continue
+		}
+
+		if p := percent(counts[0], counts[1]); p < thresholds.Contracts[name] {
+			shortfalls = append(shortfalls, CoverageShortfall{
+				Kind:    "contract",
+				Target:  name,
+				Percent: p,
+				Min:     thresholds.Contracts[name],
+			})
+		}
+	}
+
+	return shortfalls, nil
+}
+
+// CheckLCOVThresholds merges LCOV tracefiles and evaluates line coverage against the total and per-file
+// thresholds. Tracefiles have no statements per contract, so contract thresholds are not checked.
+func CheckLCOVThresholds(thresholds *CoverageThresholds, tracefiles ...io.Reader) ([]CoverageShortfall, error) {
+	lines := make(map[string]map[int]int)
+
+	for _, tracefile := range tracefiles {
+		if err := readLCOVLines(tracefile, lines); err != nil {
+			return nil, err
+		}
+	}
+
+	var total, totalCovered int
+	files := make([]*fileCoverage, 0, len(lines))
+	for path, fileLines := range lines {
+		f := &fileCoverage{
+			Path: path,
+		}
+
+		for _, hits := range fileLines {
+			f.Statements++
+			if hits > 0 {
+				f.StatementsCovered++
+			}
+		}
+
+		total += f.Statements
+		totalCovered += f.StatementsCovered
+		files = append(files, f)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return thresholds.check(totalCovered, total, files), nil
+}
+
+// readLCOVLines sums hits of DA records by source file and line.
+func readLCOVLines(tracefile io.Reader, lines map[string]map[int]int) error {
+	var path string
+
+	scanner := bufio.NewScanner(tracefile)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			path = strings.TrimPrefix(line, "SF:")
+			if lines[path] == nil {
+				lines[path] = make(map[int]int)
+			}
+		case strings.HasPrefix(line, "DA:"):
+			if len(path) == 0 {
+				return errors.New("LCOV line record outside of source file")
+			}
+
+			parts := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(parts) < 2 {
+				return errors.Errorf("malformed LCOV line record: %s", line)
+			}
+
+			lineNum, err := strconv.Atoi(parts[0])
+			if err != nil {
+				return errors.Errorf("malformed LCOV line record: %s", line)
+			}
+
+			hits, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return errors.Errorf("malformed LCOV line record: %s", line)
+			}
+
+			lines[path][lineNum] += hits
+		case line == "end_of_record":
+			path = ""
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,84 @@
+package deployer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileThreshold(t *testing.T) {
+	thresholds := &CoverageThresholds{
+		Files: map[string]float64{
+			"/src/contracts/Vault.sol": 95,
+			"contracts/*.sol":          80,
+			"contracts/lib/*.sol":      60,
+			"Token.sol":                90,
+			"./mocks/Mock.sol":         10,
+		},
+	}
+
+	testCases := []struct {
+		path string
+		min  float64
+		ok   bool
+	}{
+		{"/src/contracts/Vault.sol", 95, true},
+		{"/src/contracts/Token.sol", 90, true},
+		{"/src/contracts/Pool.sol", 80, true},
+		{"contracts/Pool.sol", 80, true},
+		{"/src/contracts/lib/Math.sol", 60, true},
+		{"/src/mocks/Mock.sol", 10, true},
+		{"/src/mocks/Other.sol", 0, false},
+		{"/src/othercontracts/Pool.sol", 0, false},
+	}
+
+	for _, tc := range testCases {
+		min, ok := thresholds.fileThreshold(tc.path)
+		assert.Equal(t, tc.ok, ok, tc.path)
+		assert.Equal(t, tc.min, min, tc.path)
+	}
+}
+
+func TestCheckLCOVThresholds(t *testing.T) {
+	tracefiles := []string{
+		"TN:\nSF:/src/contracts/Token.sol\nDA:1,1\nDA:2,0\nDA:3,2\nDA:4,0\nend_of_record\n" +
+			"SF:/src/contracts/lib/Math.sol\nDA:1,1\nDA:2,1\nend_of_record\n",
+		// hits of the same lines are summed across tracefiles
+		"TN:\nSF:/src/contracts/Token.sol\nDA:2,1\nend_of_record\n",
+	}
+
+	thresholds := &CoverageThresholds{
+		Total: 90,
+		Files: map[string]float64{
+			"contracts/*.sol":     80,
+			"contracts/lib/*.sol": 50,
+		},
+		Contracts: map[string]float64{
+			"Token": 100,
+		},
+	}
+
+	// contract thresholds are not checked, as tracefiles have no contracts
+	shortfalls, err := CheckLCOVThresholds(thresholds, strings.NewReader(tracefiles[0]), strings.NewReader(tracefiles[1]))
+	require.NoError(t, err)
+
+	assert.Equal(t, []CoverageShortfall{
+		{Kind: "total", Target: "all files", Percent: percent(5, 6), Min: 90},
+		{Kind: "file", Target: "/src/contracts/Token.sol", Percent: 75, Min: 80},
+	}, shortfalls)
+
+	thresholds.Total = 80
+	thresholds.Files["contracts/*.sol"] = 75
+
+	shortfalls, err = CheckLCOVThresholds(thresholds, strings.NewReader(tracefiles[0]), strings.NewReader(tracefiles[1]))
+	require.NoError(t, err)
+	assert.Empty(t, shortfalls)
+
+	_, err = CheckLCOVThresholds(thresholds, strings.NewReader("DA:1,1\n"))
+	assert.Error(t, err)
+
+	_, err = CheckLCOVThresholds(thresholds, strings.NewReader("SF:a.sol\nDA:x,1\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,593 @@
+package deployer
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/InjectiveLabs/etherman/sol"
+)
+
+type structLogTrace struct {
+	Failed     bool        `json:"failed"`
+	StructLogs []structLog `json:"structLogs"`
+}
+
+type structLog struct {
+	PC      uint64   `json:"pc"`
+	Op      string   `json:"op"`
+	Gas     uint64   `json:"gas"`
+	GasCost uint64   `json:"gasCost"`
+	Depth   int      `json:"depth"`
+	Stack   []string `json:"stack"`
+}
+
+// structLoggerConfig keeps the stack only, it's needed to follow call targets.
+var structLoggerConfig = map[string]interface{}{
+	"disableStorage":   true,
+	"enableMemory":     false,
+	"enableReturnData": false,
+}
+
+// loadCoverageStatements registers all statements, branches and functions of the contract in the collector,
+// so statements that were never hit are reported too.
+func loadCoverageStatements(agent CoverageDataCollector, contract *sol.Contract) {
+	if err := agent.LoadContract(contract); err != nil {
+		log.WithError(err).Errorln("failed to open referenced dependecies for coverage reporting")
+	}
+
+	for _, statement := range contract.Statements {
+		if statement[0] < 0 || statement[1] < 0 || statement[2] < 0 {
+			continue
+		}
+
+		agent.AddStatement(contract.Name,
+			uint64(statement[0]),
+			uint64(statement[1]),
+			uint64(statement[2]),
+		)
+	}
+
+	for _, branch := range contract.Branches {
+		if branch.Start < 0 || branch.Length < 0 || branch.File < 0 {
+			continue
+		}
+
+		agent.AddBranch(contract.Name, branch.Kind,
+			uint64(branch.Start),
+			uint64(branch.Length),
+			uint64(branch.File),
+		)
+	}
+
+	for _, fn := range contract.Functions {
+		if fn.Start < 0 || fn.Length < 0 || fn.File < 0 {
+			continue
+		}
+
+		agent.AddFunction(contract.Name, fn)
+	}
+}
+
+// collectTxTraceCoverage replays the mined transaction and reports executed statements of the contract.
+// Set deployment if the transaction deployed the contract, so the constructor code is mapped.
+func (d *deployer) collectTxTraceCoverage(
+	agent CoverageDataCollector,
+	contract *sol.Contract,
+	txHash common.Hash,
+	deployment bool,
+) error {
+	mapper, err := newTraceCoverageMapper(contract)
+	if err != nil {
+		return err
+	}
+
+	client, err := d.Backend()
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancelFn := context.WithTimeout(context.Background(), d.options.CallTimeout)
+	defer cancelFn()
+
+	var trace structLogTrace
+	if err := client.TraceTransaction(callCtx, txHash, structLoggerConfig, &trace); err != nil {
+		err = errors.Wrap(err, "failed to trace transaction")
+		return err
+	}
+
+	root := mapper.runtime
+	if deployment {
+		root = mapper.creation
+	}
+
+	mapper.replay(trace.StructLogs, root)
+
+	return mapper.report(agent)
+}
+
+// collectCallTraceCoverage traces the call at the given block and reports executed statements of
+// the contract, so calls remain calls while still being covered.
+func (d *deployer) collectCallTraceCoverage(
+	agent CoverageDataCollector,
+	contract *sol.Contract,
+	msg ethereum.CallMsg,
+	blockNumber *big.Int,
+) error {
+	mapper, err := newTraceCoverageMapper(contract)
+	if err != nil {
+		return err
+	}
+
+	client, err := d.Backend()
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancelFn := context.WithTimeout(context.Background(), d.options.CallTimeout)
+	defer cancelFn()
+
+	var trace structLogTrace
+	if err := client.TraceCall(callCtx, msg, blockNumber, structLoggerConfig, &trace); err != nil {
+		err = errors.Wrap(err, "failed to trace call")
+		return err
+	}
+
+	root := mapper.runtime
+	if msg.To == nil {
+		root = mapper.creation
+	}
+
+	mapper.replay(trace.StructLogs, root)
+
+	return mapper.report(agent)
+}
+
+// codeSourceMap maps program counters of the bytecode onto source map entries.
+type codeSourceMap struct {
+	indexes []int
+	entries []sol.SourceMapEntry
+}
+
+func newCodeSourceMap(bin, srcMap string) (*codeSourceMap, error) {
+	entries, err := sol.ParseSourceMap(srcMap)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &codeSourceMap{
+		indexes: sol.InstructionIndexes(common.FromHex(bin)),
+		entries: entries,
+	}
+
+	return m, nil
+}
+
+func (m *codeSourceMap) entryAt(pc uint64) (entry sol.SourceMapEntry, ok bool) {
+	if pc >= uint64(len(m.indexes)) {
+		return entry, false
+	}
+
+	idx := m.indexes[pc]
+	if idx >= len(m.entries) {
+		return entry, false
+	}
+
+	return m.entries[idx], true
+}
+
+type traceCoverageMapper struct {
+	contract *sol.Contract
+	runtime  *codeSourceMap
+	creation *codeSourceMap
+
+	// innermost caches statement index by source range of an instruction
+	innermost map[[3]int]int
+	hits      map[int]int
+
+	// jumps maps source range of a deciding JUMPI onto the branch index
+	jumps      map[[3]int]int
+	branchHits map[int]*[2]int
+
+	// innermostFunction caches function index by source range of an instruction
+	innermostFunction map[[3]int]int
+	functionHits      map[int]int
+
+	// gas used by instructions of statements and functions, excluding calls back into the contract
+	gas         map[int]uint64
+	functionGas map[int]uint64
+}
+
+func newTraceCoverageMapper(contract *sol.Contract) (*traceCoverageMapper, error) {
+	if !contract.TraceCoverage {
+		return nil, errors.New("contract was not compiled with source maps for coverage")
+	}
+
+	runtime, err := newCodeSourceMap(contract.BinRuntime, contract.SrcMapRuntime)
+	if err != nil {
+		err = errors.Wrap(err, "failed to parse runtime source map")
+		return nil, err
+	}
+
+	creation, err := newCodeSourceMap(contract.Bin, contract.SrcMap)
+	if err != nil {
+		err = errors.Wrap(err, "failed to parse source map")
+		return nil, err
+	}
+
+	m := &traceCoverageMapper{
+		contract:   contract,
+		runtime:    runtime,
+		creation:   creation,
+		innermost:  make(map[[3]int]int),
+		hits:       make(map[int]int),
+		jumps:      make(map[[3]int]int, len(contract.Branches)),
+		branchHits: make(map[int]*[2]int),
+
+		innermostFunction: make(map[[3]int]int),
+		functionHits:      make(map[int]int),
+
+		gas:         make(map[int]uint64),
+		functionGas: make(map[int]uint64),
+	}
+
+	for idx, branch := range contract.Branches {
+		m.jumps[[3]int{branch.Jump[0], branch.Jump[1], branch.File}] = idx
+	}
+
+	return m, nil
+}
+
+type traceFrame struct {
+	code          *codeSourceMap
+	lastStatement int
+
+	// functions and modifiers entered within the current internal function call
+	entered map[int]struct{}
+
+	// state of callers when internal functions were entered
+	jumps []traceCaller
+
+	// statement and function of the caller frame that made the call, or -1
+	callerStatement int
+	callerFunction  int
+
+	// gas attributed within this frame and frames it called
+	gasUsed uint64
+}
+
+type traceCaller struct {
+	lastStatement int
+	entered       map[int]struct{}
+}
+
+func newTraceFrame(code *codeSourceMap) *traceFrame {
+	return &traceFrame{
+		code:            code,
+		lastStatement:   -1,
+		entered:         make(map[int]struct{}),
+		callerStatement: -1,
+		callerFunction:  -1,
+	}
+}
+
+// stepGasCosts computes gas used by each step. Calls and creations use the difference with the next step
+// of the same frame, so gas spent by the callee is included, as struct logs report the gas sent instead.
+// The last step of a frame uses the reported cost.
+func stepGasCosts(logs []structLog) []uint64 {
+	costs := make([]uint64, len(logs))
+
+	// next step index by depth, steps of frames that returned are reset
+	next := make([]int, 0, 16)
+	for idx := len(logs) - 1; idx >= 0; idx-- {
+		depth := logs[idx].Depth
+		for len(next) <= depth {
+			next = append(next, -1)
+		}
+
+		for d := depth + 1; d < len(next); d++ {
+			next[d] = -1
+		}
+
+		costs[idx] = logs[idx].GasCost
+		if n := next[depth]; n >= 0 && logs[idx].Gas >= logs[n].Gas {
+			costs[idx] = logs[idx].Gas - logs[n].Gas
+		}
+
+		next[depth] = idx
+	}
+
+	return costs
+}
+
+func subGas(gas, used uint64) uint64 {
+	if used > gas {
+		return 0
+	}
+
+	return gas - used
+}
+
+// replay walks the struct logs, following calls into the contract address. A statement is hit
+// each time the execution enters it from another statement, returning from an internal function
+// into the calling statement doesn't count. A function or modifier is hit once per call.
+// Gas of each step is attributed to the innermost statement and function, calls into other contracts
+// are attributed to the calling statement, unless they call back into the contract.
+func (m *traceCoverageMapper) replay(logs []structLog, root *codeSourceMap) {
+	frames := []*traceFrame{newTraceFrame(root)}
+	costs := stepGasCosts(logs)
+
+	var pending *traceFrame
+	for logIdx, l := range logs {
+		if l.Depth > len(frames) {
+			if pending == nil {
+				pending = newTraceFrame(nil)
+			}
+
+			frames = append(frames, pending)
+		}
+
+		for l.Depth > 0 && l.Depth < len(frames) {
+			m.returnGas(frames[len(frames)-1], frames[len(frames)-2])
+			frames = frames[:len(frames)-1]
+		}
+
+		pending = nil
+		frame := frames[len(frames)-1]
+
+		switch l.Op {
+		case "CALL", "CALLCODE", "DELEGATECALL", "STATICCALL", "CREATE", "CREATE2":
+			pending = newTraceFrame(nil)
+			if l.Op != "CREATE" && l.Op != "CREATE2" && len(l.Stack) >= 2 &&
+				common.HexToAddress(l.Stack[len(l.Stack)-2]) == m.contract.Address {
+				pending.code = m.runtime
+			}
+		}
+
+		if frame.code == nil {
+			continue
+		}
+
+		entry, ok := frame.code.entryAt(l.PC)
+		if !ok || entry.File < 0 {
+			continue
+		}
+
+		statement := m.statementAt(entry)
+		if statement >= 0 && statement != frame.lastStatement {
+			m.hits[statement]++
+			frame.lastStatement = statement
+		}
+
+		fn := m.functionAt(entry)
+		if fn >= 0 {
+			if _, ok := frame.entered[fn]; !ok {
+				m.functionHits[fn]++
+				frame.entered[fn] = struct{}{}
+			}
+		}
+
+		if statement >= 0 {
+			m.gas[statement] += costs[logIdx]
+			frame.gasUsed += costs[logIdx]
+		}
+
+		if fn >= 0 {
+			m.functionGas[fn] += costs[logIdx]
+		}
+
+		if pending != nil {
+			pending.callerStatement = statement
+			pending.callerFunction = fn
+		}
+
+		if l.Op == "JUMPI" && logIdx+1 < len(logs) {
+			m.collectBranch(entry, l.PC, logs[logIdx+1].PC)
+		}
+
+		if l.Op != "JUMP" {
+			continue
+		}
+
+		switch entry.Jump {
+		case 'i':
+			frame.jumps = append(frame.jumps, traceCaller{
+				lastStatement: frame.lastStatement,
+				entered:       frame.entered,
+			})
+
+			// the external function wrapper calls into the body of the same function,
+			// so a new call begins only once any statement was executed
+			if frame.lastStatement >= 0 {
+				frame.entered = make(map[int]struct{})
+			}
+		case 'o':
+			if len(frame.jumps) > 0 {
+				caller := frame.jumps[len(frame.jumps)-1]
+				frame.lastStatement = caller.lastStatement
+				frame.entered = caller.entered
+				frame.jumps = frame.jumps[:len(frame.jumps)-1]
+			}
+		}
+	}
+}
+
+// returnGas moves gas used by the returning frame out of the calling statement, if it was attributed
+// within the frame already, e.g. a call back into the contract.
+func (m *traceCoverageMapper) returnGas(frame, caller *traceFrame) {
+	if frame.callerStatement < 0 {
+		caller.gasUsed += frame.gasUsed
+		return
+	}
+
+	m.gas[frame.callerStatement] = subGas(m.gas[frame.callerStatement], frame.gasUsed)
+	if frame.callerFunction >= 0 {
+		m.functionGas[frame.callerFunction] = subGas(m.functionGas[frame.callerFunction], frame.gasUsed)
+	}
+}
+
+// functionAt finds the function or modifier containing source range of the instruction,
+// returns -1 if instruction is not a part of any (e.g. function dispatch).
+func (m *traceCoverageMapper) functionAt(entry sol.SourceMapEntry) int {
+	key := [3]int{entry.Start, entry.Length, entry.File}
+	if idx, ok := m.innermostFunction[key]; ok {
+		return idx
+	}
+
+	found := -1
+	for idx, fn := range m.contract.Functions {
+		if fn.File != entry.File {
+			continue
+		} else if fn.Start > entry.Start || fn.Start+fn.Length < entry.Start+entry.Length {
+			continue
+		}
+
+		if found < 0 || fn.Length < m.contract.Functions[found].Length {
+			found = idx
+		}
+	}
+
+	m.innermostFunction[key] = found
+	return found
+}
+
+// statementAt finds the innermost statement containing source range of the instruction,
+// returns -1 if instruction is not a part of any statement (e.g. function dispatch).
+func (m *traceCoverageMapper) statementAt(entry sol.SourceMapEntry) int {
+	key := [3]int{entry.Start, entry.Length, entry.File}
+	if idx, ok := m.innermost[key]; ok {
+		return idx
+	}
+
+	found := -1
+	for idx, statement := range m.contract.Statements {
+		if statement[2] != entry.File {
+			continue
+		} else if statement[0] > entry.Start || statement[0]+statement[1] < entry.Start+entry.Length {
+			continue
+		}
+
+		if found < 0 || statement[1] < m.contract.Statements[found][1] {
+			found = idx
+		}
+	}
+
+	m.innermost[key] = found
+	return found
+}
+
+// collectBranch resolves the outcome of a branch from its JUMPI instruction. In the legacy codegen
+// the jump skips the true arm of if statements, the false arm of conditionals, and the failure
+// of require and assert.
+func (m *traceCoverageMapper) collectBranch(entry sol.SourceMapEntry, pc, nextPC uint64) {
+	idx, ok := m.jumps[[3]int{entry.Start, entry.Length, entry.File}]
+	if !ok {
+		return
+	}
+
+	jumped := nextPC != pc+1
+
+	arm := 0
+	switch m.contract.Branches[idx].Kind {
+	case sol.BranchKindIf:
+		if jumped {
+			arm = 1
+		}
+	default:
+		if !jumped {
+			arm = 1
+		}
+	}
+
+	if m.branchHits[idx] == nil {
+		m.branchHits[idx] = new([2]int)
+	}
+
+	m.branchHits[idx][arm]++
+}
+
+func (m *traceCoverageMapper) report(agent CoverageDataCollector) error {
+	for idx, hits := range m.hits {
+		statement := m.contract.Statements[idx]
+
+		err := agent.CollectStatementHits(m.contract.Name,
+			uint64(statement[0]),
+			uint64(statement[1]),
+			uint64(statement[2]),
+			hits,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx, hits := range m.functionHits {
+		fn := m.contract.Functions[idx]
+
+		err := agent.CollectFunctionHits(m.contract.Name,
+			uint64(fn.Start),
+			uint64(fn.Length),
+			uint64(fn.File),
+			hits,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx, gas := range m.gas {
+		statement := m.contract.Statements[idx]
+
+		err := agent.CollectStatementGas(m.contract.Name,
+			uint64(statement[0]),
+			uint64(statement[1]),
+			uint64(statement[2]),
+			gas,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx, gas := range m.functionGas {
+		fn := m.contract.Functions[idx]
+
+		err := agent.CollectFunctionGas(m.contract.Name,
+			uint64(fn.Start),
+			uint64(fn.Length),
+			uint64(fn.File),
+			gas,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx, hits := range m.branchHits {
+		branch := m.contract.Branches[idx]
+
+		for arm, armHits := range hits {
+			if armHits == 0 {
+				continue
+			}
+
+			err := agent.CollectBranchHits(m.contract.Name,
+				uint64(branch.Start),
+				uint64(branch.Length),
+				uint64(branch.File),
+				arm,
+				armHits,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/etherman/sol"
+)
+
+var traceTestAddress = common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+
+// newTraceTestContract has a function spanning 0:100 with three statements, where 20:10 is nested in 10:50.
+// Runtime code is PUSH1 0 PUSH1 0 ADD JUMPDEST CALL STOP, the last instruction has no source.
+func newTraceTestContract() *sol.Contract {
+	return &sol.Contract{
+		Name:          "Test",
+		Address:       traceTestAddress,
+		TraceCoverage: true,
+		BinRuntime:    "60006000015bf100",
+		SrcMapRuntime: "0:100:0;20:5;;45:2;12:3;::-1",
+		Statements: [][]int{
+			{10, 50, 0},
+			{20, 10, 0},
+			{40, 15, 0},
+		},
+		Functions: []sol.Function{
+			{Kind: "function", Name: "run", Contract: "Test", Start: 0, Length: 100, File: 0},
+		},
+	}
+}
+
+// traceTestLogs executes the runtime code, calling back into the contract at the CALL.
+func traceTestLogs() []structLog {
+	callStack := []string{traceTestAddress.Hex(), "0x5208"}
+
+	return []structLog{
+		{PC: 0, Op: "PUSH1", Gas: 1000, GasCost: 3, Depth: 1},
+		{PC: 2, Op: "PUSH1", Gas: 997, GasCost: 3, Depth: 1},
+		{PC: 4, Op: "ADD", Gas: 994, GasCost: 3, Depth: 1},
+		{PC: 5, Op: "JUMPDEST", Gas: 991, GasCost: 1, Depth: 1},
+		{PC: 6, Op: "CALL", Gas: 990, GasCost: 100, Depth: 1, Stack: callStack},
+		{PC: 2, Op: "PUSH1", Gas: 800, GasCost: 3, Depth: 2},
+		{PC: 4, Op: "ADD", Gas: 797, GasCost: 3, Depth: 2},
+		{PC: 7, Op: "STOP", Gas: 794, GasCost: 0, Depth: 2},
+		{PC: 7, Op: "STOP", Gas: 900, GasCost: 0, Depth: 1},
+	}
+}
+
+func TestTraceCoverageStatementAt(t *testing.T) {
+	m, err := newTraceCoverageMapper(newTraceTestContract())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, m.statementAt(sol.SourceMapEntry{Start: 20, Length: 5, File: 0}))
+	assert.Equal(t, 0, m.statementAt(sol.SourceMapEntry{Start: 12, Length: 3, File: 0}))
+	assert.Equal(t, 2, m.statementAt(sol.SourceMapEntry{Start: 45, Length: 2, File: 0}))
+	assert.Equal(t, -1, m.statementAt(sol.SourceMapEntry{Start: 0, Length: 100, File: 0}))
+	assert.Equal(t, -1, m.statementAt(sol.SourceMapEntry{Start: 20, Length: 5, File: 1}))
+
+	// cached lookups return the same
+	assert.Equal(t, 1, m.statementAt(sol.SourceMapEntry{Start: 20, Length: 5, File: 0}))
+
+	assert.Equal(t, 0, m.functionAt(sol.SourceMapEntry{Start: 20, Length: 5, File: 0}))
+	assert.Equal(t, -1, m.functionAt(sol.SourceMapEntry{Start: 90, Length: 20, File: 0}))
+}
+
+func TestTraceCoverageReplay(t *testing.T) {
+	m, err := newTraceCoverageMapper(newTraceTestContract())
+	require.NoError(t, err)
+
+	entry, ok := m.runtime.entryAt(3)
+	require.True(t, ok, "PUSH data maps to the PUSH instruction")
+	assert.Equal(t, sol.SourceMapEntry{Start: 20, Length: 5, File: 0}, entry)
+
+	_, ok = m.runtime.entryAt(8)
+	assert.False(t, ok)
+
+	m.replay(traceTestLogs(), m.runtime)
+
+	// the nested statement is hit in both frames, consecutive instructions count once
+	assert.Equal(t, map[int]int{0: 1, 1: 2, 2: 1}, m.hits)
+	assert.Equal(t, map[int]int{0: 2}, m.functionHits)
+
+	// calls into other contracts are not followed
+	m, err = newTraceCoverageMapper(newTraceTestContract())
+	require.NoError(t, err)
+
+	logs := traceTestLogs()
+	logs[4].Stack = []string{"0x70997970C51812dc3A010C7d01b50e0d17dc79C8", "0x5208"}
+	m.replay(logs, m.runtime)
+
+	assert.Equal(t, map[int]int{0: 1, 1: 1, 2: 1}, m.hits)
+	assert.Equal(t, map[int]int{0: 1}, m.functionHits)
+
+	contract := newTraceTestContract()
+	contract.TraceCoverage = false
+	_, err = newTraceCoverageMapper(contract)
+	assert.Error(t, err)
+}
+
+func TestTraceCoverageCollectBranch(t *testing.T) {
+	contract := newTraceTestContract()
+	contract.Branches = []sol.Branch{
+		{Kind: sol.BranchKindIf, Start: 10, Length: 50, File: 0, Jump: [2]int{12, 3}},
+		{Kind: sol.BranchKindRequire, Start: 40, Length: 15, File: 0, Jump: [2]int{45, 2}},
+	}
+
+	m, err := newTraceCoverageMapper(contract)
+	require.NoError(t, err)
+
+	ifJump := sol.SourceMapEntry{Start: 12, Length: 3, File: 0}
+	requireJump := sol.SourceMapEntry{Start: 45, Length: 2, File: 0}
+
+	// the if jumps over the true arm when the condition is false
+	m.collectBranch(ifJump, 10, 11)
+	m.collectBranch(ifJump, 10, 11)
+	m.collectBranch(ifJump, 10, 42)
+
+	// the require jumps over the failure when the condition holds
+	m.collectBranch(requireJump, 20, 64)
+
+	// JUMPI of other source ranges are not branches
+	m.collectBranch(sol.SourceMapEntry{Start: 20, Length: 5, File: 0}, 30, 31)
+
+	assert.Equal(t, map[int]*[2]int{
+		0: {2, 1},
+		1: {1, 0},
+	}, m.branchHits)
+}
@@ -28,7 +28,9 @@ type option func(o *options) error
 
 func New(opts ...option) (Deployer, error) {
 	d := &deployer{
-		options: defaultOptions(),
+		options:     defaultOptions(),
+		compiled:    make(map[string]*sol.Contract),
+		compiledMux: new(sync.Mutex),
 	}
 
 	for _, o := range opts {
@@ -96,6 +98,11 @@ type Deployer interface {
 		methodInputMapper AbiMethodInputMapperFunc,
 	) (txHash common.Hash, abiPackedArgs []byte, err error)
 
+	Transfer(
+		ctx context.Context,
+		transferOpts TransferOpts,
+	) (txHash common.Hash, err error)
+
 	Call(
 		ctx context.Context,
 		callOpts ContractCallOpts,
@@ -103,6 +110,38 @@ type Deployer interface {
 		methodInputMapper AbiMethodInputMapperFunc,
 	) (output []interface{}, outputAbi abi.Arguments, err error)
 
+	CallBatch(
+		ctx context.Context,
+		batchOpts ContractCallBatchOpts,
+		calls []ContractBatchCall,
+	) (results []ContractBatchCallResult, err error)
+
+	EstimateDeploy(
+		ctx context.Context,
+		deployOpts ContractDeployOpts,
+		constructorInputMapper AbiMethodInputMapperFunc,
+	) (estimate *GasEstimate, err error)
+
+	EstimateTx(
+		ctx context.Context,
+		txOpts ContractTxOpts,
+		methodName string,
+		methodInputMapper AbiMethodInputMapperFunc,
+	) (estimate *GasEstimate, err error)
+
+	SimulateDeploy(
+		ctx context.Context,
+		deployOpts ContractDeployOpts,
+		constructorInputMapper AbiMethodInputMapperFunc,
+	) (result *SimulationResult, err error)
+
+	SimulateTx(
+		ctx context.Context,
+		txOpts ContractTxOpts,
+		methodName string,
+		methodInputMapper AbiMethodInputMapperFunc,
+	) (result *SimulationResult, err error)
+
 	Logs(
 		ctx context.Context,
 		logsOpts ContractLogsOpts,
@@ -110,6 +149,12 @@ type Deployer interface {
 		eventName string,
 		eventUnpacker ContractLogUnpackFunc,
 	) (events []interface{}, err error)
+
+	Trace(
+		ctx context.Context,
+		traceOpts ContractTraceOpts,
+		txHash common.Hash,
+	) (root *CallFrame, err error)
 }
 
 type deployer struct {
@@ -118,6 +163,11 @@ type deployer struct {
 	client   *Client
 
 	initClientOnce sync.Once
+
+	// compiled memoizes contracts built during the lifetime of the deployer,
+	// so long-running sessions don't hit solc or the build cache repeatedly.
+	compiled    map[string]*sol.Contract
+	compiledMux *sync.Mutex
 }
 
 type options struct {
@@ -125,16 +175,20 @@ type options struct {
 	TxTimeout   time.Duration
 	CallTimeout time.Duration
 
-	SignerType     SignerType
-	GasPrice       *big.Int
-	GasLimit       uint64
-	EVMRPCEndpoint string
+	SignerType       SignerType
+	GasPrice         *big.Int
+	GasLimit         uint64
+	GasMultiplier    float64
+	GasBuffer        uint64
+	EVMRPCEndpoint   string
+	MulticallAddress common.Address
 
 	NoCache          bool
 	BuildCacheDir    string
 	SolcPath         string
 	SolcPathSet      bool
 	EnableCoverage   bool
+	CoverageStrategy CoverageStrategy
 	SolcAllowedPaths []string
 }
 
@@ -144,14 +198,17 @@ func defaultOptions() *options {
 		TxTimeout:   30 * time.Second,
 		CallTimeout: 10 * time.Second,
 
-		SignerType:     SignerEIP155,
-		GasPrice:       new(big.Int),
-		GasLimit:       1000000,
-		EVMRPCEndpoint: "http://localhost:8545",
-
-		NoCache:        false,
-		BuildCacheDir:  "build",
-		EnableCoverage: false,
+		SignerType:       SignerEIP155,
+		GasPrice:         new(big.Int),
+		GasLimit:         1000000,
+		GasMultiplier:    1.0,
+		EVMRPCEndpoint:   "http://localhost:8545",
+		MulticallAddress: Multicall3Address,
+
+		NoCache:          false,
+		BuildCacheDir:    "build",
+		EnableCoverage:   false,
+		CoverageStrategy: CoverageStrategyInstrument,
 	}
 }
 
@@ -210,9 +267,11 @@ func OptionGasPrice(price *big.Int) option {
 	}
 }
 
+// OptionGasLimit sets a fixed gas limit for transactions and deployments.
+// Zero gas limit means the limit will be estimated for each transaction.
 func OptionGasLimit(gasLimit uint64) option {
 	return func(o *options) error {
-		if gasLimit < 21000 {
+		if gasLimit != 0 && gasLimit < 21000 {
 			return errors.New("gas limit too low")
 		}
 
@@ -221,6 +280,20 @@ func OptionGasLimit(gasLimit uint64) option {
 	}
 }
 
+// OptionGasEstimateAdjustment sets how estimated gas is turned into a gas limit:
+// the estimate is multiplied by the multiplier and then the buffer is added.
+func OptionGasEstimateAdjustment(multiplier float64, buffer uint64) option {
+	return func(o *options) error {
+		if multiplier < 1 {
+			return errors.New("gas estimate multiplier must not be less than 1")
+		}
+
+		o.GasMultiplier = multiplier
+		o.GasBuffer = buffer
+		return nil
+	}
+}
+
 func OptionNoCache(noCache bool) option {
 	return func(o *options) error {
 		o.NoCache = noCache
@@ -251,6 +324,15 @@ func OptionEVMRPCEndpoint(uri string) option {
 	}
 }
 
+// OptionMulticallAddress overrides the Multicall3 contract used for batched calls.
+// Zero address disables multicall and forces JSON-RPC batching.
+func OptionMulticallAddress(address common.Address) option {
+	return func(o *options) error {
+		o.MulticallAddress = address
+		return nil
+	}
+}
+
 func OptionSolcPath(dir string) option {
 	return func(o *options) error {
 		if len(dir) == 0 {
@@ -271,6 +353,20 @@ func OptionEnableCoverage(enabled bool) option {
 	}
 }
 
+// OptionCoverageStrategy sets how coverage is collected when enabled, see CoverageStrategy.
+func OptionCoverageStrategy(strategy CoverageStrategy) option {
+	return func(o *options) error {
+		switch strategy {
+		case CoverageStrategyInstrument, CoverageStrategyTrace:
+			o.CoverageStrategy = strategy
+		default:
+			return errors.Errorf("unsupported coverage strategy: %s", strategy)
+		}
+
+		return nil
+	}
+}
+
 func OptionSolcAllowedPaths(allowedPaths []string) option {
 	return func(o *options) error {
 		o.SolcAllowedPaths = allowedPaths
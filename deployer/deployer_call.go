@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/xlab/suplog"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -117,37 +118,38 @@ func (d *deployer) Call(
 		Context: callCtx,
 	}
 
-	if d.options.EnableCoverage {
-		var coverageTopic common.Hash
-		var coverageEventABI abi.Event
+	if d.coverageStrategy() == CoverageStrategyTrace {
+		if callOpts.CoverageAgent != nil {
+			loadCoverageStatements(callOpts.CoverageAgent, contract)
 
-		_, coverageEventABI, err = d.GetCoverageEventInfo(callCtx, callOpts.From, contract.Name, contract.Address)
+			input, _ := boundContract.ABI().Pack(methodName, mappedArgs...)
+			msg := ethereum.CallMsg{
+				From: callOpts.From,
+				To:   &contract.Address,
+				Data: input,
+			}
+
+			if err := d.collectCallTraceCoverage(callOpts.CoverageAgent, contract, msg, nil); err != nil {
+				log.WithError(err).Warningln("failed to collect coverage from call trace")
+			}
+		}
+	} else if d.options.EnableCoverage {
+		var coverageEvents map[common.Hash]abi.Event
+
+		_, coverageEventABI, err := d.GetCoverageEventInfo(callCtx, callOpts.From, contract.Name, contract.Address)
 		if err != ErrNoCoverage {
 			if err != nil {
 				return nil, nil, err
 			}
 
-			coverageTopic = coverageEventABI.ID
+			coverageEvents = contractCoverageEvents(contract, coverageEventABI)
 
 			if callOpts.CoverageAgent != nil {
-				if err := callOpts.CoverageAgent.LoadContract(contract); err != nil {
-					log.WithError(err).Errorln("failed to open referenced dependecies for coverage reporting")
-				}
-				for _, statement := range contract.Statements {
-					if statement[0] < 0 || statement[1] < 0 || statement[2] < 0 {
-						continue
-					}
-
-					callOpts.CoverageAgent.AddStatement(contract.Name,
-						uint64(statement[0]),
-						uint64(statement[1]),
-						uint64(statement[2]),
-					)
-				}
+				loadCoverageStatements(callOpts.CoverageAgent, contract)
 			}
 		}
 
-		if callOpts.CoverageAgent != nil && coverageTopic != noHash {
+		if callOpts.CoverageAgent != nil && len(coverageEvents) > 0 {
 			if callOpts.CoverageCall.FromPk == nil && callOpts.CoverageCall.SignerFn == nil {
 				err := errors.New("call with enabled coverage, but no signer data provided (for tx)")
 				return nil, method.Outputs, err
@@ -166,17 +168,6 @@ func (d *deployer) Call(
 
 			txHash, _, err := d.Tx(context.Background(), txOpts, methodName, methodInputMapper)
 			if err != nil {
-				if hasCoverageReport(err) {
-					if callOpts.CoverageAgent != nil {
-						coverageReportErr := callOpts.CoverageAgent.CollectCoverageRevert(contract.Name, err)
-						if coverageReportErr != nil {
-							log.WithError(coverageReportErr).Warningln("failed to collect coverage revert event")
-						}
-					}
-
-					err = trimCoverageReport(err)
-				}
-
 				return nil, method.Outputs, err
 			}
 
@@ -198,16 +189,7 @@ func (d *deployer) Call(
 
 	// a simple call
 	if err := boundContract.Call(ethCallOpts, &output, methodName, mappedArgs...); err != nil {
-		if hasCoverageReport(err) {
-			if callOpts.CoverageAgent != nil {
-				coverageReportErr := callOpts.CoverageAgent.CollectCoverageRevert(contract.Name, err)
-				if coverageReportErr != nil {
-					log.WithError(coverageReportErr).Warningln("failed to collect coverage revert event")
-				}
-			}
-
-			err = trimCoverageReport(err)
-		}
+		err = collectCoverageRevert(callOpts.CoverageAgent, contract, err)
 
 		err = errors.Wrap(err, "failed to call contract method")
 		return nil, method.Outputs, err
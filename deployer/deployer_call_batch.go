@@ -0,0 +1,331 @@
+package deployer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Multicall3Address is the canonical deployment address of Multicall3, the same on most EVM chains.
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+type ContractCallBatchOpts struct {
+	From         common.Address
+	SolSource    string
+	ContractName string
+}
+
+// AbiMethodInputMapperWithErrFunc maps method args like AbiMethodInputMapperFunc,
+// but reports a mapping failure, so it fails only the single call of a batch.
+type AbiMethodInputMapperWithErrFunc func(args abi.Arguments) ([]interface{}, error)
+
+type ContractBatchCall struct {
+	Contract          common.Address
+	MethodName        string
+	MethodInputMapper AbiMethodInputMapperWithErrFunc
+}
+
+type ContractBatchCallResult struct {
+	Contract  common.Address
+	Method    string
+	Output    []interface{}
+	OutputAbi abi.Arguments
+	Err       error
+}
+
+// CallBatch packs multiple read-only calls of the same contract ABI into a single
+// Multicall3 aggregate3 call. If no multicall contract is deployed on the chain,
+// a JSON-RPC batch of eth_call requests is used instead. Per-call failures are
+// reported in the results, err is returned only if the whole batch failed.
+func (d *deployer) CallBatch(
+	ctx context.Context,
+	batchOpts ContractCallBatchOpts,
+	calls []ContractBatchCall,
+) (results []ContractBatchCallResult, err error) {
+	solSourceFullPath, _ := filepath.Abs(batchOpts.SolSource)
+	contract := d.getCompiledContract(batchOpts.ContractName, solSourceFullPath)
+	if contract == nil {
+		log.Errorln("contract compilation failed, check logs")
+		return nil, ErrCompilationFailed
+	}
+
+	if d.options.EnableCoverage {
+		log.Warningln("coverage is not collected for batched calls")
+	}
+
+	boundContract, err := BindContract(nil, contract)
+	if err != nil {
+		log.WithField("contract", batchOpts.ContractName).WithError(err).Errorln("failed to bind contract")
+		return nil, err
+	}
+	contractABI := boundContract.ABI()
+
+	return d.callBatch(ctx, &contractABI, batchOpts.From, calls)
+}
+
+func (d *deployer) callBatch(
+	ctx context.Context,
+	contractABI *abi.ABI,
+	from common.Address,
+	calls []ContractBatchCall,
+) (results []ContractBatchCallResult, err error) {
+	results = make([]ContractBatchCallResult, len(calls))
+	calldata := make([][]byte, len(calls))
+
+	for idx, call := range calls {
+		results[idx].Contract = call.Contract
+		results[idx].Method = call.MethodName
+
+		method, ok := contractABI.Methods[call.MethodName]
+		if !ok {
+			results[idx].Err = errors.Errorf("method not found: %s", call.MethodName)
+			continue
+		}
+		results[idx].OutputAbi = method.Outputs
+
+		var mappedArgs []interface{}
+		if call.MethodInputMapper != nil {
+			if mappedArgs, err = call.MethodInputMapper(method.Inputs); err != nil {
+				results[idx].Err = errors.Wrap(err, "failed to map method args")
+				continue
+			}
+		}
+
+		packedArgs, err := method.Inputs.PackValues(mappedArgs)
+		if err != nil {
+			results[idx].Err = errors.Wrap(err, "failed to ABI-encode method args")
+			continue
+		}
+
+		calldata[idx] = append(append([]byte{}, method.ID...), packedArgs...)
+	}
+
+	client, err := d.Backend()
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancelFn := context.WithTimeout(ctx, d.options.CallTimeout)
+	defer cancelFn()
+
+	var outputs [][]byte
+	var callErrs []error
+
+	useMulticall := false
+	if d.options.MulticallAddress != (common.Address{}) {
+		code, err := client.CodeAt(callCtx, d.options.MulticallAddress, nil)
+		if err != nil {
+			log.WithError(err).Warningln("failed to check multicall contract code, falling back to JSON-RPC batch")
+		} else if len(code) > 0 {
+			useMulticall = true
+		} else {
+			log.WithField("multicall", d.options.MulticallAddress.Hex()).Debugln("no multicall contract deployed, using JSON-RPC batch")
+		}
+	}
+
+	if useMulticall {
+		outputs, callErrs, err = d.multicallAggregate3(callCtx, client, contractABI, from, calls, calldata)
+	} else {
+		outputs, callErrs, err = jsonRPCBatchCall(callCtx, client, contractABI, from, calls, calldata)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "failed to execute batched calls")
+		return nil, err
+	}
+
+	for idx := range results {
+		if calldata[idx] == nil {
+			// failed at the packing stage
+			continue
+		} else if callErrs[idx] != nil {
+			results[idx].Err = callErrs[idx]
+			continue
+		}
+
+		output, err := contractABI.Unpack(results[idx].Method, outputs[idx])
+		if err != nil {
+			results[idx].Err = errors.Wrap(err, "failed to unpack call output")
+			continue
+		}
+
+		results[idx].Output = output
+	}
+
+	return results, nil
+}
+
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+func (d *deployer) multicallAggregate3(
+	ctx context.Context,
+	client *Client,
+	contractABI *abi.ABI,
+	from common.Address,
+	calls []ContractBatchCall,
+	calldata [][]byte,
+) (outputs [][]byte, callErrs []error, err error) {
+	// indexes of calls that made it into the aggregate
+	packed := make([]int, 0, len(calls))
+	multicalls := make([]multicall3Call, 0, len(calls))
+
+	for idx := range calls {
+		if calldata[idx] == nil {
+			continue
+		}
+
+		packed = append(packed, idx)
+		multicalls = append(multicalls, multicall3Call{
+			Target:       calls[idx].Contract,
+			AllowFailure: true,
+			CallData:     calldata[idx],
+		})
+	}
+
+	outputs = make([][]byte, len(calls))
+	callErrs = make([]error, len(calls))
+	if len(multicalls) == 0 {
+		return outputs, callErrs, nil
+	}
+
+	input, err := multicall3ABI.Pack("aggregate3", multicalls)
+	if err != nil {
+		err = errors.Wrap(err, "failed to ABI-encode aggregate3 calls")
+		return nil, nil, err
+	}
+
+	multicallAddress := d.options.MulticallAddress
+	res, err := client.CallContract(ctx, ethereum.CallMsg{
+		From: from,
+		To:   &multicallAddress,
+		Data: input,
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values, err := multicall3ABI.Unpack("aggregate3", res)
+	if err != nil {
+		err = errors.Wrap(err, "failed to unpack aggregate3 output")
+		return nil, nil, err
+	}
+
+	aggregated := *abi.ConvertType(values[0], new([]multicall3Result)).(*[]multicall3Result)
+	if len(aggregated) != len(packed) {
+		err = errors.Errorf("aggregate3 returned %d results for %d calls", len(aggregated), len(packed))
+		return nil, nil, err
+	}
+
+	for i, idx := range packed {
+		if !aggregated[i].Success {
+			callErrs[idx] = DecodeRevertData(contractABI, aggregated[i].ReturnData)
+			continue
+		}
+
+		outputs[idx] = aggregated[i].ReturnData
+	}
+
+	return outputs, callErrs, nil
+}
+
+func jsonRPCBatchCall(
+	ctx context.Context,
+	client *Client,
+	contractABI *abi.ABI,
+	from common.Address,
+	calls []ContractBatchCall,
+	calldata [][]byte,
+) (outputs [][]byte, callErrs []error, err error) {
+	packed := make([]int, 0, len(calls))
+	msgs := make([]ethereum.CallMsg, 0, len(calls))
+
+	for idx := range calls {
+		if calldata[idx] == nil {
+			continue
+		}
+
+		to := calls[idx].Contract
+		packed = append(packed, idx)
+		msgs = append(msgs, ethereum.CallMsg{
+			From: from,
+			To:   &to,
+			Data: calldata[idx],
+		})
+	}
+
+	outputs = make([][]byte, len(calls))
+	callErrs = make([]error, len(calls))
+	if len(msgs) == 0 {
+		return outputs, callErrs, nil
+	}
+
+	batchOutputs, batchErrs, err := client.CallContractBatch(ctx, msgs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, idx := range packed {
+		if batchErrs[i] != nil {
+			callErrs[idx] = batchErrs[i]
+
+			if dataErr, ok := batchErrs[i].(rpc.DataError); ok {
+				if hexData, ok := dataErr.ErrorData().(string); ok {
+					callErrs[idx] = DecodeRevertData(contractABI, common.FromHex(hexData))
+				}
+			}
+
+			continue
+		}
+
+		outputs[idx] = batchOutputs[i]
+	}
+
+	return outputs, callErrs, nil
+}
+
+var multicall3ABI, _ = abi.JSON(strings.NewReader(multicall3ABIJSON))
+
+var multicall3ABIJSON = `[{
+	"inputs": [
+		{
+			"components": [
+				{ "internalType": "address", "name": "target", "type": "address" },
+				{ "internalType": "bool", "name": "allowFailure", "type": "bool" },
+				{ "internalType": "bytes", "name": "callData", "type": "bytes" }
+			],
+			"internalType": "struct Multicall3.Call3[]",
+			"name": "calls",
+			"type": "tuple[]"
+		}
+	],
+	"name": "aggregate3",
+	"outputs": [
+		{
+			"components": [
+				{ "internalType": "bool", "name": "success", "type": "bool" },
+				{ "internalType": "bytes", "name": "returnData", "type": "bytes" }
+			],
+			"internalType": "struct Multicall3.Result[]",
+			"name": "returnData",
+			"type": "tuple[]"
+		}
+	],
+	"stateMutability": "payable",
+	"type": "function"
+}]`
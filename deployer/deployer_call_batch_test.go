@@ -0,0 +1,180 @@
+package deployer
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var batchTestABIJSON = `[
+	{
+		"name": "balanceOf",
+		"type": "function",
+		"stateMutability": "view",
+		"inputs": [{ "name": "account", "type": "address" }],
+		"outputs": [{ "name": "", "type": "uint256" }]
+	},
+	{
+		"name": "fail",
+		"type": "function",
+		"stateMutability": "view",
+		"inputs": [],
+		"outputs": []
+	}
+]`
+
+type stubRevertError struct {
+	data []byte
+}
+
+func (e *stubRevertError) Error() string          { return "execution reverted" }
+func (e *stubRevertError) ErrorCode() int         { return 3 }
+func (e *stubRevertError) ErrorData() interface{} { return hexutil.Encode(e.data) }
+
+type stubCallArgs struct {
+	From  *common.Address `json:"from"`
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+// stubEth serves eth_getCode and eth_call, executing calls of the test ABI and Multicall3 aggregate3.
+type stubEth struct {
+	t           *testing.T
+	contractABI abi.ABI
+	multicall   bool
+
+	directCalls    int
+	aggregateCalls int
+}
+
+func (s *stubEth) GetCode(address common.Address, block string) (hexutil.Bytes, error) {
+	if s.multicall && address == Multicall3Address {
+		return hexutil.Bytes{0x60, 0x80}, nil
+	}
+
+	return hexutil.Bytes{}, nil
+}
+
+func (s *stubEth) Call(args stubCallArgs, block string) (hexutil.Bytes, error) {
+	if *args.To != Multicall3Address {
+		s.directCalls++
+
+		output, revertData := s.execute(args.Input)
+		if revertData != nil {
+			return nil, &stubRevertError{data: revertData}
+		}
+
+		return output, nil
+	}
+
+	s.aggregateCalls++
+
+	values, err := multicall3ABI.Methods["aggregate3"].Inputs.Unpack(args.Input[4:])
+	require.NoError(s.t, err)
+
+	calls := *abi.ConvertType(values[0], new([]multicall3Call)).(*[]multicall3Call)
+	results := make([]multicall3Result, len(calls))
+	for i, call := range calls {
+		output, revertData := s.execute(call.CallData)
+		if revertData != nil {
+			results[i] = multicall3Result{ReturnData: revertData}
+			continue
+		}
+
+		results[i] = multicall3Result{Success: true, ReturnData: output}
+	}
+
+	return multicall3ABI.Methods["aggregate3"].Outputs.Pack(results)
+}
+
+func (s *stubEth) execute(calldata []byte) (output, revertData []byte) {
+	method, err := s.contractABI.MethodById(calldata[:4])
+	require.NoError(s.t, err)
+
+	switch method.Name {
+	case "balanceOf":
+		output, err = method.Outputs.Pack(big.NewInt(42))
+		require.NoError(s.t, err)
+		return output, nil
+	default:
+		stringType, _ := abi.NewType("string", "", nil)
+		revertData, err = abi.Arguments{{Type: stringType}}.Pack("nope")
+		require.NoError(s.t, err)
+		return nil, append(append([]byte{}, errorReasonPrefix...), revertData...)
+	}
+}
+
+func TestCallBatch(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(batchTestABIJSON))
+	require.NoError(t, err)
+
+	contract := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	account := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	calls := []ContractBatchCall{{
+		Contract:   contract,
+		MethodName: "balanceOf",
+		MethodInputMapper: func(args abi.Arguments) ([]interface{}, error) {
+			return []interface{}{account}, nil
+		},
+	}, {
+		Contract:   contract,
+		MethodName: "fail",
+	}, {
+		Contract:   contract,
+		MethodName: "balanceOf",
+		MethodInputMapper: func(args abi.Arguments) ([]interface{}, error) {
+			return nil, errors.New("wrong args count")
+		},
+	}, {
+		Contract:   contract,
+		MethodName: "missing",
+	}}
+
+	for _, multicall := range []bool{true, false} {
+		stub := &stubEth{
+			t:           t,
+			contractABI: contractABI,
+			multicall:   multicall,
+		}
+
+		srv := rpc.NewServer()
+		require.NoError(t, srv.RegisterName("eth", stub))
+		httpSrv := httptest.NewServer(srv)
+
+		d := &deployer{options: defaultOptions()}
+		d.options.EVMRPCEndpoint = httpSrv.URL
+
+		results, err := d.callBatch(context.Background(), &contractABI, account, calls)
+		require.NoError(t, err)
+		require.Len(t, results, len(calls))
+
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, []interface{}{big.NewInt(42)}, results[0].Output)
+
+		assert.EqualError(t, results[1].Err, "execution reverted: nope")
+		assert.ErrorContains(t, results[2].Err, "failed to map method args: wrong args count")
+		assert.ErrorContains(t, results[3].Err, "method not found")
+
+		if multicall {
+			assert.Equal(t, 1, stub.aggregateCalls)
+			assert.Zero(t, stub.directCalls)
+		} else {
+			assert.Zero(t, stub.aggregateCalls)
+			assert.Equal(t, 2, stub.directCalls)
+		}
+
+		httpSrv.Close()
+		srv.Stop()
+	}
+}
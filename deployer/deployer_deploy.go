@@ -12,6 +12,7 @@ import (
 
 	"github.com/InjectiveLabs/etherman/sol"
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -101,7 +102,7 @@ func (d *deployer) Deploy(
 		mappedArgs = constructorInputMapper(boundContract.ABI().Constructor.Inputs)
 	}
 
-	boundContract.SetTransact(getTransactFn(client, common.Address{}, &txHash))
+	boundContract.SetTransact(getTransactFn(client, common.Address{}, d.options.GasMultiplier, d.options.GasBuffer, &txHash))
 
 	txCtx, cancelFn := context.WithTimeout(context.Background(), d.options.RPCTimeout)
 	defer cancelFn()
@@ -134,23 +135,28 @@ func (d *deployer) Deploy(
 		"gasLimit": d.options.GasLimit,
 	}).Debugln("deploying contract", contract.Name)
 
+	traceCoverage := d.coverageStrategy() == CoverageStrategyTrace && deployOpts.CoverageAgent != nil
+	if traceCoverage {
+		loadCoverageStatements(deployOpts.CoverageAgent, contract)
+	}
+
 	address, _, err := boundContract.DeployContract(ethTxOpts, mappedArgs...)
 	if err != nil {
-		if hasCoverageReport(err) {
-			if deployOpts.CoverageAgent != nil {
-				if err := deployOpts.CoverageAgent.LoadContract(contract); err != nil {
-					log.WithError(err).Errorln("failed to open referenced dependecies for coverage reporting")
-				}
-
-				coverageReportErr := deployOpts.CoverageAgent.CollectCoverageRevert(contract.Name, err)
-				if coverageReportErr != nil {
-					log.WithError(coverageReportErr).Warningln("failed to collect coverage revert event")
-				}
+		if traceCoverage && txHash == noHash {
+			// tx was not sent, most likely failed at gas estimation, so trace it as a call
+			abiPackedArgs, _ := boundContract.ABI().Constructor.Inputs.PackValues(mappedArgs)
+			msg := ethereum.CallMsg{
+				From: deployOpts.From,
+				Data: append(common.FromHex(contract.Bin), abiPackedArgs...),
 			}
 
-			err = trimCoverageReport(err)
+			if coverageErr := d.collectCallTraceCoverage(deployOpts.CoverageAgent, contract, msg, nil); coverageErr != nil {
+				log.WithError(coverageErr).Warningln("failed to collect coverage from call trace")
+			}
 		}
 
+		err = collectCoverageRevert(deployOpts.CoverageAgent, contract, err)
+
 		log.WithError(err).WithField("txHash", txHash.Hex()).Errorln("failed to deploy contract")
 		return txHash, nil, err
 	}
@@ -163,58 +169,37 @@ func (d *deployer) Deploy(
 		log.WithField("txHash", txHash.Hex()).Debugln("awaiting contract deployment", address.Hex())
 
 		_, err = awaitTx(awaitCtx, client, txHash)
-	}
-	if err != nil {
-		if hasCoverageReport(err) {
-			if deployOpts.CoverageAgent != nil {
-				if err := deployOpts.CoverageAgent.LoadContract(contract); err != nil {
-					log.WithError(err).Errorln("failed to open referenced dependecies for coverage reporting")
+		if traceCoverage && (err == nil || err == ErrTransactionReverted) {
+			defer func() {
+				if coverageErr := d.collectTxTraceCoverage(deployOpts.CoverageAgent, contract, txHash, true); coverageErr != nil {
+					log.WithError(coverageErr).Warningln("failed to collect coverage from transaction trace")
 				}
-
-				coverageReportErr := deployOpts.CoverageAgent.CollectCoverageRevert(contract.Name, err)
-				if coverageReportErr != nil {
-					log.WithError(coverageReportErr).Warningln("failed to collect coverage revert event")
-				}
-			}
-
-			err = trimCoverageReport(err)
+			}()
 		}
-
+	}
+	if err != nil {
 		return txHash, contract, err
 	}
 
-	if d.options.EnableCoverage && deployOpts.CoverageAgent != nil && txHash != noHash {
+	if d.coverageStrategy() == CoverageStrategyInstrument && deployOpts.CoverageAgent != nil && txHash != noHash {
 		callCtx, cancelFn := context.WithTimeout(context.Background(), d.options.CallTimeout)
 		defer cancelFn()
 
-		var coverageTopic common.Hash
+		var coverageEvents map[common.Hash]abi.Event
 		_, coverageEventABI, err := d.GetCoverageEventInfo(callCtx, deployOpts.From, contract.Name, contract.Address)
 		if err != ErrNoCoverage {
 			if err != nil {
 				return txHash, contract, err
 			}
 
-			coverageTopic = coverageEventABI.ID
+			coverageEvents = contractCoverageEvents(contract, coverageEventABI)
 
 			if deployOpts.CoverageAgent != nil {
-				if err := deployOpts.CoverageAgent.LoadContract(contract); err != nil {
-					log.WithError(err).Errorln("failed to open referenced dependecies for coverage reporting")
-				}
-				for _, statement := range contract.Statements {
-					if statement[0] < 0 || statement[1] < 0 || statement[2] < 0 {
-						continue
-					}
-
-					deployOpts.CoverageAgent.AddStatement(contract.Name,
-						uint64(statement[0]),
-						uint64(statement[1]),
-						uint64(statement[2]),
-					)
-				}
+				loadCoverageStatements(deployOpts.CoverageAgent, contract)
 			}
 		}
 
-		if coverageTopic == noHash {
+		if len(coverageEvents) == 0 {
 			return txHash, contract, err
 		}
 
@@ -236,7 +221,7 @@ func (d *deployer) Deploy(
 		for _, ethLog := range receipt.Logs {
 			if ethLog == nil || len(ethLog.Topics) == 0 {
 				continue
-			} else if ethLog.Topics[0] == coverageTopic {
+			} else if coverageEventABI, ok := coverageEvents[ethLog.Topics[0]]; ok {
 				if deployOpts.CoverageAgent != nil {
 					if err := deployOpts.CoverageAgent.CollectCoverageEvent(contract.Name, coverageEventABI, ethLog); err != nil {
 						log.WithError(err).WithField("contract", contract.Name).Warningln("failed to collect coverage event from contract")
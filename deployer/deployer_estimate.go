@@ -0,0 +1,177 @@
+package deployer
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/InjectiveLabs/etherman/sol"
+)
+
+type GasEstimate struct {
+	// EstimatedGas is the raw value returned by eth_estimateGas.
+	EstimatedGas uint64 `json:"estimatedGas"`
+	// GasLimit is the estimate adjusted by the configured multiplier and buffer.
+	GasLimit uint64   `json:"gasLimit"`
+	GasPrice *big.Int `json:"gasPrice"`
+	// Cost is the max cost of the transaction in wei, i.e. GasLimit * GasPrice + Value.
+	Cost *big.Int `json:"cost"`
+}
+
+func (d *deployer) EstimateDeploy(
+	ctx context.Context,
+	deployOpts ContractDeployOpts,
+	constructorInputMapper AbiMethodInputMapperFunc,
+) (estimate *GasEstimate, err error) {
+	solSourceFullPath, _ := filepath.Abs(deployOpts.SolSource)
+	contract := d.getCompiledContract(deployOpts.ContractName, solSourceFullPath)
+	if contract == nil {
+		log.Errorln("contract compilation failed, check logs")
+		return nil, ErrCompilationFailed
+	}
+
+	input, _, err := packConstructorCalldata(contract, constructorInputMapper)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.estimateGas(ctx, ethereum.CallMsg{
+		From:  deployOpts.From,
+		Value: new(big.Int),
+		Data:  input,
+	}, nil)
+}
+
+func (d *deployer) EstimateTx(
+	ctx context.Context,
+	txOpts ContractTxOpts,
+	methodName string,
+	methodInputMapper AbiMethodInputMapperFunc,
+) (estimate *GasEstimate, err error) {
+	solSourceFullPath, _ := filepath.Abs(txOpts.SolSource)
+	contract := d.getCompiledContract(txOpts.ContractName, solSourceFullPath)
+	if contract == nil {
+		log.Errorln("contract compilation failed, check logs")
+		return nil, ErrCompilationFailed
+	}
+
+	input, _, err := packMethodCalldata(contract, methodName, methodInputMapper)
+	if err != nil {
+		return nil, err
+	}
+
+	value := txOpts.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	return d.estimateGas(ctx, ethereum.CallMsg{
+		From:  txOpts.From,
+		To:    &txOpts.Contract,
+		Value: value,
+		Data:  input,
+	}, nil)
+}
+
+// estimateGas estimates at the given block, or at the latest one if blockNumber is nil.
+func (d *deployer) estimateGas(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (*GasEstimate, error) {
+	client, err := d.Backend()
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancelFn := context.WithTimeout(ctx, d.options.CallTimeout)
+	defer cancelFn()
+
+	gasPrice := d.options.GasPrice
+	if gasPrice == nil {
+		if gasPrice, err = client.SuggestGasPrice(callCtx); err != nil {
+			err = errors.Wrap(err, "failed to suggest gas price")
+			return nil, err
+		}
+	}
+	msg.GasPrice = gasPrice
+
+	var estimatedGas uint64
+	if blockNumber != nil {
+		estimatedGas, err = client.EstimateGasAtBlock(callCtx, msg, blockNumber)
+	} else {
+		estimatedGas, err = client.EstimateGas(callCtx, msg)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "failed to estimate gas needed")
+		return nil, err
+	}
+
+	estimate := &GasEstimate{
+		EstimatedGas: estimatedGas,
+		GasLimit:     adjustGasEstimate(estimatedGas, d.options.GasMultiplier, d.options.GasBuffer),
+		GasPrice:     gasPrice,
+	}
+
+	estimate.Cost = new(big.Int).Mul(new(big.Int).SetUint64(estimate.GasLimit), gasPrice)
+	if msg.Value != nil {
+		estimate.Cost.Add(estimate.Cost, msg.Value)
+	}
+
+	return estimate, nil
+}
+
+// packConstructorCalldata returns contract creation input: the bytecode followed by ABI-encoded constructor args.
+func packConstructorCalldata(contract *sol.Contract, constructorInputMapper AbiMethodInputMapperFunc) ([]byte, *abi.ABI, error) {
+	boundContract, err := BindContract(nil, contract)
+	if err != nil {
+		log.WithField("contract", contract.Name).WithError(err).Errorln("failed to bind contract")
+		return nil, nil, err
+	}
+	contractABI := boundContract.ABI()
+
+	var mappedArgs []interface{}
+	if constructorInputMapper != nil {
+		mappedArgs = constructorInputMapper(contractABI.Constructor.Inputs)
+	}
+
+	abiPackedArgs, err := contractABI.Constructor.Inputs.PackValues(mappedArgs)
+	if err != nil {
+		err = errors.Wrap(err, "failed to ABI-encode constructor values")
+		return nil, nil, err
+	}
+
+	return append(common.FromHex(contract.Bin), abiPackedArgs...), &contractABI, nil
+}
+
+// packMethodCalldata returns method selector followed by ABI-encoded method args.
+func packMethodCalldata(contract *sol.Contract, methodName string, methodInputMapper AbiMethodInputMapperFunc) ([]byte, *abi.ABI, error) {
+	boundContract, err := BindContract(nil, contract)
+	if err != nil {
+		log.WithField("contract", contract.Name).WithError(err).Errorln("failed to bind contract")
+		return nil, nil, err
+	}
+	contractABI := boundContract.ABI()
+
+	method, ok := contractABI.Methods[methodName]
+	if !ok {
+		err := errors.Errorf("method not found: %s", methodName)
+		return nil, nil, err
+	}
+
+	var mappedArgs []interface{}
+	if methodInputMapper != nil {
+		mappedArgs = methodInputMapper(method.Inputs)
+	}
+
+	packedArgs, err := method.Inputs.PackValues(mappedArgs)
+	if err != nil {
+		err = errors.Wrap(err, "failed to ABI-encode method args")
+		return nil, nil, err
+	}
+
+	return append(append([]byte{}, method.ID...), packedArgs...), &contractABI, nil
+}
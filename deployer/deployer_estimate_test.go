@@ -0,0 +1,142 @@
+package deployer
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/etherman/sol"
+)
+
+func TestAdjustGasEstimate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		estimated  uint64
+		multiplier float64
+		buffer     uint64
+		expected   uint64
+	}{
+		{"no margin", 21000, 1, 0, 21000},
+		{"multiplier", 100000, 1.2, 0, 120000},
+		{"buffer", 21000, 1, 5000, 26000},
+		{"multiplier and buffer", 100000, 1.5, 1000, 151000},
+		{"rounded up", 33333, 1.1, 0, 36667},
+		{"multiplier below 1 is ignored", 100000, 0.5, 0, 100000},
+		{"zero multiplier is ignored", 100000, 0, 10, 100010},
+		{"negative multiplier is ignored", 100000, -2, 0, 100000},
+		{"NaN multiplier is ignored", 100000, math.NaN(), 0, 100000},
+		{"multiplied overflow", math.MaxUint64 / 2, 3, 0, math.MaxUint64},
+		{"buffer overflow", math.MaxUint64 - 10, 1, 11, math.MaxUint64},
+		{"max without overflow", math.MaxUint64 - 10, 1, 10, math.MaxUint64},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, adjustGasEstimate(tc.estimated, tc.multiplier, tc.buffer), tc.name)
+	}
+}
+
+// stubEstimateEth records eth_estimateGas calls, gas price is suggested by eth_gasPrice.
+type stubEstimateEth struct {
+	args []stubCallArgs
+}
+
+func (s *stubEstimateEth) GasPrice() (*hexutil.Big, error) {
+	return (*hexutil.Big)(big.NewInt(10)), nil
+}
+
+func (s *stubEstimateEth) EstimateGas(args stubCallArgs, block *string) (hexutil.Uint64, error) {
+	s.args = append(s.args, args)
+	return 50000, nil
+}
+
+func TestEstimate(t *testing.T) {
+	stub := new(stubEstimateEth)
+
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", stub))
+	defer srv.Stop()
+
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+
+	d := &deployer{
+		options:     defaultOptions(),
+		compiled:    make(map[string]*sol.Contract),
+		compiledMux: new(sync.Mutex),
+	}
+	d.options.EVMRPCEndpoint = httpSrv.URL
+	d.options.GasMultiplier = 1.2
+	d.options.GasBuffer = 1000
+
+	// nil gas price is suggested by the node
+	d.options.GasPrice = nil
+
+	solSource, err := filepath.Abs("Counter.sol")
+	require.NoError(t, err)
+
+	// the contract is memoized, so solc isn't needed
+	d.compiled[solSource+":Counter:"] = &sol.Contract{
+		Name: "Counter",
+		Bin:  "0x6080",
+		ABI: []byte(`[
+			{"type": "constructor", "inputs": [{"name": "start", "type": "uint256"}]},
+			{"name": "addValue", "type": "function", "stateMutability": "payable", "inputs": [{"name": "value", "type": "uint256"}], "outputs": []}
+		]`),
+	}
+
+	from := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	to := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	one := func(args abi.Arguments) []interface{} {
+		return []interface{}{big.NewInt(1)}
+	}
+
+	estimate, err := d.EstimateDeploy(context.Background(), ContractDeployOpts{
+		From:         from,
+		SolSource:    "Counter.sol",
+		ContractName: "Counter",
+	}, one)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(50000), estimate.EstimatedGas)
+	assert.Equal(t, uint64(61000), estimate.GasLimit)
+	assert.Equal(t, big.NewInt(10), estimate.GasPrice)
+	assert.Equal(t, big.NewInt(610000), estimate.Cost)
+
+	require.Len(t, stub.args, 1)
+	assert.Nil(t, stub.args[0].To)
+	assert.Equal(t, append(common.FromHex("0x6080"), common.LeftPadBytes([]byte{1}, 32)...), []byte(stub.args[0].Input))
+
+	estimate, err = d.EstimateTx(context.Background(), ContractTxOpts{
+		From:         from,
+		SolSource:    "Counter.sol",
+		ContractName: "Counter",
+		Contract:     to,
+		Value:        big.NewInt(5),
+	}, "addValue", one)
+	require.NoError(t, err)
+
+	// the value is added to the max cost
+	assert.Equal(t, big.NewInt(610005), estimate.Cost)
+
+	require.Len(t, stub.args, 2)
+	assert.Equal(t, &to, stub.args[1].To)
+	assert.Equal(t, from, *stub.args[1].From)
+
+	_, err = d.EstimateTx(context.Background(), ContractTxOpts{
+		SolSource:    "Counter.sol",
+		ContractName: "Counter",
+		Contract:     to,
+	}, "unknown", nil)
+	assert.ErrorContains(t, err, "method not found")
+}
@@ -67,33 +67,27 @@ func (d *deployer) Logs(
 	callCtx, cancelFn := context.WithTimeout(context.Background(), d.options.CallTimeout)
 	defer cancelFn()
 
-	var coverageTopic common.Hash
-	var coverageEventABI abi.Event
+	var coverageEvents map[common.Hash]abi.Event
 
-	if d.options.EnableCoverage {
-		_, coverageEventABI, err = d.GetCoverageEventInfo(callCtx, logsOpts.From, contract.Name, contract.Address)
+	if d.coverageStrategy() == CoverageStrategyTrace {
+		if logsOpts.CoverageAgent != nil {
+			loadCoverageStatements(logsOpts.CoverageAgent, contract)
+
+			if err := d.collectTxTraceCoverage(logsOpts.CoverageAgent, contract, txHash, false); err != nil {
+				log.WithError(err).Warningln("failed to collect coverage from transaction trace")
+			}
+		}
+	} else if d.options.EnableCoverage {
+		_, coverageEventABI, err := d.GetCoverageEventInfo(callCtx, logsOpts.From, contract.Name, contract.Address)
 		if err != ErrNoCoverage {
 			if err != nil {
 				return nil, err
 			}
 
-			coverageTopic = coverageEventABI.ID
+			coverageEvents = contractCoverageEvents(contract, coverageEventABI)
 
 			if logsOpts.CoverageAgent != nil {
-				if err := logsOpts.CoverageAgent.LoadContract(contract); err != nil {
-					log.WithError(err).Errorln("failed to open referenced dependecies for coverage reporting")
-				}
-				for _, statement := range contract.Statements {
-					if statement[0] < 0 || statement[1] < 0 || statement[2] < 0 {
-						continue
-					}
-
-					logsOpts.CoverageAgent.AddStatement(contract.Name,
-						uint64(statement[0]),
-						uint64(statement[1]),
-						uint64(statement[2]),
-					)
-				}
+				loadCoverageStatements(logsOpts.CoverageAgent, contract)
 			}
 		}
 	}
@@ -124,7 +118,7 @@ func (d *deployer) Logs(
 			continue
 		}
 
-		if d.options.EnableCoverage && ethLog.Topics[0] == coverageTopic {
+		if coverageEventABI, ok := coverageEvents[ethLog.Topics[0]]; ok {
 			if logsOpts.CoverageAgent != nil {
 				if err := logsOpts.CoverageAgent.CollectCoverageEvent(contract.Name, coverageEventABI, ethLog); err != nil {
 					log.WithError(err).WithField("contract", contract.Name).Warningln("failed to collect coverage event from contract")
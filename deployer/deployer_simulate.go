@@ -0,0 +1,171 @@
+package deployer
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type SimulationResult struct {
+	// Output contains decoded return values of the method, not set for deployments.
+	Output       map[string]interface{} `json:"output,omitempty"`
+	Reverted     bool                   `json:"reverted"`
+	RevertReason string                 `json:"revertReason,omitempty"`
+	// Gas is not estimated for reverted simulations.
+	Gas *GasEstimate `json:"gas,omitempty"`
+	// ContractAddress is the predicted address of a deployed contract, based on the pending nonce.
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+}
+
+// SimulateDeploy executes contract creation with eth_call at pending state, without
+// signing or broadcasting anything, so sender's nonce is not affected.
+func (d *deployer) SimulateDeploy(
+	ctx context.Context,
+	deployOpts ContractDeployOpts,
+	constructorInputMapper AbiMethodInputMapperFunc,
+) (result *SimulationResult, err error) {
+	solSourceFullPath, _ := filepath.Abs(deployOpts.SolSource)
+	contract := d.getCompiledContract(deployOpts.ContractName, solSourceFullPath)
+	if contract == nil {
+		log.Errorln("contract compilation failed, check logs")
+		return nil, ErrCompilationFailed
+	}
+
+	input, contractABI, err := packConstructorCalldata(contract, constructorInputMapper)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.Backend()
+	if err != nil {
+		return nil, err
+	}
+
+	nonceCtx, cancelFn := context.WithTimeout(ctx, d.options.RPCTimeout)
+	defer cancelFn()
+
+	nonce, err := client.PendingNonceAt(nonceCtx, deployOpts.From)
+	if err != nil {
+		log.WithField("from", deployOpts.From.Hex()).WithError(err).Errorln("failed to get pending nonce")
+		return nil, ErrNoNonce
+	}
+
+	msg := ethereum.CallMsg{
+		From:  deployOpts.From,
+		Value: new(big.Int),
+		Data:  input,
+	}
+
+	result, _, err = d.simulate(ctx, client, contractABI, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddress := crypto.CreateAddress(deployOpts.From, nonce)
+	result.ContractAddress = &contractAddress
+
+	return result, nil
+}
+
+// SimulateTx executes the method with eth_call at pending state, using the same from, value
+// and calldata as the real transaction would, but without signing or broadcasting it.
+func (d *deployer) SimulateTx(
+	ctx context.Context,
+	txOpts ContractTxOpts,
+	methodName string,
+	methodInputMapper AbiMethodInputMapperFunc,
+) (result *SimulationResult, err error) {
+	solSourceFullPath, _ := filepath.Abs(txOpts.SolSource)
+	contract := d.getCompiledContract(txOpts.ContractName, solSourceFullPath)
+	if contract == nil {
+		log.Errorln("contract compilation failed, check logs")
+		return nil, ErrCompilationFailed
+	}
+
+	input, contractABI, err := packMethodCalldata(contract, methodName, methodInputMapper)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.Backend()
+	if err != nil {
+		return nil, err
+	}
+
+	value := txOpts.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	msg := ethereum.CallMsg{
+		From:  txOpts.From,
+		To:    &txOpts.Contract,
+		Value: value,
+		Data:  input,
+	}
+
+	result, output, err := d.simulate(ctx, client, contractABI, msg)
+	if err != nil {
+		return nil, err
+	} else if result.Reverted {
+		return result, nil
+	}
+
+	result.Output, err = DecodeReturnData(contractABI, methodName, output)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (d *deployer) simulate(
+	ctx context.Context,
+	client *Client,
+	contractABI *abi.ABI,
+	msg ethereum.CallMsg,
+) (result *SimulationResult, output []byte, err error) {
+	callCtx, cancelFn := context.WithTimeout(ctx, d.options.CallTimeout)
+	defer cancelFn()
+
+	result = &SimulationResult{}
+
+	output, err = client.PendingCallContract(callCtx, msg)
+	if err != nil {
+		// some nodes return a bare revert error, without revert data
+		var revertData string
+		if dataErr, ok := err.(rpc.DataError); ok {
+			revertData, _ = dataErr.ErrorData().(string)
+		}
+
+		if len(revertData) == 0 && !isRevertError(err) {
+			err = errors.Wrap(err, "failed to simulate transaction")
+			return nil, nil, err
+		}
+
+		result.Reverted = true
+		result.RevertReason = err.Error()
+
+		if len(revertData) > 0 {
+			result.RevertReason = DecodeRevertData(contractABI, common.FromHex(revertData)).Error()
+		}
+
+		return result, nil, nil
+	}
+
+	result.Gas, err = d.estimateGas(ctx, msg, big.NewInt(int64(rpc.PendingBlockNumber)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, output, nil
+}
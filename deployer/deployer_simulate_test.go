@@ -0,0 +1,90 @@
+package deployer
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSimulateEth fails eth_call with callErr and records the block of eth_estimateGas.
+type stubSimulateEth struct {
+	callErr       error
+	callBlock     string
+	estimateBlock string
+}
+
+func (s *stubSimulateEth) Call(args stubCallArgs, block string) (hexutil.Bytes, error) {
+	s.callBlock = block
+	if s.callErr != nil {
+		return nil, s.callErr
+	}
+
+	return hexutil.Bytes{}, nil
+}
+
+func (s *stubSimulateEth) EstimateGas(args stubCallArgs, block string) (hexutil.Uint64, error) {
+	s.estimateBlock = block
+	return 21000, nil
+}
+
+func TestSimulate(t *testing.T) {
+	to := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	msg := ethereum.CallMsg{To: &to}
+
+	testCases := []struct {
+		name         string
+		callErr      error
+		reverted     bool
+		revertReason string
+	}{
+		{"success", nil, false, ""},
+		{"revert without data", errors.New("execution reverted"), true, "execution reverted"},
+		{"revert with data", &stubRevertError{data: []byte{}}, true, "execution reverted"},
+		{"ganache revert", errors.New("VM Exception while processing transaction: revert"), true, "VM Exception while processing transaction: revert"},
+		{"rpc failure", errors.New("header not found"), false, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &stubSimulateEth{callErr: tc.callErr}
+
+			srv := rpc.NewServer()
+			require.NoError(t, srv.RegisterName("eth", stub))
+			defer srv.Stop()
+
+			httpSrv := httptest.NewServer(srv)
+			defer httpSrv.Close()
+
+			d := &deployer{options: defaultOptions()}
+			d.options.EVMRPCEndpoint = httpSrv.URL
+
+			client, err := d.Backend()
+			require.NoError(t, err)
+
+			result, _, err := d.simulate(context.Background(), client, nil, msg)
+			if tc.callErr != nil && !tc.reverted {
+				assert.ErrorContains(t, err, "failed to simulate transaction")
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, "pending", stub.callBlock)
+			assert.Equal(t, tc.reverted, result.Reverted)
+			assert.Equal(t, tc.revertReason, result.RevertReason)
+
+			if !tc.reverted {
+				require.NotNil(t, result.Gas)
+				assert.Equal(t, uint64(21000), result.Gas.EstimatedGas)
+				assert.Equal(t, "pending", stub.estimateBlock)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type ContractTraceOpts struct {
+	// SolSource is optional, if the file exists, the contract is compiled
+	// and its ABI takes precedence over ABIs found in the build cache.
+	SolSource    string
+	ContractName string
+}
+
+// CallFrame is a single frame of the call tree, with calldata, output and revert
+// decoded using the first known ABI that matches.
+type CallFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *big.Int       `json:"value,omitempty"`
+	Gas     uint64         `json:"gas"`
+	GasUsed uint64         `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input,omitempty"`
+	Output  hexutil.Bytes  `json:"output,omitempty"`
+
+	Contract  string                 `json:"contract,omitempty"`
+	Method    string                 `json:"method,omitempty"`
+	Signature string                 `json:"signature,omitempty"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`
+	// InputAbi and OutputAbi keep the ABI order of Args and Outputs.
+	InputAbi  abi.Arguments `json:"-"`
+	OutputAbi abi.Arguments `json:"-"`
+
+	Error        string `json:"error,omitempty"`
+	RevertReason string `json:"revertReason,omitempty"`
+
+	Calls []*CallFrame `json:"calls,omitempty"`
+}
+
+type rawCallFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to"`
+	Value        *hexutil.Big    `json:"value"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output"`
+	Error        string          `json:"error"`
+	RevertReason string          `json:"revertReason"`
+	Calls        []rawCallFrame  `json:"calls"`
+}
+
+type knownContract struct {
+	Name       string
+	ABI        abi.ABI
+	Bin        []byte
+	BinRuntime []byte
+}
+
+// Trace replays the transaction with debug_traceTransaction and callTracer,
+// returning the root frame of the decoded call tree.
+func (d *deployer) Trace(
+	ctx context.Context,
+	traceOpts ContractTraceOpts,
+	txHash common.Hash,
+) (root *CallFrame, err error) {
+	contracts := d.knownContracts(traceOpts)
+	if len(contracts) == 0 {
+		log.Warningln("no contracts found in the build cache, call tree will not be decoded")
+	}
+
+	client, err := d.Backend()
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancelFn := context.WithTimeout(ctx, d.options.CallTimeout)
+	defer cancelFn()
+
+	var raw rawCallFrame
+	err = client.TraceTransaction(callCtx, txHash, map[string]interface{}{
+		"tracer": "callTracer",
+	}, &raw)
+	if err != nil {
+		err = errors.Wrap(err, "failed to trace transaction")
+		return nil, err
+	}
+
+	byAddress := d.resolveContractAddresses(ctx, client, contracts, &raw)
+	return decodeCallFrame(contracts, byAddress, &raw), nil
+}
+
+// resolveContractAddresses maps call targets of the tree to known contracts, either created
+// within the traced transaction, or having deployed code that matches the runtime bytecode.
+func (d *deployer) resolveContractAddresses(
+	ctx context.Context,
+	client *Client,
+	contracts []*knownContract,
+	raw *rawCallFrame,
+) map[common.Address]*knownContract {
+	byAddress := make(map[common.Address]*knownContract)
+	if len(contracts) == 0 {
+		return byAddress
+	}
+
+	var targets []common.Address
+
+	var walk func(raw *rawCallFrame)
+	walk = func(raw *rawCallFrame) {
+		if raw.To != nil {
+			switch raw.Type {
+			case "CREATE", "CREATE2":
+				if c := matchCreationCode(contracts, raw.Input); c != nil {
+					byAddress[*raw.To] = c
+				}
+			default:
+				targets = append(targets, *raw.To)
+			}
+		}
+
+		for idx := range raw.Calls {
+			walk(&raw.Calls[idx])
+		}
+	}
+	walk(raw)
+
+	checked := make(map[common.Address]struct{}, len(targets))
+	for _, address := range targets {
+		if _, ok := byAddress[address]; ok {
+			continue
+		} else if _, ok := checked[address]; ok {
+			continue
+		}
+		checked[address] = struct{}{}
+
+		codeCtx, cancelFn := context.WithTimeout(ctx, d.options.CallTimeout)
+		code, err := client.CodeAt(codeCtx, address, nil)
+		cancelFn()
+		if err != nil {
+			log.WithField("address", address.Hex()).WithError(err).Debugln("failed to get contract code")
+			continue
+		}
+
+		for _, c := range contracts {
+			if matchRuntimeCode(c.BinRuntime, code) {
+				byAddress[address] = c
+				break
+			}
+		}
+	}
+
+	return byAddress
+}
+
+// matchRuntimeCode compares deployed code with the compiled runtime bytecode,
+// where immutables are zero placeholders filled in on deployment.
+func matchRuntimeCode(binRuntime, code []byte) bool {
+	if len(binRuntime) == 0 || len(binRuntime) != len(code) {
+		return false
+	}
+
+	for i := range code {
+		if code[i] != binRuntime[i] && binRuntime[i] != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchCreationCode(contracts []*knownContract, input []byte) *knownContract {
+	for _, c := range contracts {
+		if len(c.Bin) > 0 && bytes.HasPrefix(input, c.Bin) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// knownContracts collects ABIs from the build cache, the most recent build of each contract wins.
+func (d *deployer) knownContracts(traceOpts ContractTraceOpts) []*knownContract {
+	contracts := make([]*knownContract, 0)
+
+	if len(traceOpts.SolSource) > 0 {
+		solSourceFullPath, _ := filepath.Abs(traceOpts.SolSource)
+
+		if _, err := os.Stat(solSourceFullPath); err == nil {
+			if contract := d.getCompiledContract(traceOpts.ContractName, solSourceFullPath); contract != nil {
+				if contractABI, err := abi.JSON(bytes.NewReader(contract.ABI)); err == nil {
+					contracts = append(contracts, &knownContract{
+						Name:       contract.Name,
+						ABI:        contractABI,
+						Bin:        common.FromHex(contract.Bin),
+						BinRuntime: common.FromHex(contract.BinRuntime),
+					})
+				}
+			}
+		}
+	}
+
+	cache, err := NewBuildCache(d.options.BuildCacheDir)
+	if err != nil {
+		log.WithError(err).Warningln("failed to open build cache")
+		return contracts
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		log.WithError(err).Warningln("failed to list build cache entries")
+		return contracts
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+
+	seen := make(map[string]struct{}, len(entries))
+	for _, c := range contracts {
+		seen[c.Name] = struct{}{}
+	}
+
+	for _, entry := range entries {
+		if _, ok := seen[entry.ContractName]; ok {
+			continue
+		}
+
+		contractABI, err := abi.JSON(bytes.NewReader(entry.ABI))
+		if err != nil {
+			log.WithField("contract", entry.ContractName).WithError(err).Warningln("failed to parse cached ABI")
+			continue
+		}
+
+		seen[entry.ContractName] = struct{}{}
+		contracts = append(contracts, &knownContract{
+			Name:       entry.ContractName,
+			ABI:        contractABI,
+			Bin:        common.FromHex(entry.Bin),
+			BinRuntime: common.FromHex(entry.BinRuntime),
+		})
+	}
+
+	return contracts
+}
+
+func decodeCallFrame(
+	contracts []*knownContract,
+	byAddress map[common.Address]*knownContract,
+	raw *rawCallFrame,
+) *CallFrame {
+	frame := &CallFrame{
+		Type:         raw.Type,
+		From:         raw.From,
+		Gas:          uint64(raw.Gas),
+		GasUsed:      uint64(raw.GasUsed),
+		Input:        raw.Input,
+		Output:       raw.Output,
+		Error:        raw.Error,
+		RevertReason: raw.RevertReason,
+	}
+
+	if raw.To != nil {
+		frame.To = *raw.To
+	}
+
+	if raw.Value != nil {
+		frame.Value = raw.Value.ToInt()
+	}
+
+	var matched *knownContract
+
+	switch raw.Type {
+	case "CREATE", "CREATE2":
+		matched = decodeCreateFrame(contracts, frame)
+	default:
+		matched = decodeMethodFrame(contracts, byAddress, frame)
+	}
+
+	if len(frame.Error) > 0 && len(frame.Output) > 0 {
+		var contractABI *abi.ABI
+		if matched != nil {
+			contractABI = &matched.ABI
+		}
+
+		if len(frame.Output) >= 4 {
+			var errorID [4]byte
+			copy(errorID[:], frame.Output[:4])
+
+			if contractABI == nil || !hasErrorID(contractABI, errorID) {
+				for _, c := range contracts {
+					if hasErrorID(&c.ABI, errorID) {
+						contractABI = &c.ABI
+						break
+					}
+				}
+			}
+		}
+
+		frame.RevertReason = DecodeRevertData(contractABI, frame.Output).Error()
+	}
+
+	for idx := range raw.Calls {
+		frame.Calls = append(frame.Calls, decodeCallFrame(contracts, byAddress, &raw.Calls[idx]))
+	}
+
+	return frame
+}
+
+// decodeMethodFrame decodes the call using the contract known at the target address,
+// falling back to the first contract with a matching selector if the address is unknown.
+func decodeMethodFrame(
+	contracts []*knownContract,
+	byAddress map[common.Address]*knownContract,
+	frame *CallFrame,
+) *knownContract {
+	if c, ok := byAddress[frame.To]; ok {
+		frame.Contract = c.Name
+
+		if len(frame.Input) >= 4 {
+			if method, err := c.ABI.MethodById(frame.Input[:4]); err == nil {
+				decodeMethodCall(frame, method)
+			}
+		}
+
+		return c
+	}
+
+	if len(frame.Input) < 4 {
+		return nil
+	}
+
+	for _, c := range contracts {
+		method, err := c.ABI.MethodById(frame.Input[:4])
+		if err != nil {
+			continue
+		}
+
+		frame.Contract = c.Name
+		decodeMethodCall(frame, method)
+
+		return c
+	}
+
+	return nil
+}
+
+func decodeMethodCall(frame *CallFrame, method *abi.Method) {
+	frame.Method = method.RawName
+	frame.Signature = method.Sig
+	frame.InputAbi = method.Inputs
+
+	if args, err := unpackArgumentsIntoMap(method.Inputs, frame.Input[4:]); err == nil {
+		frame.Args = args
+	}
+
+	if len(frame.Error) == 0 && len(frame.Output) > 0 {
+		frame.OutputAbi = method.Outputs
+
+		if outputs, err := unpackArgumentsIntoMap(method.Outputs, frame.Output); err == nil {
+			frame.Outputs = outputs
+		}
+	}
+}
+
+func decodeCreateFrame(contracts []*knownContract, frame *CallFrame) *knownContract {
+	c := matchCreationCode(contracts, frame.Input)
+	if c == nil {
+		return nil
+	}
+
+	frame.Contract = c.Name
+	frame.Method = "constructor"
+	frame.InputAbi = c.ABI.Constructor.Inputs
+
+	if args, err := unpackArgumentsIntoMap(c.ABI.Constructor.Inputs, frame.Input[len(c.Bin):]); err == nil {
+		frame.Args = args
+	}
+
+	return c
+}
+
+func hasErrorID(contractABI *abi.ABI, errorID [4]byte) bool {
+	_, err := contractABI.ErrorByID(errorID)
+	return err == nil
+}
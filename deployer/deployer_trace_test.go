@@ -0,0 +1,86 @@
+package deployer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeMethodFrame(t *testing.T) {
+	// both contracts have the same selector of value(), with different outputs
+	tokenABI, err := abi.JSON(strings.NewReader(`[{
+		"name": "value", "type": "function", "stateMutability": "view",
+		"inputs": [], "outputs": [{ "name": "amount", "type": "uint256" }]
+	}]`))
+	require.NoError(t, err)
+
+	oracleABI, err := abi.JSON(strings.NewReader(`[{
+		"name": "value", "type": "function", "stateMutability": "view",
+		"inputs": [], "outputs": [{ "name": "price", "type": "int256" }]
+	}]`))
+	require.NoError(t, err)
+
+	contracts := []*knownContract{
+		{Name: "Token", ABI: tokenABI},
+		{Name: "Oracle", ABI: oracleABI},
+	}
+
+	oracleAddress := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	byAddress := map[common.Address]*knownContract{
+		oracleAddress: contracts[1],
+	}
+
+	frame := &CallFrame{
+		To:     oracleAddress,
+		Input:  oracleABI.Methods["value"].ID,
+		Output: common.LeftPadBytes([]byte{1}, 32),
+	}
+	assert.Equal(t, contracts[1], decodeMethodFrame(contracts, byAddress, frame))
+	assert.Equal(t, "Oracle", frame.Contract)
+	assert.Contains(t, frame.Outputs, "price")
+
+	// unknown address falls back to selector matching
+	frame = &CallFrame{
+		To:     common.HexToAddress("0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512"),
+		Input:  oracleABI.Methods["value"].ID,
+		Output: common.LeftPadBytes([]byte{1}, 32),
+	}
+	assert.Equal(t, contracts[0], decodeMethodFrame(contracts, byAddress, frame))
+	assert.Equal(t, "Token", frame.Contract)
+
+	// known address with unknown selector is not decoded with other ABIs
+	frame = &CallFrame{
+		To:    oracleAddress,
+		Input: []byte{0xde, 0xad, 0xbe, 0xef},
+	}
+	assert.Equal(t, contracts[1], decodeMethodFrame(contracts, byAddress, frame))
+	assert.Empty(t, frame.Method)
+}
+
+func TestMatchRuntimeCode(t *testing.T) {
+	binRuntime := []byte{0x60, 0x80, 0x00, 0x00, 0x56}
+
+	assert.True(t, matchRuntimeCode(binRuntime, []byte{0x60, 0x80, 0x12, 0x34, 0x56}))
+	assert.False(t, matchRuntimeCode(binRuntime, []byte{0x60, 0x81, 0x00, 0x00, 0x56}))
+	assert.False(t, matchRuntimeCode(binRuntime, []byte{0x60, 0x80, 0x00, 0x00}))
+	assert.False(t, matchRuntimeCode(nil, nil))
+}
+
+func TestArgumentNames(t *testing.T) {
+	uintType, _ := abi.NewType("uint256", "", nil)
+
+	args := make(abi.Arguments, 12)
+	for idx := range args {
+		args[idx] = abi.Argument{Type: uintType}
+	}
+	args[1].Name = "amount"
+
+	names := ArgumentNames(args)
+	require.Len(t, names, 12)
+	assert.Equal(t, []string{"arg0", "amount", "arg2"}, names[:3])
+	assert.Equal(t, "arg10", names[10])
+}
@@ -0,0 +1,138 @@
+package deployer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type TransferOpts struct {
+	From     common.Address
+	FromPk   *ecdsa.PrivateKey
+	SignerFn bind.SignerFn
+	To       common.Address
+	Value    *big.Int
+	// Nonce overrides the latest nonce of the sender, e.g. for many transactions sent concurrently.
+	Nonce *big.Int
+	Await bool
+}
+
+// Transfer sends value to the address, without calldata.
+func (d *deployer) Transfer(
+	ctx context.Context,
+	transferOpts TransferOpts,
+) (txHash common.Hash, err error) {
+	client, err := d.Backend()
+	if err != nil {
+		return noHash, err
+	}
+
+	chainCtx, cancelFn := context.WithTimeout(ctx, d.options.RPCTimeout)
+	defer cancelFn()
+
+	chainId, err := client.ChainID(chainCtx)
+	if err != nil {
+		log.WithError(err).Errorln("failed get valid chain ID")
+		return noHash, ErrNoChainID
+	}
+
+	var nonce uint64
+	if transferOpts.Nonce != nil {
+		nonce = transferOpts.Nonce.Uint64()
+	} else {
+		nonceCtx, cancelFn := context.WithTimeout(ctx, d.options.RPCTimeout)
+		defer cancelFn()
+
+		nonce, err = client.NonceAt(nonceCtx, transferOpts.From, nil)
+		if err != nil {
+			log.WithField("from", transferOpts.From.Hex()).WithError(err).Errorln("failed to get most recent nonce")
+			return noHash, ErrNoNonce
+		}
+	}
+
+	signerFn := transferOpts.SignerFn
+	if signerFn == nil {
+		signerFn, err = getSignerFn(d.options.SignerType, chainId, transferOpts.From, transferOpts.FromPk)
+		if err != nil {
+			log.WithError(err).Errorln("failed to get signer function")
+			return noHash, err
+		}
+	}
+
+	value := transferOpts.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	txCtx, cancelFn := context.WithTimeout(ctx, d.options.RPCTimeout)
+	defer cancelFn()
+
+	gasPrice := d.options.GasPrice
+	if gasPrice == nil {
+		gasPrice, err = client.SuggestGasPrice(txCtx)
+		if err != nil {
+			err = errors.Wrap(err, "failed to suggest gas price")
+			return noHash, err
+		}
+	}
+
+	gasLimit := d.options.GasLimit
+	if gasLimit == 0 {
+		// the recipient might be a contract with receive logic
+		msg := ethereum.CallMsg{
+			From:     transferOpts.From,
+			To:       &transferOpts.To,
+			GasPrice: gasPrice,
+			Value:    value,
+		}
+
+		gasLimit, err = client.EstimateGas(txCtx, msg)
+		if err != nil {
+			err = errors.Wrap(err, "failed to estimate gas needed")
+			return noHash, err
+		}
+
+		gasLimit = adjustGasEstimate(gasLimit, d.options.GasMultiplier, d.options.GasBuffer)
+	}
+
+	rawTx := types.NewTransaction(nonce, transferOpts.To, value, gasLimit, gasPrice, nil)
+
+	log.WithFields(log.Fields{
+		"nonce":    nonce,
+		"to":       transferOpts.To.Hex(),
+		"value":    value.String(),
+		"gasPrice": gasPrice.String(),
+		"gasLimit": gasLimit,
+	}).Debugln("broadcasting a transfer")
+
+	signedTx, err := signerFn(transferOpts.From, rawTx)
+	if err != nil {
+		err = errors.Wrap(err, "failed to sign transfer")
+		return noHash, err
+	}
+
+	txHash, err = client.SendTransactionWithRet(txCtx, signedTx)
+	if err != nil {
+		log.WithError(err).WithField("txHash", txHash.Hex()).Errorln("failed to send transaction")
+		return txHash, err
+	}
+
+	if transferOpts.Await {
+		awaitCtx, cancelFn := context.WithTimeout(ctx, d.options.TxTimeout)
+		defer cancelFn()
+
+		if _, err := awaitTx(awaitCtx, client, txHash); err != nil {
+			return txHash, err
+		}
+	}
+
+	return txHash, nil
+}
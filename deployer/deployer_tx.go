@@ -16,13 +16,15 @@ import (
 )
 
 type ContractTxOpts struct {
-	From          common.Address
-	FromPk        *ecdsa.PrivateKey
-	SignerFn      bind.SignerFn
-	SolSource     string
-	ContractName  string
-	Contract      common.Address
-	Value         *big.Int
+	From         common.Address
+	FromPk       *ecdsa.PrivateKey
+	SignerFn     bind.SignerFn
+	SolSource    string
+	ContractName string
+	Contract     common.Address
+	Value        *big.Int
+	// Nonce overrides the latest nonce of the sender, e.g. for many transactions sent concurrently.
+	Nonce         *big.Int
 	BytecodeOnly  bool
 	Await         bool
 	CoverageAgent CoverageDataCollector
@@ -85,13 +87,18 @@ func (d *deployer) Tx(
 		return noHash, nil, ErrNoChainID
 	}
 
-	nonceCtx, cancelFn := context.WithTimeout(context.Background(), d.options.RPCTimeout)
-	defer cancelFn()
+	var nonce uint64
+	if txOpts.Nonce != nil {
+		nonce = txOpts.Nonce.Uint64()
+	} else {
+		nonceCtx, cancelFn := context.WithTimeout(context.Background(), d.options.RPCTimeout)
+		defer cancelFn()
 
-	nonce, err := client.NonceAt(nonceCtx, txOpts.From, nil)
-	if err != nil {
-		log.WithField("from", txOpts.From.Hex()).WithError(err).Errorln("failed to get most recent nonce")
-		return noHash, nil, ErrNoNonce
+		nonce, err = client.NonceAt(nonceCtx, txOpts.From, nil)
+		if err != nil {
+			log.WithField("from", txOpts.From.Hex()).WithError(err).Errorln("failed to get most recent nonce")
+			return noHash, nil, ErrNoNonce
+		}
 	}
 
 	boundContract, err := BindContract(client.Client, contract)
@@ -103,33 +110,22 @@ func (d *deployer) Tx(
 	callCtx, cancelFn := context.WithTimeout(context.Background(), d.options.CallTimeout)
 	defer cancelFn()
 
-	var coverageEventABI abi.Event
-	var coverageTopic common.Hash
+	var coverageEvents map[common.Hash]abi.Event
 
-	if d.options.EnableCoverage {
-		_, coverageEventABI, err = d.GetCoverageEventInfo(callCtx, txOpts.From, contract.Name, contract.Address)
+	traceCoverage := d.coverageStrategy() == CoverageStrategyTrace && txOpts.CoverageAgent != nil
+	if traceCoverage {
+		loadCoverageStatements(txOpts.CoverageAgent, contract)
+	} else if d.options.EnableCoverage {
+		_, coverageEventABI, err := d.GetCoverageEventInfo(callCtx, txOpts.From, contract.Name, contract.Address)
 		if err != ErrNoCoverage {
 			if err != nil {
 				return noHash, nil, err
 			}
 
-			coverageTopic = coverageEventABI.ID
+			coverageEvents = contractCoverageEvents(contract, coverageEventABI)
 
 			if txOpts.CoverageAgent != nil {
-				if err := txOpts.CoverageAgent.LoadContract(contract); err != nil {
-					log.WithError(err).Errorln("failed to open referenced dependecies for coverage reporting")
-				}
-				for _, statement := range contract.Statements {
-					if statement[0] < 0 || statement[1] < 0 || statement[2] < 0 {
-						continue
-					}
-
-					txOpts.CoverageAgent.AddStatement(contract.Name,
-						uint64(statement[0]),
-						uint64(statement[1]),
-						uint64(statement[2]),
-					)
-				}
+				loadCoverageStatements(txOpts.CoverageAgent, contract)
 			}
 		}
 	}
@@ -145,7 +141,7 @@ func (d *deployer) Tx(
 		mappedArgs = methodInputMapper(method.Inputs)
 	}
 
-	boundContract.SetTransact(getTransactFn(client, contract.Address, &txHash))
+	boundContract.SetTransact(getTransactFn(client, contract.Address, d.options.GasMultiplier, d.options.GasBuffer, &txHash))
 
 	txCtx, cancelFn := context.WithTimeout(context.Background(), d.options.RPCTimeout)
 	defer cancelFn()
@@ -180,17 +176,23 @@ func (d *deployer) Tx(
 
 	txData, err := boundContract.Transact(ethTxOpts, methodName, mappedArgs...)
 	if err != nil {
-		if hasCoverageReport(err) {
-			if txOpts.CoverageAgent != nil {
-				coverageReportErr := txOpts.CoverageAgent.CollectCoverageRevert(contract.Name, err)
-				if coverageReportErr != nil {
-					log.WithError(coverageReportErr).Warningln("failed to collect coverage revert event")
-				}
+		if traceCoverage && txHash == noHash {
+			// tx was not sent, most likely failed at gas estimation, so trace it as a call
+			input, _ := boundContract.ABI().Pack(methodName, mappedArgs...)
+			msg := ethereum.CallMsg{
+				From:  txOpts.From,
+				To:    &contract.Address,
+				Value: txOpts.Value,
+				Data:  input,
 			}
 
-			err = trimCoverageReport(err)
+			if coverageErr := d.collectCallTraceCoverage(txOpts.CoverageAgent, contract, msg, nil); coverageErr != nil {
+				log.WithError(coverageErr).Warningln("failed to collect coverage from call trace")
+			}
 		}
 
+		err = collectCoverageRevert(txOpts.CoverageAgent, contract, err)
+
 		log.WithError(err).WithField("txHash", txHash.Hex()).Errorln("failed to send transaction")
 		return txHash, nil, err
 	}
@@ -202,46 +204,33 @@ func (d *deployer) Tx(
 		log.WithField("contract", contract.Address.Hex()).Debugln("awaiting tx", txHash.Hex())
 
 		blockNum, err := awaitTx(awaitCtx, client, txHash)
+		if traceCoverage && (err == nil || err == ErrTransactionReverted) {
+			defer func() {
+				if coverageErr := d.collectTxTraceCoverage(txOpts.CoverageAgent, contract, txHash, false); coverageErr != nil {
+					log.WithError(coverageErr).Warningln("failed to collect coverage from transaction trace")
+				}
+			}()
+		}
 
 		if err == ErrTransactionReverted {
 			// attempt to get reason
 			reason, err := getRevertReason(ctx, txOpts.From, contract.Address, client, txData.Data(), blockNum)
 			if err == nil && len(reason) > 0 {
 				err = errors.New(reason)
-
-				if hasCoverageReport(err) {
-					if txOpts.CoverageAgent != nil {
-						coverageReportErr := txOpts.CoverageAgent.CollectCoverageRevert(contract.Name, err)
-						if coverageReportErr != nil {
-							log.WithError(coverageReportErr).Warningln("failed to collect coverage revert event")
-						}
-					}
-
-					err = trimCoverageReport(err)
-				}
-
 				return txHash, nil, err
+			} else if revertErr := collectCoverageRevert(txOpts.CoverageAgent, contract, err); revertErr != err {
+				// the call reverted with the coverage error, it wraps the original reason
+				return txHash, nil, revertErr
 			} else if err != nil {
 				log.WithError(err).Warningln("failed to get revert reason")
 				return txHash, nil, err
 			}
 		} else if err != nil {
-			if hasCoverageReport(err) {
-				if txOpts.CoverageAgent != nil {
-					coverageReportErr := txOpts.CoverageAgent.CollectCoverageRevert(contract.Name, err)
-					if coverageReportErr != nil {
-						log.WithError(coverageReportErr).Warningln("failed to collect coverage revert event")
-					}
-				}
-
-				err = trimCoverageReport(err)
-			}
-
 			return txHash, nil, err
 		}
 	}
 
-	if d.options.EnableCoverage && txOpts.CoverageAgent != nil && txHash != noHash {
+	if d.coverageStrategy() == CoverageStrategyInstrument && txOpts.CoverageAgent != nil && txHash != noHash {
 		callCtx, cancelFn = context.WithTimeout(context.Background(), d.options.CallTimeout)
 		defer cancelFn()
 
@@ -260,7 +249,7 @@ func (d *deployer) Tx(
 		for _, ethLog := range receipt.Logs {
 			if ethLog == nil || len(ethLog.Topics) == 0 {
 				continue
-			} else if ethLog.Topics[0] == coverageTopic {
+			} else if coverageEventABI, ok := coverageEvents[ethLog.Topics[0]]; ok {
 				if txOpts.CoverageAgent != nil {
 					if err := txOpts.CoverageAgent.CollectCoverageEvent(contract.Name, coverageEventABI, ethLog); err != nil {
 						log.WithError(err).WithField("contract", contract.Name).Warningln("failed to collect coverage event from contract")
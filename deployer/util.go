@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
+	"math"
 	"math/big"
 	"path/filepath"
 	"strings"
@@ -79,6 +80,39 @@ func getRevertReason(
 	return "", ErrNoRevertReason
 }
 
+// DecodeRevertData turns raw revert data into a readable error, trying the standard
+// Error(string) and Panic(uint256) encodings first, then custom errors from the contract ABI.
+func DecodeRevertData(contractABI *abi.ABI, data []byte) error {
+	if len(data) == 0 {
+		return errors.New("execution reverted")
+	}
+
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return errors.Errorf("execution reverted: %s", reason)
+	}
+
+	if contractABI != nil && len(data) >= 4 {
+		var errorID [4]byte
+		copy(errorID[:], data[:4])
+
+		if abiErr, err := contractABI.ErrorByID(errorID); err == nil {
+			values, err := abiErr.Unpack(data)
+			if err == nil {
+				return errors.Errorf("execution reverted: %s%v", abiErr.Name, values)
+			}
+		}
+	}
+
+	return errors.Errorf("execution reverted: %s", hexutil.Encode(data))
+}
+
+// isRevertError reports whether a call failed due to revert, based on the error message of the node.
+func isRevertError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "execution reverted") ||
+		strings.Contains(msg, "vm exception while processing transaction: revert")
+}
+
 var errorReasonPrefix, _ = hexutil.Decode("0x08c379a0")
 
 var errorABI, _ = abi.JSON(strings.NewReader(errorABIJSON))
@@ -132,7 +166,39 @@ func awaitTx(ctx context.Context, client *Client, txHash common.Hash) (blockNum
 	}
 }
 
+// coverageStrategy returns empty strategy if coverage is not enabled.
+func (d *deployer) coverageStrategy() CoverageStrategy {
+	if !d.options.EnableCoverage {
+		return ""
+	}
+
+	return d.options.CoverageStrategy
+}
+
 func (d *deployer) getCompiledContract(contractName, solFullPath string) *sol.Contract {
+	memoKey := fmt.Sprintf("%s:%s:%s", solFullPath, contractName, d.coverageStrategy())
+
+	d.compiledMux.Lock()
+	defer d.compiledMux.Unlock()
+
+	if contract, ok := d.compiled[memoKey]; ok {
+		// callers are allowed to modify the returned contract (e.g. set address)
+		contractCopy := *contract
+		return &contractCopy
+	}
+
+	contract := d.compileContract(contractName, solFullPath)
+	if contract == nil {
+		return nil
+	}
+
+	d.compiled[memoKey] = contract
+
+	contractCopy := *contract
+	return &contractCopy
+}
+
+func (d *deployer) compileContract(contractName, solFullPath string) *sol.Contract {
 	if !d.options.NoCache {
 		cacheLog := log.WithField("path", d.options.BuildCacheDir)
 
@@ -140,7 +206,7 @@ func (d *deployer) getCompiledContract(contractName, solFullPath string) *sol.Co
 		if err != nil {
 			cacheLog.WithError(err).Warningln("failed to use build cache dir")
 		} else {
-			contract, err := cache.LoadContract(solFullPath, contractName, d.options.EnableCoverage)
+			contract, err := cache.LoadContract(solFullPath, contractName, d.coverageStrategy())
 			if err != nil {
 				if err != ErrNoCache {
 					// generic error
@@ -163,10 +229,13 @@ func (d *deployer) getCompiledContract(contractName, solFullPath string) *sol.Co
 		contracts map[string]*sol.Contract
 	)
 
-	if d.options.EnableCoverage {
+	switch d.coverageStrategy() {
+	case CoverageStrategyInstrument:
 		// this is going to orchestrate sources accordingly
 		contracts, err = d.compiler.CompileWithCoverage(filepath.Dir(solFullPath), filepath.Base(solFullPath))
-	} else {
+	case CoverageStrategyTrace:
+		contracts, err = d.compiler.CompileWithSourceMaps(filepath.Dir(solFullPath), filepath.Base(solFullPath))
+	default:
 		contracts, err = d.compiler.Compile(filepath.Dir(solFullPath), filepath.Base(solFullPath), 200)
 	}
 
@@ -174,7 +243,7 @@ func (d *deployer) getCompiledContract(contractName, solFullPath string) *sol.Co
 		log.WithFields(log.Fields{
 			"dir":      filepath.Dir(solFullPath),
 			"file":     filepath.Base(solFullPath),
-			"coverage": d.options.EnableCoverage,
+			"coverage": d.coverageStrategy(),
 		}).WithError(err).Errorln("failed to compile .sol files")
 
 		return nil
@@ -259,7 +328,34 @@ func getSignerFn(
 	}
 }
 
-func getTransactFn(ec *Client, contractAddress common.Address, txHashOut *common.Hash) TransactFunc {
+// adjustGasEstimate turns estimated gas into a gas limit, leaving a margin for
+// state changes between estimation and inclusion. The multiplied gas is rounded up,
+// so the margin isn't lost, and the result is capped at max uint64.
+func adjustGasEstimate(estimated uint64, multiplier float64, buffer uint64) uint64 {
+	limit := estimated
+	if multiplier > 1 {
+		multiplied := math.Ceil(float64(estimated) * multiplier)
+		if multiplied >= math.MaxUint64 {
+			return math.MaxUint64
+		}
+
+		limit = uint64(multiplied)
+	}
+
+	if limit > math.MaxUint64-buffer {
+		return math.MaxUint64
+	}
+
+	return limit + buffer
+}
+
+func getTransactFn(
+	ec *Client,
+	contractAddress common.Address,
+	gasMultiplier float64,
+	gasBuffer uint64,
+	txHashOut *common.Hash,
+) TransactFunc {
 	return func(opts *bind.TransactOpts, contract *common.Address, input []byte) (*types.Transaction, error) {
 		var err error
 
@@ -299,8 +395,10 @@ func getTransactFn(ec *Client, contractAddress common.Address, txHashOut *common
 			msg := ethereum.CallMsg{From: opts.From, To: contract, GasPrice: gasPrice, Value: value, Data: input}
 			gasLimit, err = ec.EstimateGas(opts.Context, msg)
 			if err != nil {
-				return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+				return nil, fmt.Errorf("failed to estimate gas needed: %w", err)
 			}
+
+			gasLimit = adjustGasEstimate(gasLimit, gasMultiplier, gasBuffer)
 		}
 		// Create the transaction, sign it and schedule it for execution
 		var rawTx *types.Transaction
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	cli "github.com/jawher/mow.cli"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/etherman/deployer"
+)
+
+func onEstimate(cmd *cli.Cmd) {
+	cmd.Command("deploy", "Estimates gas and cost of the contract deployment.", onEstimateDeploy)
+	cmd.Command("tx", "Estimates gas and cost of the transaction for particular contract method.", onEstimateTx)
+}
+
+func newEstimateDeployer() deployer.Deployer {
+	d, err := deployer.New(
+		deployer.OptionRPCTimeout(duration(*rpcTimeout, defaultRPCTimeout)),
+		deployer.OptionCallTimeout(duration(*callTimeout, defaultCallTimeout)),
+
+		// only options applicable to estimate
+		deployer.OptionEVMRPCEndpoint(*evmEndpoint),
+		deployer.OptionGasPrice(big.NewInt(int64(*gasPrice))),
+		deployer.OptionGasEstimateAdjustment(*gasMultiplier, gasBufferValue(*gasBuffer)),
+		deployer.OptionNoCache(*noCache),
+		deployer.OptionBuildCacheDir(*buildCacheDir),
+		deployer.OptionSolcAllowedPaths(*solAllowedPaths),
+	)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to init deployer")
+	}
+
+	return d
+}
+
+func onEstimateDeploy(cmd *cli.Cmd) {
+	contractArgs := cmd.StringsArg("ARGS", []string{}, "Contract constructor's arguments. Will be ABI-encoded.")
+
+	cmd.Spec = "[ARGS...]"
+
+	cmd.Action = func() {
+		d := newEstimateDeployer()
+
+		fromAddress, err := resolveFromAddress(signerOpts)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to get from address")
+		}
+
+		estimate, err := d.EstimateDeploy(
+			context.Background(),
+			deployer.ContractDeployOpts{
+				From:         fromAddress,
+				SolSource:    *solSource,
+				ContractName: *contractName,
+			},
+			func(args abi.Arguments) []interface{} {
+				mappedArgs, err := mapStringArgs(args, *contractArgs)
+				if err != nil {
+					log.WithError(err).Fatalln("failed to map constructor args")
+					return nil
+				}
+
+				return mappedArgs
+			},
+		)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		v, _ := json.MarshalIndent(estimate, "", "\t")
+		fmt.Println(string(v))
+	}
+}
+
+func onEstimateTx(cmd *cli.Cmd) {
+	contractAddress := cmd.StringArg("ADDRESS", "", "Contract address to interact with.")
+	methodName := cmd.StringArg("METHOD", "", "Contract method to transact.")
+	methodArgs := cmd.StringsArg("ARGS", []string{}, "Method transaction arguments. Will be ABI-encoded.")
+	valueArg := cmd.StringOpt("value", "0", "Value to be sent along with the transaction")
+
+	cmd.Spec = "[--value] ADDRESS METHOD [ARGS...]"
+
+	cmd.Action = func() {
+		d := newEstimateDeployer()
+
+		fromAddress, err := resolveFromAddress(signerOpts)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to get from address")
+		}
+
+		value, ok := new(big.Int).SetString(*valueArg, 10)
+		if !ok {
+			log.Fatalln("failed to parse value flag")
+		}
+
+		estimate, err := d.EstimateTx(
+			context.Background(),
+			deployer.ContractTxOpts{
+				From:         fromAddress,
+				SolSource:    *solSource,
+				ContractName: *contractName,
+				Contract:     common.HexToAddress(*contractAddress),
+				Value:        value,
+			},
+			*methodName,
+			func(args abi.Arguments) []interface{} {
+				mappedArgs, err := mapStringArgs(args, *methodArgs)
+				if err != nil {
+					log.WithError(err).Fatalln("failed to map method args")
+					return nil
+				}
+
+				return mappedArgs
+			},
+		)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		v, _ := json.MarshalIndent(estimate, "", "\t")
+		fmt.Println(string(v))
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	ethcmn "github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	cli "github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+	"golang.org/x/term"
+
+	"github.com/InjectiveLabs/etherman/keystore"
+)
+
+func onKeys(cmd *cli.Cmd) {
+	cmd.Command("new", "Generates a new key and stores it encrypted in --keystore-dir.", onKeysNew)
+	cmd.Command("import", "Imports a hex private key, or a file with it, into --keystore-dir.", onKeysImport)
+	cmd.Command("export", "Decrypts the key of the account and prints it in hex.", onKeysExport)
+	cmd.Command("list", "Lists accounts and key files in --keystore-dir.", onKeysList)
+	cmd.Command("change-password", "Re-encrypts the key file of the account with a new password.", onKeysChangePassword)
+	cmd.Command("derive", "Lists accounts derived from --mnemonic under --hd-path, starting at --hd-index.", onKeysDerive)
+	cmd.Command("ledger", "Lists accounts of the Ledger device under --ledger-path, starting at --hd-index.", onKeysLedger)
+}
+
+func onKeysNew(cmd *cli.Cmd) {
+	scryptParams := readScryptOptions(cmd)
+
+	cmd.Spec = "[OPTIONS]"
+
+	cmd.Action = func() {
+		ks := openKeystore(true)
+
+		pass, err := newPassphrase(*fromPassphrase)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		account, err := ks.NewKey(*keystoreDir, pass, scryptParams())
+		if err != nil {
+			log.WithError(err).Fatalln("failed to create key")
+		}
+
+		fmt.Println(account.Hex())
+	}
+}
+
+func onKeysImport(cmd *cli.Cmd) {
+	scryptParams := readScryptOptions(cmd)
+	key := cmd.StringArg("KEY", "", "Private key in hex, or path to a file with it, in any format of --from-pk-file.")
+
+	cmd.Spec = "[OPTIONS] KEY"
+
+	cmd.Action = func() {
+		var (
+			pk  *ecdsa.PrivateKey
+			err error
+		)
+
+		if info, statErr := os.Stat(*key); statErr == nil && info.Mode().IsRegular() {
+			f, err := os.Open(*key)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to read private key file")
+			}
+
+			pk, err = keystore.ReadPrivateKey(f, unlockPassphrase)
+			_ = f.Close()
+		} else {
+			pk, err = keystore.ParsePrivateKey(*key)
+		}
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		ks := openKeystore(true)
+
+		pass, err := newPassphrase(*fromPassphrase)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		account, err := ks.ImportKey(*keystoreDir, pk, pass, scryptParams())
+		if err != nil {
+			log.WithError(err).Fatalln("failed to import key")
+		}
+
+		fmt.Println(account.Hex())
+	}
+}
+
+func onKeysExport(cmd *cli.Cmd) {
+	encrypt := cmd.BoolOpt("encrypt", false, "Print the key as age file encrypted with a new passphrase, to use with --from-pk-file.")
+	newPass := cmd.String(cli.StringOpt{
+		Name:   "new-passphrase",
+		Desc:   "Passphrase to encrypt the exported key with, if empty then stdin is used.",
+		EnvVar: "DEPLOYER_NEW_PASSPHRASE",
+	})
+	address := cmd.StringArg("ADDRESS", "", "Account to export, --from is used if not set.")
+
+	cmd.Spec = "[--encrypt [--new-passphrase]] [ADDRESS]"
+
+	cmd.Action = func() {
+		account := keysAccount(*address)
+		ks := openKeystore(false)
+
+		pass, err := unlockPassphrase()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		pk, err := ks.PrivateKey(account, pass)
+		if err != nil {
+			log.WithError(err).Fatalf("failed to load key for %s", account)
+		}
+
+		if !*encrypt {
+			fmt.Println(hex.EncodeToString(ethcrypto.FromECDSA(pk)))
+			return
+		}
+
+		newPass, err := newPassphrase(*newPass)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		blob, err := keystore.EncryptPrivateKey(pk, newPass, 0)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		fmt.Print(string(blob))
+	}
+}
+
+func onKeysList(cmd *cli.Cmd) {
+	cmd.Action = func() {
+		ks := openKeystore(false)
+
+		for _, wallet := range ks.Wallets() {
+			fmt.Printf("%s\t%s\n", wallet.AddressFromHex().Hex(), wallet.Path)
+		}
+	}
+}
+
+func onKeysChangePassword(cmd *cli.Cmd) {
+	scryptParams := readScryptOptions(cmd)
+	newPass := cmd.String(cli.StringOpt{
+		Name:   "new-passphrase",
+		Desc:   "New passphrase of the key file, if empty then stdin is used.",
+		EnvVar: "DEPLOYER_NEW_PASSPHRASE",
+	})
+	address := cmd.StringArg("ADDRESS", "", "Account to re-encrypt, --from is used if not set.")
+
+	cmd.Spec = "[OPTIONS] [ADDRESS]"
+
+	cmd.Action = func() {
+		account := keysAccount(*address)
+		ks := openKeystore(false)
+
+		pass, err := unlockPassphrase()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		newPass, err := newPassphrase(*newPass)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if err := ks.ChangePassword(account, pass, newPass, scryptParams()); err != nil {
+			log.WithError(err).Fatalf("failed to change password for %s", account)
+		}
+	}
+}
+
+func onKeysDerive(cmd *cli.Cmd) {
+	count := cmd.IntOpt("n count", 10, "Number of accounts to derive.")
+	showKeys := cmd.BoolOpt("show-keys", false, "Print private keys of derived accounts in hex.")
+
+	cmd.Spec = "[OPTIONS]"
+
+	cmd.Action = func() {
+		if len(*mnemonic) == 0 && len(*mnemonicFile) == 0 {
+			log.Fatalln("--mnemonic or --mnemonic-file must be specified")
+		} else if *hdIndex < 0 {
+			log.Fatalln("--hd-index must not be negative")
+		}
+
+		phrase, err := readMnemonic(mnemonic, mnemonicFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		wallet, err := keystore.NewHDWallet(phrase, "")
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		for idx := *hdIndex; idx < *hdIndex+*count; idx++ {
+			path, err := keystore.ParseHDPath(*hdPath, uint32(idx))
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			pk, err := wallet.Derive(path)
+			if err != nil {
+				log.WithError(err).Fatalf("failed to derive key at %s", path.String())
+			}
+
+			address := ethcrypto.PubkeyToAddress(pk.PublicKey).Hex()
+			if *showKeys {
+				fmt.Printf("%d\t%s\t%s\t%s\n", idx, address, path.String(), hex.EncodeToString(ethcrypto.FromECDSA(pk)))
+				continue
+			}
+
+			fmt.Printf("%d\t%s\t%s\n", idx, address, path.String())
+		}
+	}
+}
+
+func onKeysLedger(cmd *cli.Cmd) {
+	count := cmd.IntOpt("n count", keystore.DefaultLedgerAccounts, "Number of accounts to derive.")
+
+	cmd.Spec = "[OPTIONS]"
+
+	cmd.Action = func() {
+		if *hdIndex < 0 {
+			log.Fatalln("--hd-index must not be negative")
+		}
+
+		ledgerWallet, pathFn, err := openLedgerWallet(ledgerPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer ledgerWallet.Close()
+
+		found, err := keystore.DiscoverLedgerAccounts(ledgerWallet, pathFn, uint32(*hdIndex), *count)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		for i, acc := range found {
+			path, _ := pathFn(uint32(*hdIndex + i))
+			fmt.Printf("%d\t%s\t%s\n", *hdIndex+i, acc.Address.Hex(), path.String())
+		}
+	}
+}
+
+// readScryptOptions adds KDF options of key files being written to the command.
+func readScryptOptions(cmd *cli.Cmd) func() keystore.ScryptParams {
+	scryptN := cmd.IntOpt("scrypt-n", keystore.StandardScryptParams.N, "Scrypt CPU/memory cost parameter N of the key file, a power of 2.")
+	scryptP := cmd.IntOpt("scrypt-p", keystore.StandardScryptParams.P, "Scrypt parallelization parameter P of the key file.")
+	lightKDF := cmd.BoolOpt("light-kdf", false, "Use light scrypt parameters, weak but fast. For test keys only.")
+
+	return func() keystore.ScryptParams {
+		if *lightKDF {
+			return keystore.LightScryptParams
+		}
+
+		if *scryptN <= 1 || *scryptN&(*scryptN-1) != 0 {
+			log.Fatalln("--scrypt-n must be a power of 2")
+		} else if *scryptP < 1 {
+			log.Fatalln("--scrypt-p must be positive")
+		}
+
+		return keystore.ScryptParams{
+			N: *scryptN,
+			P: *scryptP,
+		}
+	}
+}
+
+func openKeystore(create bool) keystore.EthKeyStore {
+	if len(*keystoreDir) == 0 {
+		log.Fatalln("--keystore-dir must be specified")
+	}
+
+	if create {
+		if err := os.MkdirAll(*keystoreDir, 0700); err != nil {
+			log.WithError(err).Fatalln("failed to create keystore dir")
+		}
+	} else if info, err := os.Stat(*keystoreDir); err != nil || !info.IsDir() {
+		log.Fatalln("failed to locate keystore dir")
+	}
+
+	ks, err := keystore.NewWithOptions(
+		[]string{*keystoreDir},
+		keystore.OptionRecursive(*keystoreRecursive),
+	)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to load keystore")
+	}
+
+	return ks
+}
+
+func keysAccount(address string) ethcmn.Address {
+	if len(address) == 0 {
+		address = *from
+	}
+
+	if !ethcmn.IsHexAddress(address) {
+		log.Fatalln("account address must be specified as argument or with --from")
+	}
+
+	return ethcmn.HexToAddress(address)
+}
+
+func unlockPassphrase() (string, error) {
+	if len(*fromPassphrase) > 0 {
+		return *fromPassphrase, nil
+	}
+
+	return ethPassFromStdin()
+}
+
+// newPassphrase returns the passphrase if set, otherwise reads it from stdin twice to confirm.
+func newPassphrase(pass string) (string, error) {
+	if len(pass) > 0 {
+		return pass, nil
+	}
+
+	readPass := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			err := errors.Wrap(err, "failed to read password from stdin")
+			return "", err
+		}
+
+		return strings.TrimSpace(string(bytePassword)), nil
+	}
+
+	pass, err := readPass("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	confirmed, err := readPass("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	} else if pass != confirmed {
+		return "", errors.New("passphrases do not match")
+	}
+
+	return pass, nil
+}
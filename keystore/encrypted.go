@@ -0,0 +1,123 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+const ageHeader = "age-encryption.org/v1"
+
+// maxKeyInputSize limits private key input, so a wrong file or descriptor is not read entirely.
+const maxKeyInputSize = 64 * 1024
+
+// PassphraseFn returns the passphrase of an encrypted key, e.g. by prompting the user.
+type PassphraseFn func() (string, error)
+
+// ReadPrivateKey reads the private key in hex, a passphrase-encrypted age file (binary or armored)
+// with the hex key inside, or a V3 key file. The passphrase is requested for encrypted keys only.
+func ReadPrivateKey(r io.Reader, passphraseFn PassphraseFn) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxKeyInputSize))
+	if err != nil {
+		err = errors.Wrap(err, "failed to read private key")
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(data, []byte(armor.Header)), bytes.HasPrefix(data, []byte(ageHeader)):
+		passphrase, err := passphraseFn()
+		if err != nil {
+			return nil, err
+		}
+
+		return decryptAgeKey(data, passphrase)
+
+	case bytes.HasPrefix(data, []byte("{")):
+		passphrase, err := passphraseFn()
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := keystore.DecryptKey(data, passphrase)
+		if err != nil {
+			err = errors.Wrap(err, "failed to decrypt key file")
+			return nil, err
+		}
+
+		return key.PrivateKey, nil
+
+	default:
+		return ParsePrivateKey(string(data))
+	}
+}
+
+func decryptAgeKey(data []byte, passphrase string) (*ecdsa.PrivateKey, error) {
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte(armor.Header)) {
+		src = armor.NewReader(src)
+	}
+
+	plain, err := age.Decrypt(src, identity)
+	if err != nil {
+		err = errors.Wrap(err, "failed to decrypt private key")
+		return nil, err
+	}
+
+	pkHex, err := ioutil.ReadAll(io.LimitReader(plain, maxKeyInputSize))
+	if err != nil {
+		err = errors.Wrap(err, "failed to decrypt private key")
+		return nil, err
+	}
+
+	return ParsePrivateKey(string(pkHex))
+}
+
+// EncryptPrivateKey encrypts the hex key with the passphrase into an armored age file,
+// which can be decrypted with `age -d` as well. Zero work factor keeps the age default.
+func EncryptPrivateKey(pk *ecdsa.PrivateKey, passphrase string, workFactor int) ([]byte, error) {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if workFactor > 0 {
+		recipient.SetWorkFactor(workFactor)
+	}
+
+	buf := new(bytes.Buffer)
+	armored := armor.NewWriter(buf)
+
+	w, err := age.Encrypt(armored, recipient)
+	if err != nil {
+		err = errors.Wrap(err, "failed to encrypt private key")
+		return nil, err
+	}
+
+	if _, err := io.WriteString(w, hex.EncodeToString(crypto.FromECDSA(pk))+"\n"); err != nil {
+		err = errors.Wrap(err, "failed to encrypt private key")
+		return nil, err
+	} else if err := w.Close(); err != nil {
+		err = errors.Wrap(err, "failed to encrypt private key")
+		return nil, err
+	} else if err := armored.Close(); err != nil {
+		err = errors.Wrap(err, "failed to encrypt private key")
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPrivateKey(t *testing.T) {
+	pk, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(pk.PublicKey)
+
+	var prompts int
+	passphraseFn := func() (string, error) {
+		prompts++
+		return "pass", nil
+	}
+
+	// plain hex is read without prompt
+	plain, err := ReadPrivateKey(strings.NewReader("0x"+hex.EncodeToString(crypto.FromECDSA(pk))+"\n"), passphraseFn)
+	require.NoError(t, err)
+	assert.Equal(t, account, crypto.PubkeyToAddress(plain.PublicKey))
+	assert.Zero(t, prompts)
+
+	// armored age file, with light work factor for tests
+	blob, err := EncryptPrivateKey(pk, "pass", 10)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(blob, []byte("-----BEGIN AGE ENCRYPTED FILE-----")))
+
+	decrypted, err := ReadPrivateKey(bytes.NewReader(blob), passphraseFn)
+	require.NoError(t, err)
+	assert.Equal(t, account, crypto.PubkeyToAddress(decrypted.PublicKey))
+	assert.Equal(t, 1, prompts)
+
+	_, err = ReadPrivateKey(bytes.NewReader(blob), func() (string, error) {
+		return "wrong", nil
+	})
+	assert.Error(t, err)
+
+	// V3 key file
+	keyJSON, err := keystore.EncryptKey(&keystore.Key{
+		Id:         uuid.New(),
+		Address:    account,
+		PrivateKey: pk,
+	}, "pass", LightScryptParams.N, LightScryptParams.P)
+	require.NoError(t, err)
+
+	decrypted, err = ReadPrivateKey(bytes.NewReader(keyJSON), passphraseFn)
+	require.NoError(t, err)
+	assert.Equal(t, account, crypto.PubkeyToAddress(decrypted.PublicKey))
+	assert.Equal(t, 2, prompts)
+
+	_, err = ReadPrivateKey(strings.NewReader("not a key"), passphraseFn)
+	assert.Error(t, err)
+}
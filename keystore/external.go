@@ -0,0 +1,151 @@
+package keystore
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+// ExternalSigner signs with keys held by an external signer, such as Clef, serving
+// account_signTransaction and account_signData over HTTP or IPC. Keys never leave the signer.
+type ExternalSigner struct {
+	endpoint string
+	signer   *external.ExternalSigner
+
+	// client calls methods not covered by the Geth external signer, e.g. account_signTypedData
+	client *rpc.Client
+}
+
+// NewExternalSigner connects to the signer at the HTTP(S) URL or IPC socket path
+// and checks that it responds to account_version.
+func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
+	signer, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to connect to external signer at %s", endpoint)
+		return nil, err
+	}
+
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to connect to external signer at %s", endpoint)
+		return nil, err
+	}
+
+	s := &ExternalSigner{
+		endpoint: endpoint,
+		signer:   signer,
+		client:   client,
+	}
+
+	return s, nil
+}
+
+// Accounts lists accounts managed by the signer, Clef asks for approval of that.
+func (s *ExternalSigner) Accounts() []common.Address {
+	var addresses []common.Address
+	for _, account := range s.signer.Accounts() {
+		addresses = append(addresses, account.Address)
+	}
+
+	return addresses
+}
+
+// SignerFn returns a SignerFn that asks the external signer to sign transactions of the account.
+// Signed transactions are rejected if the signer altered them or the signature doesn't recover to the account.
+func (s *ExternalSigner) SignerFn(chainID uint64, account common.Address) SignerFn {
+	chainIDInt := new(big.Int).SetUint64(chainID)
+	txSigner := types.LatestSignerForChainID(chainIDInt)
+
+	return func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if from != account {
+			return nil, bind.ErrNotAuthorized
+		}
+
+		signedTx, err := s.signer.SignTx(accounts.Account{Address: account}, tx, chainIDInt)
+		if err != nil {
+			err = errors.Wrapf(err, "external signer at %s failed to sign tx", s.endpoint)
+			return nil, err
+		} else if signedTx == nil {
+			return nil, errors.Errorf("external signer at %s returned no tx", s.endpoint)
+		}
+
+		if txSigner.Hash(signedTx) != txSigner.Hash(tx) {
+			return nil, errors.Errorf("external signer at %s returned a different tx", s.endpoint)
+		}
+
+		sender, err := types.Sender(txSigner, signedTx)
+		if err != nil {
+			err = errors.Wrap(err, "failed to recover sender of the signed tx")
+			return nil, err
+		} else if sender != account {
+			return nil, errors.Errorf("tx signed by %s instead of %s", sender.Hex(), account.Hex())
+		}
+
+		return signedTx, nil
+	}
+}
+
+// PersonalSignFn returns a PersonalSignFn that asks the external signer to sign
+// EIP-191 text messages of the account, V of signatures is 0 or 1.
+func (s *ExternalSigner) PersonalSignFn(account common.Address) PersonalSignFn {
+	return func(from common.Address, data []byte) (sig []byte, err error) {
+		if from != account {
+			return nil, errors.New("from address mismatch")
+		}
+
+		sig, err = s.signer.SignText(accounts.Account{Address: account}, data)
+		if err != nil {
+			err = errors.Wrapf(err, "external signer at %s failed to sign data", s.endpoint)
+			return nil, err
+		} else if len(sig) != crypto.SignatureLength {
+			return nil, errors.Errorf("external signer at %s returned invalid signature", s.endpoint)
+		}
+
+		if err := verifySignature(accounts.TextHash(data), sig, account); err != nil {
+			return nil, err
+		}
+
+		return sig, nil
+	}
+}
+
+// TypedDataSignFn returns a TypedDataSignFn that asks the external signer to sign
+// EIP-712 typed data of the account with account_signTypedData.
+func (s *ExternalSigner) TypedDataSignFn(account common.Address) TypedDataSignFn {
+	return func(from common.Address, typedData apitypes.TypedData) (sig []byte, err error) {
+		if from != account {
+			return nil, errors.New("from address mismatch")
+		}
+
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			err = errors.Wrap(err, "failed to hash typed data")
+			return nil, err
+		}
+
+		var res hexutil.Bytes
+		signAddress := common.NewMixedcaseAddress(account)
+		if err := s.client.Call(&res, "account_signTypedData", &signAddress, typedData); err != nil {
+			err = errors.Wrapf(err, "external signer at %s failed to sign typed data", s.endpoint)
+			return nil, err
+		} else if len(res) != crypto.SignatureLength {
+			return nil, errors.Errorf("external signer at %s returned invalid signature", s.endpoint)
+		}
+
+		sig = NormalizeSignatureV(res)
+		if err := verifySignature(hash, sig, account); err != nil {
+			return nil, err
+		}
+
+		return sig, nil
+	}
+}
@@ -0,0 +1,253 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSigner stands in for Clef, serving the account namespace of its external API.
+type stubSigner struct {
+	key *ecdsa.PrivateKey
+
+	// tamper alters the tx before signing, like a signer that doesn't sign what's asked
+	tamper func(tx *types.Transaction) *types.Transaction
+}
+
+type stubSignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+func (s *stubSigner) Version() string {
+	return "6.1.0"
+}
+
+func (s *stubSigner) List() []common.Address {
+	return []common.Address{crypto.PubkeyToAddress(s.key.PublicKey)}
+}
+
+func (s *stubSigner) SignTransaction(args apitypes.SendTxArgs, methodSelector *string) (*stubSignTxResult, error) {
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.tamper != nil {
+		tx = s.tamper(tx)
+	}
+
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID((*big.Int)(args.ChainID)), s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &stubSignTxResult{Raw: raw, Tx: signedTx}, nil
+}
+
+func (s *stubSigner) SignData(contentType string, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	sig, err := crypto.Sign(accounts.TextHash(data), s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+func (s *stubSigner) SignTypedData(addr common.MixedcaseAddress, typedData apitypes.TypedData) (hexutil.Bytes, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(hash, s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+func newStubSignerServer(t *testing.T, stub *stubSigner) *rpc.Server {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("account", stub))
+	t.Cleanup(srv.Stop)
+
+	return srv
+}
+
+func TestExternalSignerHTTP(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	httpSrv := httptest.NewServer(newStubSignerServer(t, &stubSigner{key: key}))
+	defer httpSrv.Close()
+
+	signer, err := NewExternalSigner(httpSrv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{account}, signer.Accounts())
+
+	to := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	txs := []*types.Transaction{
+		types.NewTx(&types.LegacyTx{
+			Nonce:    1,
+			GasPrice: big.NewInt(50),
+			Gas:      21000,
+			To:       &to,
+			Value:    big.NewInt(10),
+		}),
+		types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(1337),
+			Nonce:     2,
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(100),
+			Gas:       100000,
+			Data:      []byte{0x60, 0x80},
+		}),
+	}
+
+	signerFn := signer.SignerFn(1337, account)
+	for _, tx := range txs {
+		signedTx, err := signerFn(account, tx)
+		require.NoError(t, err)
+
+		sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1337)), signedTx)
+		require.NoError(t, err)
+		assert.Equal(t, account, sender)
+		assert.Equal(t, tx.Nonce(), signedTx.Nonce())
+	}
+
+	_, err = signerFn(common.HexToAddress("0x01"), txs[0])
+	assert.Error(t, err)
+
+	msg := []byte("hello")
+	sig, err := signer.PersonalSignFn(account)(account, msg)
+	require.NoError(t, err)
+
+	expectedSig, err := crypto.Sign(accounts.TextHash(msg), key)
+	require.NoError(t, err)
+	assert.Equal(t, expectedSig, sig)
+
+	typedData := testTypedData(account)
+	sig, err = signer.TypedDataSignFn(account)(account, typedData)
+	require.NoError(t, err)
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	expectedSig, err = crypto.Sign(hash, key)
+	require.NoError(t, err)
+	assert.Equal(t, expectedSig, sig)
+}
+
+func testTypedData(account common.Address) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"Mail": {
+				{Name: "from", Type: "address"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: apitypes.TypedDataDomain{
+			Name:    "Ether Mail",
+			ChainId: math.NewHexOrDecimal256(1337),
+		},
+		Message: apitypes.TypedDataMessage{
+			"from":     account.Hex(),
+			"contents": "Hello, Bob!",
+		},
+	}
+}
+
+func TestExternalSignerIPC(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	ipcPath := filepath.Join(t.TempDir(), "clef.ipc")
+	listener, err := net.Listen("unix", ipcPath)
+	require.NoError(t, err)
+
+	go newStubSignerServer(t, &stubSigner{key: key}).ServeListener(listener)
+	defer listener.Close()
+
+	signer, err := NewExternalSigner(ipcPath)
+	require.NoError(t, err)
+
+	to := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(50),
+		Gas:      21000,
+		To:       &to,
+	})
+
+	signedTx, err := signer.SignerFn(1, account)(account, tx)
+	require.NoError(t, err)
+
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), signedTx)
+	require.NoError(t, err)
+	assert.Equal(t, account, sender)
+}
+
+func TestExternalSignerRejectsAlteredTx(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	stub := &stubSigner{
+		key: key,
+		tamper: func(tx *types.Transaction) *types.Transaction {
+			return types.NewTx(&types.LegacyTx{
+				Nonce:    tx.Nonce(),
+				GasPrice: tx.GasPrice(),
+				Gas:      tx.Gas(),
+				To:       tx.To(),
+				Value:    big.NewInt(1e18),
+			})
+		},
+	}
+
+	httpSrv := httptest.NewServer(newStubSignerServer(t, stub))
+	defer httpSrv.Close()
+
+	signer, err := NewExternalSigner(httpSrv.URL)
+	require.NoError(t, err)
+
+	to := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(50),
+		Gas:      21000,
+		To:       &to,
+	})
+
+	_, err = signer.SignerFn(1, account)(account, tx)
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultHDPath is the BIP-44 base path of Ethereum accounts, the account index is appended to it.
+// Hardhat, Anvil and MetaMask derive accounts of a mnemonic the same way.
+const DefaultHDPath = "m/44'/60'/0'/0"
+
+var masterKeyHMACKey = []byte("Bitcoin seed")
+
+// HDWallet derives keys from the seed of a BIP-39 mnemonic, as specified by BIP-32.
+type HDWallet struct {
+	key       []byte
+	chainCode []byte
+}
+
+// NewHDWallet checks the mnemonic checksum and derives the master key from its seed,
+// passphrase is the optional BIP-39 passphrase.
+func NewHDWallet(mnemonic, passphrase string) (*HDWallet, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		err = errors.Wrap(err, "invalid mnemonic")
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, masterKeyHMACKey)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	w := &HDWallet{
+		key:       sum[:32],
+		chainCode: sum[32:],
+	}
+
+	if !validHDKey(w.key) {
+		return nil, errors.New("invalid master key derived from the mnemonic")
+	}
+
+	return w, nil
+}
+
+// ParseHDPath parses the base derivation path and appends the account index, e.g. m/44'/60'/0'/0/1.
+func ParseHDPath(basePath string, index uint32) (accounts.DerivationPath, error) {
+	path, err := accounts.ParseDerivationPath(basePath)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to parse HD path %s", basePath)
+		return nil, err
+	}
+
+	return append(path, index), nil
+}
+
+// Derive returns the private key at the path, hardened components are offset by 0x80000000.
+func (w *HDWallet) Derive(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	key, chainCode := w.key, w.chainCode
+
+	for _, index := range path {
+		var data []byte
+		if index >= 0x80000000 {
+			data = append([]byte{0}, key...)
+		} else {
+			pk, err := crypto.ToECDSA(key)
+			if err != nil {
+				return nil, err
+			}
+
+			data = crypto.CompressPubkey(&pk.PublicKey)
+		}
+
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(crypto.S256().Params().N) >= 0 {
+			return nil, errors.Errorf("invalid key derived at %s", path.String())
+		}
+
+		child := tweak.Add(tweak, new(big.Int).SetBytes(key))
+		child.Mod(child, crypto.S256().Params().N)
+
+		key, chainCode = math.PaddedBigBytes(child, 32), sum[32:]
+		if !validHDKey(key) {
+			return nil, errors.Errorf("invalid key derived at %s", path.String())
+		}
+	}
+
+	return crypto.ToECDSA(key)
+}
+
+func validHDKey(key []byte) bool {
+	k := new(big.Int).SetBytes(key)
+	return k.Sign() > 0 && k.Cmp(crypto.S256().Params().N) < 0
+}
@@ -0,0 +1,63 @@
+package keystore
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMnemonic is the default mnemonic of Hardhat and Anvil development accounts.
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func TestHDWalletDerive(t *testing.T) {
+	w, err := NewHDWallet(testMnemonic, "")
+	require.NoError(t, err)
+
+	expected := []common.Address{
+		common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"),
+		common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"),
+	}
+
+	for index, account := range expected {
+		path, err := ParseHDPath(DefaultHDPath, uint32(index))
+		require.NoError(t, err)
+
+		pk, err := w.Derive(path)
+		require.NoError(t, err)
+		assert.Equal(t, account, crypto.PubkeyToAddress(pk.PublicKey), path.String())
+	}
+
+	// the passphrase changes the seed
+	w, err = NewHDWallet(testMnemonic, "passphrase")
+	require.NoError(t, err)
+
+	path, err := ParseHDPath(DefaultHDPath, 0)
+	require.NoError(t, err)
+
+	pk, err := w.Derive(path)
+	require.NoError(t, err)
+	assert.NotEqual(t, expected[0], crypto.PubkeyToAddress(pk.PublicKey))
+
+	_, err = NewHDWallet("test test test test test test test test test test test test", "")
+	assert.Error(t, err, "invalid checksum")
+}
+
+func TestParseHDPath(t *testing.T) {
+	path, err := ParseHDPath(DefaultHDPath, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "m/44'/60'/0'/0/1", path.String())
+
+	for _, basePath := range []string{
+		"",
+		"m/44'/60'/x'/0",
+		"m/44'/60'/0'/-1",
+		"m/44'/60'/0'/4294967296",
+		"44'/60'/0'/0/m",
+	} {
+		_, err := ParseHDPath(basePath, 0)
+		assert.Error(t, err, basePath)
+	}
+}
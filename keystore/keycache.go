@@ -2,42 +2,119 @@ package keystore
 
 import (
 	"crypto/ecdsa"
-	"crypto/sha1"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"io/ioutil"
 	"math/big"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/pkg/errors"
 )
 
+// ErrLocked is returned by signers of keys evicted from the cache, the key must be unlocked again.
+var ErrLocked = errors.New("key is locked, unlock it with the password again")
+
 type KeyCache interface {
 	SetPath(account common.Address, path string) (existing bool)
 	UnsetPath(account common.Address)
+	Path(account common.Address) (path string, ok bool)
 	PrivateKey(account common.Address, password string) (*ecdsa.PrivateKey, error)
 	SetPrivateKey(account common.Address, pk *ecdsa.PrivateKey)
 	UnsetKey(account common.Address, password string)
+	Lock(account common.Address)
+	LockAll()
 	SignerFn(chainID uint64, account common.Address, password string) (SignerFn, error)
 	PersonalSignFn(account common.Address, password string) (PersonalSignFn, error)
+	TypedDataSignFn(account common.Address, password string) (TypedDataSignFn, error)
+}
+
+type keyCacheOptions struct {
+	TTL        time.Duration
+	MaxEntries int
+}
+
+type KeyCacheOption func(o *keyCacheOptions) error
+
+// KeyCacheTTL sets how long decrypted keys are kept after unlocking, zero keeps them until locked.
+func KeyCacheTTL(ttl time.Duration) KeyCacheOption {
+	return func(o *keyCacheOptions) error {
+		if ttl < 0 {
+			return errors.New("key cache TTL must not be negative")
+		}
+
+		o.TTL = ttl
+		return nil
+	}
+}
+
+// KeyCacheMaxEntries limits the number of decrypted keys kept, least recently used are evicted first.
+// Zero means no limit.
+func KeyCacheMaxEntries(maxEntries int) KeyCacheOption {
+	return func(o *keyCacheOptions) error {
+		if maxEntries < 0 {
+			return errors.New("key cache max entries must not be negative")
+		}
+
+		o.MaxEntries = maxEntries
+		return nil
+	}
 }
 
-func NewKeyCache() KeyCache {
-	return &keyCache{
-		paths: new(sync.Map),
-		keys:  new(sync.Map),
-		guard: new(sync.Map),
+// NewKeyCache returns a cache of keystore paths and decrypted keys. Keys are kept until
+// locked unless TTL or max entries are set, evicted keys are zeroed.
+func NewKeyCache(options ...KeyCacheOption) (KeyCache, error) {
+	k := &keyCache{
+		paths:   new(sync.Map),
+		guard:   new(sync.Map),
+		secret:  make([]byte, 32),
+		keys:    make(map[keyHash]*cachedKey),
+		keysMux: new(sync.Mutex),
+	}
+
+	for _, optFn := range options {
+		if err := optFn(&k.options); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := rand.Read(k.secret); err != nil {
+		err = errors.Wrap(err, "failed to generate key cache secret")
+		return nil, err
 	}
+
+	return k, nil
+}
+
+// keyHash identifies a decrypted key by account and password it was unlocked with.
+type keyHash [sha256.Size]byte
+
+type cachedKey struct {
+	account  common.Address
+	pk       *ecdsa.PrivateKey
+	lastUsed time.Time
+	expiry   *time.Timer
 }
 
 type keyCache struct {
+	options keyCacheOptions
+
 	paths *sync.Map // map[common.Address]string
-	keys  *sync.Map // map[string]*ecdsa.PrivateKey
 	guard *sync.Map
+
+	// secret is the HMAC key of cache entries, so passwords can't be brute-forced from them
+	secret  []byte
+	keys    map[keyHash]*cachedKey
+	keysMux *sync.Mutex
 }
 
 func (k *keyCache) SetPath(account common.Address, path string) (existing bool) {
@@ -54,32 +131,76 @@ func (k *keyCache) UnsetPath(account common.Address) {
 	k.paths.Delete(account)
 }
 
+func (k *keyCache) Path(account common.Address) (path string, ok bool) {
+	v, ok := k.paths.Load(account)
+	if !ok {
+		return "", false
+	}
+
+	return strings.TrimPrefix(v.(string), "keystore://"), true
+}
+
 func (k *keyCache) UnsetKey(account common.Address, password string) {
-	h := hashAccountPass(account, password)
-	k.keys.Delete(string(h))
+	h := k.hashAccountPass(account, password)
+
+	k.keysMux.Lock()
+	k.evictLocked(h)
+	k.keysMux.Unlock()
+}
+
+// Lock zeroes and evicts keys of the account, unlocked with any password.
+func (k *keyCache) Lock(account common.Address) {
+	k.keysMux.Lock()
+	defer k.keysMux.Unlock()
+
+	for h, entry := range k.keys {
+		if entry.account == account {
+			k.evictLocked(h)
+		}
+	}
+}
+
+// LockAll zeroes and evicts all decrypted keys, signers return ErrLocked until keys are unlocked again.
+func (k *keyCache) LockAll() {
+	k.keysMux.Lock()
+	defer k.keysMux.Unlock()
+
+	for h := range k.keys {
+		k.evictLocked(h)
+	}
 }
 
 func (k *keyCache) SetPrivateKey(account common.Address, pk *ecdsa.PrivateKey) {
-	h := hashAccountPass(account, "")
-	k.keys.Store(string(h), pk)
+	h := k.hashAccountPass(account, "")
+	k.storeKey(h, account, copyKey(pk))
 }
 
+// PrivateKey returns a copy of the decrypted key, the copy isn't zeroed on eviction.
 func (k *keyCache) PrivateKey(account common.Address, password string) (*ecdsa.PrivateKey, error) {
-	h := hashAccountPass(account, password)
+	h, err := k.unlock(account, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.copyCachedKey(h)
+}
+
+// unlock decrypts the key file of the account, unless the key is cached already.
+func (k *keyCache) unlock(account common.Address, password string) (keyHash, error) {
+	h := k.hashAccountPass(account, password)
 
 	mux, _ := k.guard.LoadOrStore(account, new(sync.Mutex))
 	mux.(*sync.Mutex).Lock()
 	defer mux.(*sync.Mutex).Unlock()
 
-	v, ok := k.keys.Load(string(h))
-	if ok {
-		return v.(*ecdsa.PrivateKey), nil
+	if k.touchKey(h) {
+		return h, nil
 	}
 
-	v, ok = k.paths.Load(account)
+	v, ok := k.paths.Load(account)
 	if !ok {
 		err := errors.Errorf("no keystore path set for account %s", account.String())
-		return nil, err
+		return h, err
 	}
 
 	path := v.(string)
@@ -90,63 +211,233 @@ func (k *keyCache) PrivateKey(account common.Address, password string) (*ecdsa.P
 	keyJSON, err := ioutil.ReadFile(path)
 	if err != nil {
 		err = errors.Wrap(err, "failed to load a file from keystore")
-		return nil, err
+		return h, err
 	}
 
 	pk, err := keystore.DecryptKey(keyJSON, password)
 	if err != nil {
 		err = errors.Wrap(err, "key decryption failed")
-		return nil, err
+		return h, err
+	}
+
+	k.storeKey(h, account, pk.PrivateKey)
+	return h, nil
+}
+
+// touchKey marks the key as used, returns false if it's not cached.
+func (k *keyCache) touchKey(h keyHash) bool {
+	k.keysMux.Lock()
+	defer k.keysMux.Unlock()
+
+	entry, ok := k.keys[h]
+	if ok {
+		entry.lastUsed = time.Now()
+	}
+
+	return ok
+}
+
+// withKey calls fn with a copy of the cached key, so signing doesn't block other accounts
+// and evictions. The copy is zeroed once fn returns.
+func (k *keyCache) withKey(h keyHash, fn func(pk *ecdsa.PrivateKey) error) error {
+	pk, err := k.copyCachedKey(h)
+	if err != nil {
+		return err
 	}
+	defer zeroKey(pk)
 
-	k.keys.Store(string(h), pk.PrivateKey)
-	return pk.PrivateKey, nil
+	return fn(pk)
 }
 
+// copyCachedKey copies the cached key out under the lock and marks it as used.
+func (k *keyCache) copyCachedKey(h keyHash) (*ecdsa.PrivateKey, error) {
+	k.keysMux.Lock()
+	defer k.keysMux.Unlock()
+
+	entry, ok := k.keys[h]
+	if !ok {
+		return nil, ErrLocked
+	}
+
+	entry.lastUsed = time.Now()
+	return copyKey(entry.pk), nil
+}
+
+func (k *keyCache) storeKey(h keyHash, account common.Address, pk *ecdsa.PrivateKey) {
+	k.keysMux.Lock()
+	defer k.keysMux.Unlock()
+
+	k.evictLocked(h)
+
+	entry := &cachedKey{
+		account:  account,
+		pk:       pk,
+		lastUsed: time.Now(),
+	}
+
+	if k.options.TTL > 0 {
+		entry.expiry = time.AfterFunc(k.options.TTL, func() {
+			k.keysMux.Lock()
+			defer k.keysMux.Unlock()
+
+			// the key might have been evicted and unlocked again since
+			if k.keys[h] == entry {
+				k.evictLocked(h)
+			}
+		})
+	}
+
+	k.keys[h] = entry
+
+	for k.options.MaxEntries > 0 && len(k.keys) > k.options.MaxEntries {
+		var oldest keyHash
+		var oldestUsed time.Time
+		for hash, e := range k.keys {
+			if hash != h && (oldestUsed.IsZero() || e.lastUsed.Before(oldestUsed)) {
+				oldest, oldestUsed = hash, e.lastUsed
+			}
+		}
+
+		k.evictLocked(oldest)
+	}
+}
+
+func (k *keyCache) evictLocked(h keyHash) {
+	entry, ok := k.keys[h]
+	if !ok {
+		return
+	}
+
+	if entry.expiry != nil {
+		entry.expiry.Stop()
+	}
+
+	zeroKey(entry.pk)
+	delete(k.keys, h)
+}
+
+// SignerFn returns a SignerFn using the cached key, it returns ErrLocked once the key is evicted.
 func (k *keyCache) SignerFn(chainID uint64, account common.Address, password string) (SignerFn, error) {
-	key, err := k.PrivateKey(account, password)
+	h, err := k.unlock(account, password)
 	if err != nil {
 		return nil, err
 	}
 
-	txOpts, err := bind.NewKeyedTransactorWithChainID(key, new(big.Int).SetUint64(chainID))
-	if err != nil {
-		err = errors.Wrap(err, "failed to init NewKeyedTransactorWithChainID")
+	if err := k.checkKeyAddress(h, account); err != nil {
 		return nil, err
 	}
 
-	return txOpts.Signer, nil
+	txSigner := types.LatestSignerForChainID(new(big.Int).SetUint64(chainID))
+
+	signerFn := func(from common.Address, tx *types.Transaction) (signedTx *types.Transaction, err error) {
+		if from != account {
+			return nil, bind.ErrNotAuthorized
+		}
+
+		err = k.withKey(h, func(pk *ecdsa.PrivateKey) error {
+			signedTx, err = types.SignTx(tx, txSigner, pk)
+			return err
+		})
+
+		return signedTx, err
+	}
+
+	return signerFn, nil
 }
 
 func (k *keyCache) PersonalSignFn(account common.Address, password string) (PersonalSignFn, error) {
-	key, err := k.PrivateKey(account, password)
+	h, err := k.unlock(account, password)
 	if err != nil {
 		return nil, err
 	}
 
-	keyAddress := crypto.PubkeyToAddress(key.PublicKey)
-	if keyAddress != account {
-		return nil, errors.New("account key address mismatch")
+	if err := k.checkKeyAddress(h, account); err != nil {
+		return nil, err
 	}
 
 	signFn := func(from common.Address, data []byte) (sig []byte, err error) {
-		if from != keyAddress {
+		if from != account {
 			return nil, errors.New("from address mismatch")
 		}
 
-		protectedHash := accounts.TextHash(data)
-		return crypto.Sign(protectedHash, key)
+		return k.signHash(h, accounts.TextHash(data))
 	}
 
 	return signFn, nil
 }
 
+func (k *keyCache) TypedDataSignFn(account common.Address, password string) (TypedDataSignFn, error) {
+	h, err := k.unlock(account, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := k.checkKeyAddress(h, account); err != nil {
+		return nil, err
+	}
+
+	signFn := func(from common.Address, typedData apitypes.TypedData) (sig []byte, err error) {
+		if from != account {
+			return nil, errors.New("from address mismatch")
+		}
+
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			return nil, err
+		}
+
+		return k.signHash(h, hash)
+	}
+
+	return signFn, nil
+}
+
+func (k *keyCache) signHash(h keyHash, hash []byte) (sig []byte, err error) {
+	err = k.withKey(h, func(pk *ecdsa.PrivateKey) error {
+		sig, err = crypto.Sign(hash, pk)
+		return err
+	})
+
+	return sig, err
+}
+
+func (k *keyCache) checkKeyAddress(h keyHash, account common.Address) error {
+	return k.withKey(h, func(pk *ecdsa.PrivateKey) error {
+		if crypto.PubkeyToAddress(pk.PublicKey) != account {
+			return errors.New("account key address mismatch")
+		}
+
+		return nil
+	})
+}
+
 var hashSep = []byte("-")
 
-func hashAccountPass(account common.Address, password string) []byte {
-	h := sha1.New()
-	h.Write(account[:])
-	h.Write(hashSep)
-	h.Write([]byte(password))
-	return h.Sum(nil)
+func (k *keyCache) hashAccountPass(account common.Address, password string) keyHash {
+	var h keyHash
+
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(account[:])
+	mac.Write(hashSep)
+	mac.Write([]byte(password))
+	copy(h[:], mac.Sum(nil))
+
+	return h
+}
+
+func copyKey(pk *ecdsa.PrivateKey) *ecdsa.PrivateKey {
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: pk.Curve,
+			X:     new(big.Int).Set(pk.X),
+			Y:     new(big.Int).Set(pk.Y),
+		},
+		D: new(big.Int).Set(pk.D),
+	}
+}
+
+// zeroKey overwrites the private scalar in place, like Geth does for locked keys.
+func zeroKey(pk *ecdsa.PrivateKey) {
+	clear(pk.D.Bits())
+	pk.D.SetInt64(0)
 }
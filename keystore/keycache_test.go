@@ -0,0 +1,147 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyStore(t *testing.T, options ...KeyCacheOption) (EthKeyStore, *keyCache, []common.Address) {
+	cache, err := NewKeyCache(options...)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	ks, err := NewWithOptions([]string{dir}, OptionKeyCache(cache))
+	require.NoError(t, err)
+
+	var accounts []common.Address
+	for i := 0; i < 2; i++ {
+		account, err := ks.NewKey(dir, "pass", LightScryptParams)
+		require.NoError(t, err)
+		accounts = append(accounts, account)
+	}
+
+	return ks, cache.(*keyCache), accounts
+}
+
+func testSignTx(signerFn SignerFn, account common.Address) error {
+	to := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	_, err := signerFn(account, types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(50),
+		Gas:      21000,
+		To:       &to,
+	}))
+
+	return err
+}
+
+func TestKeyCacheTTL(t *testing.T) {
+	ks, cache, accounts := newTestKeyStore(t, KeyCacheTTL(50*time.Millisecond))
+
+	signerFn, err := ks.SignerFn(1, accounts[0], "pass")
+	require.NoError(t, err)
+	require.NoError(t, testSignTx(signerFn, accounts[0]))
+
+	cache.keysMux.Lock()
+	entry := cache.keys[cache.hashAccountPass(accounts[0], "pass")]
+	cache.keysMux.Unlock()
+	require.NotNil(t, entry)
+
+	assert.Eventually(t, func() bool {
+		return testSignTx(signerFn, accounts[0]) == ErrLocked
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Zero(t, entry.pk.D.Sign(), "evicted key must be zeroed")
+
+	// unlocking again restores signing
+	signerFn, err = ks.SignerFn(1, accounts[0], "pass")
+	require.NoError(t, err)
+	assert.NoError(t, testSignTx(signerFn, accounts[0]))
+}
+
+func TestKeyCacheMaxEntries(t *testing.T) {
+	ks, _, accounts := newTestKeyStore(t, KeyCacheMaxEntries(1))
+
+	signerFn0, err := ks.SignerFn(1, accounts[0], "pass")
+	require.NoError(t, err)
+
+	signerFn1, err := ks.SignerFn(1, accounts[1], "pass")
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrLocked, testSignTx(signerFn0, accounts[0]))
+	assert.NoError(t, testSignTx(signerFn1, accounts[1]))
+}
+
+func TestKeyCacheLockAll(t *testing.T) {
+	ks, _, accounts := newTestKeyStore(t)
+
+	pk, err := ks.PrivateKey(accounts[0], "pass")
+	require.NoError(t, err)
+
+	personalSignFn, err := ks.PersonalSignFn(accounts[0], "pass")
+	require.NoError(t, err)
+
+	signerFn, err := ks.SignerFn(1, accounts[1], "pass")
+	require.NoError(t, err)
+
+	_, err = personalSignFn(accounts[0], []byte("hello"))
+	require.NoError(t, err)
+
+	ks.LockAll()
+
+	_, err = personalSignFn(accounts[0], []byte("hello"))
+	assert.Equal(t, ErrLocked, err)
+	assert.Equal(t, ErrLocked, testSignTx(signerFn, accounts[1]))
+
+	// returned keys are copies owned by the caller
+	assert.Equal(t, accounts[0], crypto.PubkeyToAddress(pk.PublicKey))
+	assert.NotZero(t, pk.D.Sign())
+
+	_, err = ks.PrivateKey(accounts[0], "wrong")
+	assert.Error(t, err)
+}
+
+func TestKeyCacheWithKey(t *testing.T) {
+	_, cache, accounts := newTestKeyStore(t)
+
+	h, err := cache.unlock(accounts[0], "pass")
+	require.NoError(t, err)
+
+	var signingKey *ecdsa.PrivateKey
+	err = cache.withKey(h, func(pk *ecdsa.PrivateKey) error {
+		signingKey = pk
+
+		// the cache isn't locked while signing, the key can be evicted meanwhile
+		cache.LockAll()
+		assert.NotZero(t, pk.D.Sign())
+		assert.Equal(t, accounts[0], crypto.PubkeyToAddress(pk.PublicKey))
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Zero(t, signingKey.D.Sign(), "key copy must be zeroed after signing")
+	assert.Equal(t, ErrLocked, cache.withKey(h, func(*ecdsa.PrivateKey) error { return nil }))
+}
+
+func TestKeyCacheKeyedHash(t *testing.T) {
+	cache1, err := NewKeyCache()
+	require.NoError(t, err)
+	cache2, err := NewKeyCache()
+	require.NoError(t, err)
+
+	account := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	h1 := cache1.(*keyCache).hashAccountPass(account, "pass")
+
+	assert.Equal(t, h1, cache1.(*keyCache).hashAccountPass(account, "pass"))
+	assert.NotEqual(t, h1, cache1.(*keyCache).hashAccountPass(account, "pass2"))
+	assert.NotEqual(t, h1, cache2.(*keyCache).hashAccountPass(account, "pass"))
+}
@@ -0,0 +1,161 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ScryptParams are KDF parameters of key files being written, see StandardScryptParams.
+type ScryptParams struct {
+	N int
+	P int
+}
+
+var (
+	// StandardScryptParams match Geth defaults, decryption takes about a second and 256MB of memory.
+	StandardScryptParams = ScryptParams{
+		N: keystore.StandardScryptN,
+		P: keystore.StandardScryptP,
+	}
+
+	// LightScryptParams use 4MB of memory, for test keys only.
+	LightScryptParams = ScryptParams{
+		N: keystore.LightScryptN,
+		P: keystore.LightScryptP,
+	}
+)
+
+var ErrAccountExists = errors.New("account already exists in keystore")
+
+func (ks *keyStore) NewKey(keystorePath, password string, params ScryptParams) (common.Address, error) {
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		err = errors.Wrap(err, "failed to generate key")
+		return common.Address{}, err
+	}
+
+	return ks.ImportKey(keystorePath, pk, password, params)
+}
+
+func (ks *keyStore) ImportKey(
+	keystorePath string,
+	pk *ecdsa.PrivateKey,
+	password string,
+	params ScryptParams,
+) (common.Address, error) {
+	account := crypto.PubkeyToAddress(pk.PublicKey)
+	if _, ok := ks.cache.Path(account); ok {
+		return account, ErrAccountExists
+	}
+
+	path := filepath.Join(keystorePath, keyFileName(account))
+	if err := writeKeyFile(path, pk, password, params); err != nil {
+		return account, err
+	}
+
+	ks.pathsMux.Lock()
+	ks.paths[keystorePath] = struct{}{}
+	ks.pathsMux.Unlock()
+
+	ks.walletsMux.Lock()
+	ks.wallets[account] = path
+	ks.walletsMux.Unlock()
+
+	ks.cache.SetPath(account, path)
+
+	return account, nil
+}
+
+// ChangePassword rewrites the key file of the account, encrypting it with the new password.
+func (ks *keyStore) ChangePassword(account common.Address, password, newPassword string, params ScryptParams) error {
+	path, ok := ks.cache.Path(account)
+	if !ok {
+		err := errors.Errorf("no keystore path set for account %s", account.String())
+		return err
+	}
+
+	pk, err := ks.cache.PrivateKey(account, password)
+	if err != nil {
+		return err
+	}
+	defer zeroKey(pk)
+
+	if err := writeKeyFile(path, pk, newPassword, params); err != nil {
+		return err
+	}
+
+	ks.cache.UnsetKey(account, password)
+
+	return nil
+}
+
+// writeKeyFile encrypts the key into a V3 key file, the file is replaced atomically.
+func writeKeyFile(path string, pk *ecdsa.PrivateKey, password string, params ScryptParams) error {
+	key := &keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(pk.PublicKey),
+		PrivateKey: pk,
+	}
+
+	keyJSON, err := keystore.EncryptKey(key, password, params.N, params.P)
+	if err != nil {
+		err = errors.Wrap(err, "failed to encrypt key")
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		err = errors.Wrap(err, "failed to create keystore dir")
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		err = errors.Wrap(err, "failed to create key file")
+		return err
+	}
+
+	if _, err := f.Write(keyJSON); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		err = errors.Wrap(err, "failed to write key file")
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		err = errors.Wrap(err, "failed to write key file")
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// keyFileName follows the Geth naming convention: UTC--<created at>--<address hex>.
+func keyFileName(account common.Address) string {
+	ts := time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z")
+	return fmt.Sprintf("UTC--%s--%s", ts, hex.EncodeToString(account[:]))
+}
+
+// ParsePrivateKey reads the hex private key, with or without 0x prefix.
+func ParsePrivateKey(pkHex string) (*ecdsa.PrivateKey, error) {
+	pk, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(pkHex), "0x"))
+	if err != nil {
+		err = errors.Wrap(err, "failed to hex-decode Ethereum ECDSA Private Key")
+		return nil, err
+	}
+
+	return pk, nil
+}
@@ -0,0 +1,77 @@
+package keystore
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrivKeyHex = "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+var testPrivKeyAccount = common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+
+func TestImportKey(t *testing.T) {
+	pk, err := ParsePrivateKey(testPrivKeyHex)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	ks, err := New(dir)
+	require.NoError(t, err)
+
+	account, err := ks.ImportKey(dir, pk, "pass", LightScryptParams)
+	require.NoError(t, err)
+	assert.Equal(t, testPrivKeyAccount, account)
+
+	// the key file is read back by a new keystore
+	ks, err = New(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{account}, ks.Accounts())
+
+	decrypted, err := ks.PrivateKey(account, "pass")
+	require.NoError(t, err)
+	assert.Equal(t, crypto.FromECDSA(pk), crypto.FromECDSA(decrypted))
+
+	_, err = ks.PrivateKey(account, "wrong")
+	assert.Error(t, err)
+
+	_, err = ks.ImportKey(dir, pk, "other", LightScryptParams)
+	assert.Equal(t, ErrAccountExists, err)
+}
+
+func TestChangePassword(t *testing.T) {
+	pk, err := ParsePrivateKey(testPrivKeyHex)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	ks, err := New(dir)
+	require.NoError(t, err)
+
+	account, err := ks.ImportKey(dir, pk, "old", LightScryptParams)
+	require.NoError(t, err)
+
+	// unlocked with the old password before the change
+	_, err = ks.PrivateKey(account, "old")
+	require.NoError(t, err)
+
+	require.NoError(t, ks.ChangePassword(account, "old", "new", LightScryptParams))
+	assert.Error(t, ks.ChangePassword(account, "old", "other", LightScryptParams))
+
+	for _, ks := range []EthKeyStore{ks, mustNewKeyStore(t, dir)} {
+		_, err = ks.PrivateKey(account, "old")
+		assert.Error(t, err)
+
+		decrypted, err := ks.PrivateKey(account, "new")
+		require.NoError(t, err)
+		assert.Equal(t, crypto.FromECDSA(pk), crypto.FromECDSA(decrypted))
+	}
+}
+
+func mustNewKeyStore(t *testing.T, dir string) EthKeyStore {
+	ks, err := New(dir)
+	require.NoError(t, err)
+
+	return ks
+}
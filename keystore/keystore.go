@@ -1,43 +1,101 @@
 package keystore
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	log "github.com/xlab/suplog"
 )
 
 type PersonalSignFn func(account common.Address, data []byte) (sig []byte, err error)
 
+// TypedDataSignFn signs EIP-712 typed data, V of signatures is 0 or 1.
+type TypedDataSignFn func(account common.Address, typedData apitypes.TypedData) (sig []byte, err error)
+
 type SignerFn = bind.SignerFn
 
 type EthKeyStore interface {
 	PrivateKey(account common.Address, password string) (*ecdsa.PrivateKey, error)
 	SignerFn(chainID uint64, account common.Address, password string) (SignerFn, error)
 	PersonalSignFn(account common.Address, password string) (PersonalSignFn, error)
+	TypedDataSignFn(account common.Address, password string) (TypedDataSignFn, error)
 	UnsetKey(account common.Address, password string)
+	Lock(account common.Address)
+	LockAll()
+	NewKey(keystorePath, password string, params ScryptParams) (common.Address, error)
+	ImportKey(keystorePath string, pk *ecdsa.PrivateKey, password string, params ScryptParams) (common.Address, error)
+	ChangePassword(account common.Address, password, newPassword string, params ScryptParams) error
 	Accounts() []common.Address
+	Wallets() []*WalletSpec
 	AddPath(keystorePath string) error
 	RemovePath(keystorePath string)
 	Paths() []string
+	Watch(ctx context.Context)
 }
 
 func New(paths ...string) (EthKeyStore, error) {
+	return NewWithOptions(paths)
+}
+
+type Option func(ks *keyStore) error
+
+// OptionKeyCache sets the cache of decrypted keys, see NewKeyCache for expiry options.
+func OptionKeyCache(cache KeyCache) Option {
+	return func(ks *keyStore) error {
+		if cache == nil {
+			return errors.New("no key cache provided")
+		}
+
+		ks.cache = cache
+		return nil
+	}
+}
+
+// OptionRecursive enables scanning of subdirectories in keystore paths, hidden ones are skipped.
+func OptionRecursive(recursive bool) Option {
+	return func(ks *keyStore) error {
+		ks.recursive = recursive
+		return nil
+	}
+}
+
+func NewWithOptions(paths []string, options ...Option) (EthKeyStore, error) {
 	ks := &keyStore{
-		cache:    NewKeyCache(),
-		paths:    make(map[string]struct{}),
-		pathsMux: new(sync.RWMutex),
+		paths:      make(map[string]struct{}),
+		pathsMux:   new(sync.RWMutex),
+		wallets:    make(map[common.Address]string),
+		walletsMux: new(sync.Mutex),
+	}
+
+	for _, optFn := range options {
+		if err := optFn(ks); err != nil {
+			return nil, err
+		}
+	}
+
+	if ks.cache == nil {
+		cache, err := NewKeyCache()
+		if err != nil {
+			return nil, err
+		}
+
+		ks.cache = cache
 	}
 
 	for _, path := range paths {
@@ -50,9 +108,18 @@ func New(paths ...string) (EthKeyStore, error) {
 }
 
 type keyStore struct {
-	cache    KeyCache
+	cache     KeyCache
+	recursive bool
+
 	paths    map[string]struct{}
 	pathsMux *sync.RWMutex
+
+	// wallets are key file paths of accounts found by the last scan
+	wallets    map[common.Address]string
+	walletsMux *sync.Mutex
+
+	// skipped are modification times of invalid files, by path
+	skipped sync.Map
 }
 
 func (ks *keyStore) PrivateKey(account common.Address, password string) (*ecdsa.PrivateKey, error) {
@@ -67,10 +134,24 @@ func (ks *keyStore) PersonalSignFn(account common.Address, password string) (Per
 	return ks.cache.PersonalSignFn(account, password)
 }
 
+func (ks *keyStore) TypedDataSignFn(account common.Address, password string) (TypedDataSignFn, error) {
+	return ks.cache.TypedDataSignFn(account, password)
+}
+
 func (ks *keyStore) UnsetKey(account common.Address, password string) {
 	ks.cache.UnsetKey(account, password)
 }
 
+// Lock zeroes decrypted keys of the account, signers return ErrLocked until it's unlocked again.
+func (ks *keyStore) Lock(account common.Address) {
+	ks.cache.Lock(account)
+}
+
+// LockAll zeroes all decrypted keys.
+func (ks *keyStore) LockAll() {
+	ks.cache.LockAll()
+}
+
 func (ks *keyStore) Accounts() []common.Address {
 	paths := ks.Paths()
 
@@ -87,33 +168,94 @@ func (ks *keyStore) Accounts() []common.Address {
 	return accounts
 }
 
+// Wallets lists key files of all keystore paths.
+func (ks *keyStore) Wallets() []*WalletSpec {
+	paths := ks.Paths()
+
+	var wallets []*WalletSpec
+	for _, keystorePath := range paths {
+		if err := ks.forEachWallet(keystorePath, func(spec *WalletSpec) error {
+			wallets = append(wallets, spec)
+			return nil
+		}); err != nil {
+			log.WithField("keystore", keystorePath).WithError(err).Warningln("failed to read keystore files")
+		}
+	}
+
+	return wallets
+}
+
 var errRangeStop = errors.New("stop")
 
+// forEachWallet calls fn for key files in the keystore path. Files that can't be parsed are skipped
+// with a warning, as well as hidden and backup files. Subdirectories are scanned if the keystore is recursive.
 func (ks *keyStore) forEachWallet(keystorePath string, fn func(spec *WalletSpec) error) error {
-	return filepath.Walk(keystorePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
+	return filepath.WalkDir(keystorePath, func(path string, d fs.DirEntry, err error) error {
+		if path == keystorePath {
 			return err
-		} else if path == keystorePath {
+		} else if err != nil {
+			log.WithField("path", path).WithError(err).Warningln("failed to read keystore path")
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+
 			return nil
-		} else if info.IsDir() {
-			return filepath.SkipDir
 		}
-		var spec *WalletSpec
-		if data, err := ioutil.ReadFile(path); err != nil {
-			return err
-		} else if err = json.Unmarshal(data, &spec); err != nil {
-			return err
+
+		if d.IsDir() {
+			if !ks.recursive || nonKeyFile(d.Name()) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		} else if nonKeyFile(d.Name()) {
+			return nil
 		}
-		if len(spec.Address) == 0 {
-			return fmt.Errorf("failed to load address from %s", path)
-		} else if !common.IsHexAddress(spec.Address) {
-			return fmt.Errorf("wrong (not hex) address from %s", path)
+
+		// key files might be symlinks
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
 		}
-		spec.Path = path
+
+		spec, err := readWalletSpec(path)
+		if err != nil {
+			// rescans warn only about new or changed files
+			if modTime, ok := ks.skipped.Load(path); !ok || !info.ModTime().Equal(modTime.(time.Time)) {
+				log.WithField("path", path).WithError(err).Warningln("skipping invalid key file")
+				ks.skipped.Store(path, info.ModTime())
+			}
+
+			return nil
+		}
+
 		return fn(spec)
 	})
 }
 
+func readWalletSpec(path string) (*WalletSpec, error) {
+	var spec *WalletSpec
+	if data, err := ioutil.ReadFile(path); err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	if spec == nil || len(spec.Address) == 0 {
+		return nil, fmt.Errorf("failed to load address from %s", path)
+	} else if !common.IsHexAddress(spec.Address) {
+		return nil, fmt.Errorf("wrong (not hex) address from %s", path)
+	}
+
+	spec.Path = path
+	return spec, nil
+}
+
+// nonKeyFile reports whether the file is hidden, a backup or a README, like Geth keystore does.
+func nonKeyFile(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || name == "README"
+}
+
 func (ks *keyStore) AddPath(keystorePath string) error {
 	f, err := os.Stat(keystorePath)
 	if err != nil {
@@ -131,23 +273,48 @@ func (ks *keyStore) AddPath(keystorePath string) error {
 	return nil
 }
 
+// reloadPathsCache rescans keystore paths, accounts of key files removed since the last scan are unset.
 func (ks *keyStore) reloadPathsCache() {
+	wallets := make(map[common.Address]string)
+
 	paths := ks.Paths()
 	for _, keystorePath := range paths {
 		err := ks.forEachWallet(keystorePath, func(spec *WalletSpec) error {
-			_ = ks.cache.SetPath(spec.AddressFromHex(), spec.Path)
+			wallets[spec.AddressFromHex()] = spec.Path
 			return nil
 		})
 		if err != nil {
 			log.WithField("keystore", keystorePath).WithError(err).Warningln("failed to read keystore files")
 		}
 	}
+
+	ks.walletsMux.Lock()
+	defer ks.walletsMux.Unlock()
+
+	for account, path := range ks.wallets {
+		if _, ok := wallets[account]; !ok {
+			log.WithField("path", path).Debugln("key file removed from keystore")
+			ks.cache.UnsetPath(account)
+		}
+	}
+
+	for account, path := range wallets {
+		if prevPath, ok := ks.wallets[account]; !ok || prevPath != path {
+			log.WithField("path", path).Debugln("key file added to keystore")
+		}
+
+		_ = ks.cache.SetPath(account, path)
+	}
+
+	ks.wallets = wallets
 }
 
 func (ks *keyStore) RemovePath(keystorePath string) {
 	ks.pathsMux.Lock()
 	delete(ks.paths, keystorePath)
 	ks.pathsMux.Unlock()
+
+	ks.reloadPathsCache()
 }
 
 func (ks *keyStore) Paths() []string {
@@ -186,3 +353,42 @@ func PrivateKeyPersonalSignFn(privKey *ecdsa.PrivateKey) (PersonalSignFn, error)
 
 	return signFn, nil
 }
+
+func PrivateKeyTypedDataSignFn(privKey *ecdsa.PrivateKey) (TypedDataSignFn, error) {
+	keyAddress := crypto.PubkeyToAddress(privKey.PublicKey)
+
+	signFn := func(from common.Address, typedData apitypes.TypedData) (sig []byte, err error) {
+		if from != keyAddress {
+			return nil, errors.New("from address mismatch")
+		}
+
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			return nil, err
+		}
+
+		return crypto.Sign(hash, privKey)
+	}
+
+	return signFn, nil
+}
+
+// NormalizeSignatureV converts V of the signature from 27/28 to 0/1, as returned by crypto.Sign.
+// The signature is changed in place.
+func NormalizeSignatureV(sig []byte) []byte {
+	if len(sig) == crypto.SignatureLength && sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	return sig
+}
+
+// LegacySignatureV converts V of the signature to 27/28, as returned by wallets for personal_sign and EIP-712.
+// It's the inverse of NormalizeSignatureV, the signature is changed in place.
+func LegacySignatureV(sig []byte) []byte {
+	if len(sig) == crypto.SignatureLength && sig[crypto.RecoveryIDOffset] < 27 {
+		sig[crypto.RecoveryIDOffset] += 27
+	}
+
+	return sig
+}
@@ -0,0 +1,341 @@
+package keystore
+
+import (
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+// LedgerLivePath is the path layout of Ledger Live, where the account index is hardened in the third
+// component, i.e. m/44'/60'/N'/0/0. Other base paths get the index appended, like m/44'/60'/0'/0/N.
+const LedgerLivePath = "live"
+
+// DefaultLedgerAccounts is the number of accounts derived on the device to find the from account.
+const DefaultLedgerAccounts = 10
+
+// LedgerPathFn returns the derivation path of the account at the index.
+type LedgerPathFn func(index uint32) (accounts.DerivationPath, error)
+
+// LedgerPath returns derivation paths of Ledger Live layout or of the base path with the index appended.
+func LedgerPath(basePath string) (LedgerPathFn, error) {
+	if strings.EqualFold(basePath, LedgerLivePath) {
+		return func(index uint32) (accounts.DerivationPath, error) {
+			return accounts.DerivationPath{
+				0x80000000 + 44,
+				0x80000000 + 60,
+				0x80000000 + index,
+				0,
+				0,
+			}, nil
+		}, nil
+	}
+
+	if _, err := ParseHDPath(basePath, 0); err != nil {
+		return nil, err
+	}
+
+	return func(index uint32) (accounts.DerivationPath, error) {
+		return ParseHDPath(basePath, index)
+	}, nil
+}
+
+// OpenLedgerWallet opens the first wallet that can be opened, the handle is kept open until closed
+// so the device is not reconnected for each signature.
+func OpenLedgerWallet(wallets []accounts.Wallet) (accounts.Wallet, error) {
+	if len(wallets) == 0 {
+		return nil, errors.New("no Ledger device found")
+	}
+
+	var openErr error
+	for _, w := range wallets {
+		if err := w.Open(""); err != nil {
+			openErr = err
+			continue
+		}
+
+		return w, nil
+	}
+
+	err := errors.Wrap(openErr, "failed to connect to wallet on Ledger device")
+	return nil, err
+}
+
+// DiscoverLedgerAccounts derives count accounts starting at the first index, without pinning them.
+func DiscoverLedgerAccounts(
+	w accounts.Wallet,
+	pathFn LedgerPathFn,
+	first uint32,
+	count int,
+) ([]accounts.Account, error) {
+	found := make([]accounts.Account, 0, count)
+
+	for i := 0; i < count; i++ {
+		path, err := pathFn(first + uint32(i))
+		if err != nil {
+			return nil, err
+		}
+
+		acc, err := w.Derive(path, false)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to derive account at %s", path.String())
+			return nil, err
+		}
+
+		found = append(found, acc)
+	}
+
+	return found, nil
+}
+
+// LedgerSigner signs with an account of the open Ledger wallet.
+type LedgerSigner struct {
+	wallet  accounts.Wallet
+	account accounts.Account
+	path    accounts.DerivationPath
+
+	// transportFn opens the device for requests the wallet driver doesn't support
+	transportFn LedgerTransportFn
+
+	// mux serializes access to the device, the wallet is reconnected for transport requests
+	mux *sync.Mutex
+}
+
+type LedgerSignerOption func(s *LedgerSigner)
+
+// LedgerSignerTransport enables personal_sign via APDU requests sent over the transport,
+// since the Ledger driver of go-ethereum supports only transactions and EIP-712 typed data.
+func LedgerSignerTransport(transportFn LedgerTransportFn) LedgerSignerOption {
+	return func(s *LedgerSigner) {
+		s.transportFn = transportFn
+	}
+}
+
+// NewLedgerSigner looks up the account among derived ones, or derives count accounts
+// of path layout on the device to find it. The found account is pinned in the wallet.
+func NewLedgerSigner(
+	w accounts.Wallet,
+	account common.Address,
+	pathFn LedgerPathFn,
+	count int,
+	options ...LedgerSignerOption,
+) (*LedgerSigner, error) {
+	acc := accounts.Account{
+		Address: account,
+	}
+
+	newSigner := func(acc accounts.Account, path accounts.DerivationPath) *LedgerSigner {
+		signer := &LedgerSigner{
+			wallet:  w,
+			account: acc,
+			path:    path,
+			mux:     new(sync.Mutex),
+		}
+
+		for _, option := range options {
+			option(signer)
+		}
+
+		return signer
+	}
+
+	if w.Contains(acc) {
+		// URLs of pinned accounts end with their derivation path
+		for _, pinned := range w.Accounts() {
+			if pinned.Address == account {
+				acc = pinned
+			}
+		}
+
+		return newSigner(acc, accountDerivationPath(acc)), nil
+	}
+
+	for i := 0; i < count; i++ {
+		path, err := pathFn(uint32(i))
+		if err != nil {
+			return nil, err
+		}
+
+		derived, err := w.Derive(path, false)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to derive account at %s", path.String())
+			return nil, err
+		} else if derived.Address != account {
+			continue
+		}
+
+		if acc, err = w.Derive(path, true); err != nil {
+			err = errors.Wrapf(err, "failed to derive account at %s", path.String())
+			return nil, err
+		}
+
+		return newSigner(acc, path), nil
+	}
+
+	return nil, errors.Errorf("account %s not found in %d accounts on Ledger", account.Hex(), count)
+}
+
+// accountDerivationPath parses the path from the account URL of usbwallet, i.e. <device>/m/44'/60'/0'/0/0.
+func accountDerivationPath(acc accounts.Account) accounts.DerivationPath {
+	idx := strings.LastIndex(acc.URL.Path, "/m/")
+	if idx < 0 {
+		return nil
+	}
+
+	path, err := accounts.ParseDerivationPath(acc.URL.Path[idx+1:])
+	if err != nil {
+		return nil
+	}
+
+	return path
+}
+
+func (s *LedgerSigner) Account() accounts.Account {
+	return s.account
+}
+
+// Close disconnects the wallet, signers of the wallet can't be used after.
+func (s *LedgerSigner) Close() error {
+	return s.wallet.Close()
+}
+
+func (s *LedgerSigner) SignerFn(chainID uint64) SignerFn {
+	return func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if from != s.account.Address {
+			return nil, errors.Errorf("not authorized to sign with %s", from.Hex())
+		}
+
+		s.mux.Lock()
+		signedTx, err := s.wallet.SignTx(s.account, tx, new(big.Int).SetUint64(chainID))
+		s.mux.Unlock()
+		if err != nil {
+			err = errors.Wrap(err, "failed to sign transaction on Ledger")
+			return nil, err
+		}
+
+		sender, err := types.Sender(types.LatestSignerForChainID(new(big.Int).SetUint64(chainID)), signedTx)
+		if err != nil {
+			err = errors.Wrap(err, "failed to recover sender of signed transaction")
+			return nil, err
+		} else if sender != from {
+			return nil, errors.Errorf("transaction signed by %s, not %s", sender.Hex(), from.Hex())
+		}
+
+		return signedTx, nil
+	}
+}
+
+// PersonalSignFn signs with EIP-191 prefix via SignText of the wallet. The Ledger driver of go-ethereum
+// doesn't support it, so the signPersonalMessage request is sent over the transport, if set.
+func (s *LedgerSigner) PersonalSignFn() PersonalSignFn {
+	return func(from common.Address, data []byte) ([]byte, error) {
+		if from != s.account.Address {
+			return nil, errors.Errorf("not authorized to sign with %s", from.Hex())
+		}
+
+		s.mux.Lock()
+		sig, err := s.wallet.SignText(s.account, data)
+		if err == accounts.ErrNotSupported && s.transportFn != nil {
+			sig, err = s.signPersonalMessage(data)
+		}
+		s.mux.Unlock()
+
+		if err == accounts.ErrNotSupported {
+			err = errors.New("personal_sign is not supported by the Ledger driver, only transactions and EIP-712 typed data")
+			return nil, err
+		} else if err != nil {
+			err = errors.Wrap(err, "failed to sign message on Ledger")
+			return nil, err
+		} else if len(sig) != crypto.SignatureLength {
+			return nil, errors.Errorf("invalid signature length: %d", len(sig))
+		}
+
+		sig = NormalizeSignatureV(sig)
+		if err := verifySignature(accounts.TextHash(data), sig, from); err != nil {
+			return nil, err
+		}
+
+		return sig, nil
+	}
+}
+
+// signPersonalMessage sends the message to the Ethereum app over the transport. The wallet holds
+// the device, so it's disconnected for the request and connected again after, with the account pinned.
+// Expects the lock to be held.
+func (s *LedgerSigner) signPersonalMessage(data []byte) (sig []byte, err error) {
+	if len(s.path) == 0 {
+		return nil, errors.Errorf("derivation path of %s is unknown", s.account.Address.Hex())
+	}
+
+	if err := s.wallet.Close(); err != nil {
+		err = errors.Wrap(err, "failed to disconnect Ledger wallet")
+		return nil, err
+	}
+
+	defer func() {
+		if openErr := s.reopen(); openErr != nil && err == nil {
+			err = openErr
+		}
+	}()
+
+	transport, err := s.transportFn()
+	if err != nil {
+		err = errors.Wrap(err, "failed to connect to Ledger device")
+		return nil, err
+	}
+	defer transport.Close()
+
+	return ledgerSignPersonalMessage(transport, s.path, data)
+}
+
+func (s *LedgerSigner) reopen() error {
+	if err := s.wallet.Open(""); err != nil {
+		err = errors.Wrap(err, "failed to reconnect Ledger wallet")
+		return err
+	}
+
+	if _, err := s.wallet.Derive(s.path, true); err != nil {
+		err = errors.Wrapf(err, "failed to derive account at %s", s.path.String())
+		return err
+	}
+
+	return nil
+}
+
+// TypedDataSignFn signs EIP-712 typed data, the device shows domain and message hashes.
+func (s *LedgerSigner) TypedDataSignFn() TypedDataSignFn {
+	return func(from common.Address, typedData apitypes.TypedData) ([]byte, error) {
+		if from != s.account.Address {
+			return nil, errors.Errorf("not authorized to sign with %s", from.Hex())
+		}
+
+		hash, rawData, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			err = errors.Wrap(err, "failed to hash typed data")
+			return nil, err
+		}
+
+		s.mux.Lock()
+		sig, err := s.wallet.SignData(s.account, accounts.MimetypeTypedData, []byte(rawData))
+		s.mux.Unlock()
+		if err != nil {
+			err = errors.Wrap(err, "failed to sign typed data on Ledger")
+			return nil, err
+		} else if len(sig) != crypto.SignatureLength {
+			return nil, errors.Errorf("invalid signature length: %d", len(sig))
+		}
+
+		sig = NormalizeSignatureV(sig)
+		if err := verifySignature(hash, sig, from); err != nil {
+			return nil, err
+		}
+
+		return sig, nil
+	}
+}
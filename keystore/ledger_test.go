@@ -0,0 +1,317 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLedger is an accounts.Wallet deriving keys from a mnemonic, it signs like the Ledger driver
+// of usbwallet: transactions and EIP-712 typed data, but not text. Pinned accounts are dropped on close.
+type fakeLedger struct {
+	hd *HDWallet
+
+	opened  int
+	closed  int
+	derived int
+	paths   map[common.Address]accounts.DerivationPath
+}
+
+func newFakeLedger(t *testing.T) *fakeLedger {
+	hd, err := NewHDWallet("test test test test test test test test test test test junk", "")
+	require.NoError(t, err)
+
+	return &fakeLedger{
+		hd:    hd,
+		paths: make(map[common.Address]accounts.DerivationPath),
+	}
+}
+
+func (w *fakeLedger) URL() accounts.URL {
+	return accounts.URL{Scheme: "ledger", Path: "fake"}
+}
+
+func (w *fakeLedger) Status() (string, error) {
+	return "ok", nil
+}
+
+func (w *fakeLedger) Open(passphrase string) error {
+	w.opened++
+	return nil
+}
+
+func (w *fakeLedger) Close() error {
+	w.closed++
+	w.paths = make(map[common.Address]accounts.DerivationPath)
+	return nil
+}
+
+func (w *fakeLedger) Accounts() []accounts.Account {
+	var found []accounts.Account
+	for address, path := range w.paths {
+		found = append(found, w.account(address, path))
+	}
+
+	return found
+}
+
+func (w *fakeLedger) account(address common.Address, path accounts.DerivationPath) accounts.Account {
+	return accounts.Account{
+		Address: address,
+		URL:     accounts.URL{Scheme: "ledger", Path: "fake/" + path.String()},
+	}
+}
+
+func (w *fakeLedger) Contains(account accounts.Account) bool {
+	_, ok := w.paths[account.Address]
+	return ok
+}
+
+func (w *fakeLedger) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	w.derived++
+
+	pk, err := w.hd.Derive(path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+
+	acc := w.account(crypto.PubkeyToAddress(pk.PublicKey), path)
+	if pin {
+		w.paths[acc.Address] = path
+	}
+
+	return acc, nil
+}
+
+func (w *fakeLedger) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {}
+
+func (w *fakeLedger) key(account accounts.Account) (*ecdsa.PrivateKey, error) {
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+
+	return w.hd.Derive(path)
+}
+
+func (w *fakeLedger) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	if mimeType != accounts.MimetypeTypedData || len(data) != 66 || data[0] != 0x19 || data[1] != 0x01 {
+		return nil, accounts.ErrNotSupported
+	}
+
+	key, err := w.key(account)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(crypto.Keccak256(data), key)
+	if err != nil {
+		return nil, err
+	}
+
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+func (w *fakeLedger) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+func (w *fakeLedger) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+func (w *fakeLedger) SignTextWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+func (w *fakeLedger) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.key(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), key)
+}
+
+func (w *fakeLedger) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
+
+// fakeLedgerTransport replies to signPersonalMessage requests like the Ethereum app, with V, R and S.
+type fakeLedgerTransport struct {
+	hd *HDWallet
+
+	requests int
+	closed   bool
+	path     accounts.DerivationPath
+	length   int
+	message  []byte
+}
+
+func (t *fakeLedgerTransport) Exchange(ins, p1, p2 byte, data []byte) ([]byte, error) {
+	t.requests++
+
+	if ins != ledgerInsSignPersonalMessage || len(data) > ledgerMaxChunk {
+		return nil, errors.New("invalid request")
+	}
+
+	if p1 == ledgerP1FirstChunk {
+		t.path = make(accounts.DerivationPath, data[0])
+		for i := range t.path {
+			t.path[i] = binary.BigEndian.Uint32(data[1+4*i:])
+		}
+
+		data = data[1+4*len(t.path):]
+		t.length = int(binary.BigEndian.Uint32(data))
+		t.message = append([]byte{}, data[4:]...)
+	} else {
+		t.message = append(t.message, data...)
+	}
+
+	if len(t.message) < t.length {
+		return nil, nil
+	}
+
+	key, err := t.hd.Derive(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(accounts.TextHash(t.message), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{sig[crypto.RecoveryIDOffset] + 27}, sig[:crypto.RecoveryIDOffset]...), nil
+}
+
+func (t *fakeLedgerTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestLedgerPath(t *testing.T) {
+	livePathFn, err := LedgerPath(LedgerLivePath)
+	require.NoError(t, err)
+
+	path, err := livePathFn(2)
+	require.NoError(t, err)
+	assert.Equal(t, "m/44'/60'/2'/0/0", path.String())
+
+	legacyPathFn, err := LedgerPath(DefaultHDPath)
+	require.NoError(t, err)
+
+	path, err = legacyPathFn(2)
+	require.NoError(t, err)
+	assert.Equal(t, "m/44'/60'/0'/0/2", path.String())
+
+	_, err = LedgerPath("m/44'/x")
+	assert.Error(t, err)
+}
+
+func TestLedgerSigner(t *testing.T) {
+	ledger := newFakeLedger(t)
+
+	w, err := OpenLedgerWallet([]accounts.Wallet{ledger})
+	require.NoError(t, err)
+
+	pathFn, err := LedgerPath(DefaultHDPath)
+	require.NoError(t, err)
+
+	discovered, err := DiscoverLedgerAccounts(w, pathFn, 0, 3)
+	require.NoError(t, err)
+	require.Len(t, discovered, 3)
+	assert.Equal(t, common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"), discovered[2].Address)
+	assert.Empty(t, ledger.Accounts(), "discovered accounts must not be pinned")
+
+	account := discovered[2].Address
+	signer, err := NewLedgerSigner(w, account, pathFn, DefaultLedgerAccounts)
+	require.NoError(t, err)
+	assert.True(t, ledger.Contains(signer.Account()))
+
+	// pinned account is found without deriving again
+	derived := ledger.derived
+	_, err = NewLedgerSigner(w, account, pathFn, DefaultLedgerAccounts)
+	require.NoError(t, err)
+	assert.Equal(t, derived, ledger.derived)
+
+	signerFn := signer.SignerFn(1337)
+	to := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		signedTx, err := signerFn(account, types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(1337),
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(100),
+			Gas:       21000,
+			To:        &to,
+		}))
+		require.NoError(t, err)
+
+		sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1337)), signedTx)
+		require.NoError(t, err)
+		assert.Equal(t, account, sender)
+	}
+
+	// the wallet handle is kept open across signatures
+	assert.Equal(t, 1, ledger.opened)
+
+	_, err = signerFn(to, types.NewTx(&types.LegacyTx{Gas: 21000, To: &to}))
+	assert.Error(t, err)
+
+	typedData := testTypedData(account)
+	sig, err := signer.TypedDataSignFn()(account, typedData)
+	require.NoError(t, err)
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	pubKey, err := crypto.SigToPub(hash, sig)
+	require.NoError(t, err)
+	assert.Equal(t, account, crypto.PubkeyToAddress(*pubKey))
+
+	_, err = signer.PersonalSignFn()(account, []byte("hello"))
+	assert.ErrorContains(t, err, "not supported")
+
+	// with the transport, the derivation path of the pinned account is taken from its URL
+	transport := &fakeLedgerTransport{hd: ledger.hd}
+	signer, err = NewLedgerSigner(w, account, pathFn, DefaultLedgerAccounts, LedgerSignerTransport(func() (LedgerTransport, error) {
+		assert.Empty(t, ledger.paths, "wallet must be disconnected")
+		return transport, nil
+	}))
+	require.NoError(t, err)
+
+	// messages are sent in chunks of APDU data
+	message := []byte(strings.Repeat("hello ", 100))
+	sig, err = signer.PersonalSignFn()(account, message)
+	require.NoError(t, err)
+	assert.Equal(t, 3, transport.requests)
+	assert.True(t, transport.closed)
+
+	pubKey, err = crypto.SigToPub(accounts.TextHash(message), sig)
+	require.NoError(t, err)
+	assert.Equal(t, account, crypto.PubkeyToAddress(*pubKey))
+
+	// the wallet is connected again with the account pinned, so transactions are signed as before
+	assert.Equal(t, 1, ledger.closed)
+	assert.Equal(t, 2, ledger.opened)
+	assert.True(t, ledger.Contains(signer.Account()))
+
+	_, err = signer.SignerFn(1337)(account, types.NewTx(&types.LegacyTx{Gas: 21000, To: &to}))
+	require.NoError(t, err)
+
+	_, err = NewLedgerSigner(w, to, pathFn, 3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package keystore
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/karalabe/hid"
+	"github.com/pkg/errors"
+)
+
+const (
+	ledgerVendorID = 0x2c97
+
+	// ledgerCLA is the instruction class of the Ethereum app
+	ledgerCLA = 0xe0
+
+	// ledgerInsSignPersonalMessage signs the message with EIP-191 prefix, P1 marks the first chunk
+	ledgerInsSignPersonalMessage = 0x08
+	ledgerP1FirstChunk           = 0x00
+	ledgerP1NextChunk            = 0x80
+
+	// ledgerMaxChunk is the max length of APDU data
+	ledgerMaxChunk = 255
+
+	ledgerStatusOK     = 0x9000
+	ledgerStatusDenied = 0x6985
+)
+
+// LedgerTransport exchanges APDU requests with the Ethereum app of a Ledger device.
+type LedgerTransport interface {
+	// Exchange sends the request and returns the reply without the status word,
+	// statuses other than success are returned as errors.
+	Exchange(ins, p1, p2 byte, data []byte) ([]byte, error)
+	Close() error
+}
+
+// LedgerTransportFn connects to the device, it's called while the wallet is disconnected.
+type LedgerTransportFn func() (LedgerTransport, error)
+
+// ledgerSignPersonalMessage sends the signPersonalMessage request, the first chunk carries the derivation path
+// and the message length. The device replies with V, R and S, the signature is returned as [R || S || V].
+//
+//	Description                      | Length
+//	---------------------------------+----------
+//	Number of BIP 32 derivations     | 1 byte
+//	Derivation index (big endian)    | 4 bytes each
+//	Message length (big endian)      | 4 bytes
+//	Message                          | arbitrary
+func ledgerSignPersonalMessage(t LedgerTransport, derivationPath accounts.DerivationPath, message []byte) ([]byte, error) {
+	payload := make([]byte, 1+4*len(derivationPath)+4, 1+4*len(derivationPath)+4+len(message))
+	payload[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(payload[1+4*i:], component)
+	}
+	binary.BigEndian.PutUint32(payload[1+4*len(derivationPath):], uint32(len(message)))
+	payload = append(payload, message...)
+
+	var (
+		p1    byte = ledgerP1FirstChunk
+		reply []byte
+		err   error
+	)
+
+	for len(payload) > 0 {
+		chunk := ledgerMaxChunk
+		if len(payload) < chunk {
+			chunk = len(payload)
+		}
+
+		reply, err = t.Exchange(ledgerInsSignPersonalMessage, p1, 0, payload[:chunk])
+		if err != nil {
+			return nil, err
+		}
+
+		payload = payload[chunk:]
+		p1 = ledgerP1NextChunk
+	}
+
+	if len(reply) != crypto.SignatureLength {
+		return nil, errors.New("reply lacks signature")
+	}
+
+	sig := append(reply[1:crypto.SignatureLength:crypto.SignatureLength], reply[0])
+	return sig, nil
+}
+
+// LedgerHIDTransport connects to the Ledger device of the wallet URL, usbwallet uses the device path as URL path.
+func LedgerHIDTransport(url accounts.URL) LedgerTransportFn {
+	return func() (LedgerTransport, error) {
+		infos, err := hid.Enumerate(ledgerVendorID, 0)
+		if err != nil {
+			err = errors.Wrap(err, "failed to enumerate USB devices")
+			return nil, err
+		}
+
+		for _, info := range infos {
+			if info.Path != url.Path {
+				continue
+			}
+
+			device, err := info.Open()
+			if err != nil {
+				return nil, err
+			}
+
+			return &ledgerHIDTransport{device: device}, nil
+		}
+
+		return nil, errors.Errorf("Ledger device not found: %s", url.String())
+	}
+}
+
+type ledgerHIDTransport struct {
+	device hid.Device
+}
+
+func (t *ledgerHIDTransport) Close() error {
+	return t.device.Close()
+}
+
+// Exchange frames the APDU into 64 byte HID packets, each with channel ID 0x0101,
+// APDU tag 0x05 and the packet sequence index. The first packet of the request and
+// of the reply starts with the length of the APDU.
+func (t *ledgerHIDTransport) Exchange(ins, p1, p2 byte, data []byte) ([]byte, error) {
+	if len(data) > ledgerMaxChunk {
+		return nil, errors.Errorf("APDU data too long: %d", len(data))
+	}
+
+	apdu := make([]byte, 2, 7+len(data))
+	binary.BigEndian.PutUint16(apdu, uint16(5+len(data)))
+	apdu = append(apdu, ledgerCLA, ins, p1, p2, byte(len(data)))
+	apdu = append(apdu, data...)
+
+	header := []byte{0x01, 0x01, 0x05, 0x00, 0x00}
+	packet := make([]byte, 64)
+	space := len(packet) - len(header)
+
+	for seq := 0; len(apdu) > 0; seq++ {
+		packet = append(packet[:0], header...)
+		binary.BigEndian.PutUint16(packet[3:], uint16(seq))
+
+		n := space
+		if len(apdu) < n {
+			n = len(apdu)
+		}
+
+		packet = append(packet, apdu[:n]...)
+		apdu = apdu[n:]
+
+		if _, err := t.device.Write(packet); err != nil {
+			err = errors.Wrap(err, "failed to write to Ledger device")
+			return nil, err
+		}
+	}
+
+	var reply []byte
+	packet = packet[:64]
+	for seq := 0; ; seq++ {
+		if _, err := io.ReadFull(t.device, packet); err != nil {
+			err = errors.Wrap(err, "failed to read from Ledger device")
+			return nil, err
+		}
+
+		if packet[0] != 0x01 || packet[1] != 0x01 || packet[2] != 0x05 || int(binary.BigEndian.Uint16(packet[3:])) != seq {
+			return nil, errors.New("invalid reply header from Ledger device")
+		}
+
+		payload := packet[5:]
+		if seq == 0 {
+			reply = make([]byte, 0, int(binary.BigEndian.Uint16(packet[5:7])))
+			payload = packet[7:]
+		}
+
+		if left := cap(reply) - len(reply); left > len(payload) {
+			reply = append(reply, payload...)
+		} else {
+			reply = append(reply, payload[:left]...)
+			break
+		}
+	}
+
+	if len(reply) < 2 {
+		return nil, errors.New("reply lacks status from Ledger device")
+	}
+
+	switch status := binary.BigEndian.Uint16(reply[len(reply)-2:]); status {
+	case ledgerStatusOK:
+		return reply[:len(reply)-2], nil
+	case ledgerStatusDenied:
+		return nil, errors.New("request denied on Ledger device")
+	default:
+		return nil, errors.Errorf("Ledger device replied with status 0x%04x", status)
+	}
+}
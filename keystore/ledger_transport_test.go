@@ -0,0 +1,79 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHIDDevice records written packets and reads the prepared reply.
+type fakeHIDDevice struct {
+	written [][]byte
+	reply   *bytes.Buffer
+}
+
+func (d *fakeHIDDevice) Close() error { return nil }
+
+func (d *fakeHIDDevice) Write(b []byte) (int, error) {
+	d.written = append(d.written, append([]byte{}, b...))
+	return len(b), nil
+}
+
+func (d *fakeHIDDevice) Read(b []byte) (int, error)                     { return d.reply.Read(b) }
+func (d *fakeHIDDevice) ReadTimeout(b []byte, timeout int) (int, error) { return d.reply.Read(b) }
+func (d *fakeHIDDevice) GetFeatureReport(b []byte) (int, error)         { return 0, nil }
+func (d *fakeHIDDevice) SendFeatureReport(b []byte) (int, error)        { return 0, nil }
+
+// hidReply frames the reply into 64 byte packets of the Ledger HID protocol.
+func hidReply(reply []byte) *bytes.Buffer {
+	out := new(bytes.Buffer)
+
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(reply)))
+	data = append(data, reply...)
+
+	for seq := 0; len(data) > 0; seq++ {
+		packet := make([]byte, 64)
+		copy(packet, []byte{0x01, 0x01, 0x05})
+		binary.BigEndian.PutUint16(packet[3:], uint16(seq))
+
+		n := copy(packet[5:], data)
+		data = data[n:]
+		out.Write(packet)
+	}
+
+	return out
+}
+
+func TestLedgerHIDTransport(t *testing.T) {
+	signature := bytes.Repeat([]byte{0xab}, 65)
+
+	device := &fakeHIDDevice{reply: hidReply(append(signature, 0x90, 0x00))}
+	transport := &ledgerHIDTransport{device: device}
+
+	data := bytes.Repeat([]byte{0x01}, 100)
+	reply, err := transport.Exchange(ledgerInsSignPersonalMessage, ledgerP1NextChunk, 0, data)
+	require.NoError(t, err)
+	assert.Equal(t, signature, reply)
+
+	// the APDU with its length takes 107 bytes, so two packets
+	require.Len(t, device.written, 2)
+	assert.Equal(t, []byte{0x01, 0x01, 0x05, 0x00, 0x00, 0x00, 105, 0xe0, 0x08, 0x80, 0x00, 100}, device.written[0][:12])
+	assert.Equal(t, []byte{0x01, 0x01, 0x05, 0x00, 0x01}, device.written[1][:5])
+	assert.Len(t, device.written[0], 64)
+	assert.Len(t, device.written[1], 5+107-59)
+
+	// statuses other than success are errors
+	device.reply = hidReply([]byte{0x69, 0x85})
+	_, err = transport.Exchange(ledgerInsSignPersonalMessage, ledgerP1FirstChunk, 0, data)
+	assert.ErrorContains(t, err, "denied")
+
+	device.reply = hidReply([]byte{0x6a, 0x80})
+	_, err = transport.Exchange(ledgerInsSignPersonalMessage, ledgerP1FirstChunk, 0, data)
+	assert.ErrorContains(t, err, "0x6a80")
+
+	_, err = transport.Exchange(ledgerInsSignPersonalMessage, ledgerP1FirstChunk, 0, make([]byte, 256))
+	assert.Error(t, err)
+}
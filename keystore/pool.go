@@ -0,0 +1,244 @@
+package keystore
+
+import (
+	"container/heap"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// PendingNonceFn returns the nonce of the next transaction of the account, including pending ones.
+type PendingNonceFn func(ctx context.Context, account common.Address) (uint64, error)
+
+// PoolSender is the account handed out by the pool with a nonce reserved for one transaction.
+type PoolSender struct {
+	From     common.Address
+	Nonce    uint64
+	SignerFn SignerFn
+}
+
+// SignerPool hands out accounts round-robin, so transactions can be sent from many accounts in parallel.
+// Nonces are tracked per account, starting at the pending nonce of the first use.
+type SignerPool struct {
+	pendingNonceFn PendingNonceFn
+
+	accounts []common.Address
+	signers  map[common.Address]SignerFn
+
+	mux    *sync.Mutex
+	next   int
+	nonces map[common.Address]*accountNonce
+}
+
+// accountNonce is the next nonce of the account, it's locked while the pending nonce is requested,
+// so other accounts of the pool are not blocked. Released nonces are handed out again first, lowest one
+// first, so the account has no gaps in nonces that would keep later transactions queued.
+type accountNonce struct {
+	mux      sync.Mutex
+	nonce    uint64
+	known    bool
+	released nonceHeap
+}
+
+// nonceHeap is a min-heap of nonces, see container/heap.
+type nonceHeap []uint64
+
+func (h nonceHeap) Len() int            { return len(h) }
+func (h nonceHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h nonceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nonceHeap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+
+func (h *nonceHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+func (h nonceHeap) contains(nonce uint64) bool {
+	for _, v := range h {
+		if v == nonce {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewSignerPool(pendingNonceFn PendingNonceFn) *SignerPool {
+	return &SignerPool{
+		pendingNonceFn: pendingNonceFn,
+		signers:        make(map[common.Address]SignerFn),
+		mux:            new(sync.Mutex),
+		nonces:         make(map[common.Address]*accountNonce),
+	}
+}
+
+// NewKeystoreSignerPool unlocks keystore accounts with the same password and adds them to the pool.
+func NewKeystoreSignerPool(
+	ks EthKeyStore,
+	chainID uint64,
+	accounts []common.Address,
+	password string,
+	pendingNonceFn PendingNonceFn,
+) (*SignerPool, error) {
+	pool := NewSignerPool(pendingNonceFn)
+
+	for _, account := range accounts {
+		signerFn, err := ks.SignerFn(chainID, account, password)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to unlock %s", account.Hex())
+			return nil, err
+		}
+
+		if err := pool.Add(account, signerFn); err != nil {
+			return nil, err
+		}
+	}
+
+	return pool, nil
+}
+
+// NewHDSignerPool adds count accounts derived from the wallet at base path, starting at firstIndex.
+func NewHDSignerPool(
+	wallet *HDWallet,
+	basePath string,
+	firstIndex uint32,
+	count int,
+	chainID uint64,
+	pendingNonceFn PendingNonceFn,
+) (*SignerPool, error) {
+	pool := NewSignerPool(pendingNonceFn)
+
+	for i := 0; i < count; i++ {
+		path, err := ParseHDPath(basePath, firstIndex+uint32(i))
+		if err != nil {
+			return nil, err
+		}
+
+		pk, err := wallet.Derive(path)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to derive key at %s", path.String())
+			return nil, err
+		}
+
+		if err := pool.AddPrivateKey(chainID, pk); err != nil {
+			return nil, err
+		}
+	}
+
+	return pool, nil
+}
+
+// Add adds the account with its signer, an account can be added once.
+func (p *SignerPool) Add(account common.Address, signerFn SignerFn) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if _, ok := p.signers[account]; ok {
+		return errors.Errorf("account %s is already in the pool", account.Hex())
+	}
+
+	p.accounts = append(p.accounts, account)
+	p.signers[account] = signerFn
+	p.nonces[account] = new(accountNonce)
+
+	return nil
+}
+
+func (p *SignerPool) AddPrivateKey(chainID uint64, pk *ecdsa.PrivateKey) error {
+	txOpts, err := bind.NewKeyedTransactorWithChainID(pk, new(big.Int).SetUint64(chainID))
+	if err != nil {
+		err = errors.Wrap(err, "failed to init keyed transactor")
+		return err
+	}
+
+	return p.Add(crypto.PubkeyToAddress(pk.PublicKey), txOpts.Signer)
+}
+
+func (p *SignerPool) Accounts() []common.Address {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return append([]common.Address{}, p.accounts...)
+}
+
+func (p *SignerPool) Len() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return len(p.accounts)
+}
+
+// Next returns the next account of the pool and reserves its nonce.
+func (p *SignerPool) Next(ctx context.Context) (*PoolSender, error) {
+	p.mux.Lock()
+	if len(p.accounts) == 0 {
+		p.mux.Unlock()
+		return nil, errors.New("signer pool is empty")
+	}
+
+	account := p.accounts[p.next]
+	p.next = (p.next + 1) % len(p.accounts)
+
+	signerFn := p.signers[account]
+	n := p.nonces[account]
+	p.mux.Unlock()
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if !n.known {
+		pendingNonce, err := p.pendingNonceFn(ctx, account)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to get pending nonce of %s", account.Hex())
+			return nil, err
+		}
+
+		n.nonce = pendingNonce
+		n.known = true
+	}
+
+	sender := &PoolSender{
+		From:     account,
+		SignerFn: signerFn,
+	}
+
+	if n.released.Len() > 0 {
+		sender.Nonce = heap.Pop(&n.released).(uint64)
+		return sender, nil
+	}
+
+	sender.Nonce = n.nonce
+	n.nonce++
+
+	return sender, nil
+}
+
+// ResetNonce releases the reserved nonce of the account, so it's handed out again before any new one.
+// Must be called when a transaction with the reserved nonce was not sent.
+func (p *SignerPool) ResetNonce(account common.Address, failedNonce uint64) {
+	p.mux.Lock()
+	n, ok := p.nonces[account]
+	p.mux.Unlock()
+
+	if !ok {
+		return
+	}
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if !n.known || failedNonce >= n.nonce || n.released.contains(failedNonce) {
+		return
+	}
+
+	heap.Push(&n.released, failedNonce)
+}
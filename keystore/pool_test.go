@@ -0,0 +1,127 @@
+package keystore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignerPool(t *testing.T) {
+	wallet, err := NewHDWallet("test test test test test test test test test test test junk", "")
+	require.NoError(t, err)
+
+	var nonceRequests int32
+	pendingNonceFn := func(ctx context.Context, account common.Address) (uint64, error) {
+		atomic.AddInt32(&nonceRequests, 1)
+		return 5, nil
+	}
+
+	pool, err := NewHDSignerPool(wallet, DefaultHDPath, 1, 3, 1337, pendingNonceFn)
+	require.NoError(t, err)
+	require.Equal(t, 3, pool.Len())
+
+	// second Anvil account
+	assert.Equal(t, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), pool.Accounts()[0])
+
+	var (
+		mux   sync.Mutex
+		seen  = make(map[common.Address][]uint64)
+		wg    sync.WaitGroup
+		count = 9
+	)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sender, err := pool.Next(context.Background())
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			mux.Lock()
+			seen[sender.From] = append(seen[sender.From], sender.Nonce)
+			mux.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 3, nonceRequests)
+	for _, account := range pool.Accounts() {
+		assert.ElementsMatch(t, []uint64{5, 6, 7}, seen[account])
+	}
+
+	// a released nonce is handed out again, even if later ones are reserved
+	pool.ResetNonce(pool.Accounts()[0], 6)
+	sender, err := pool.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, pool.Accounts()[0], sender.From)
+	assert.Equal(t, uint64(6), sender.Nonce)
+
+	// released nonces go lowest first, before new ones, unknown and repeated ones are ignored
+	pool.ResetNonce(sender.From, 7)
+	pool.ResetNonce(sender.From, 5)
+	pool.ResetNonce(sender.From, 5)
+	pool.ResetNonce(sender.From, 8)
+	pool.ResetNonce(common.Address{}, 5)
+
+	var nonces []uint64
+	for i := 0; i < 9; i++ {
+		sender, err = pool.Next(context.Background())
+		require.NoError(t, err)
+
+		if sender.From == pool.Accounts()[0] {
+			nonces = append(nonces, sender.Nonce)
+		}
+	}
+	assert.Equal(t, []uint64{5, 7, 8}, nonces)
+	assert.EqualValues(t, 3, nonceRequests)
+
+	assert.Error(t, pool.Add(sender.From, sender.SignerFn))
+}
+
+func TestSignerPoolNonceRequests(t *testing.T) {
+	wallet, err := NewHDWallet("test test test test test test test test test test test junk", "")
+	require.NoError(t, err)
+
+	var first int32
+	accounts := make(chan common.Address, 1)
+	release := make(chan struct{})
+	pendingNonceFn := func(ctx context.Context, account common.Address) (uint64, error) {
+		// the first request waits, others proceed
+		if atomic.CompareAndSwapInt32(&first, 0, 1) {
+			accounts <- account
+			<-release
+		}
+
+		return 1, nil
+	}
+
+	pool, err := NewHDSignerPool(wallet, DefaultHDPath, 0, 2, 1337, pendingNonceFn)
+	require.NoError(t, err)
+
+	done := make(chan *PoolSender)
+	go func() {
+		sender, err := pool.Next(context.Background())
+		assert.NoError(t, err)
+		done <- sender
+	}()
+
+	assert.Equal(t, pool.Accounts()[0], <-accounts)
+
+	// the pending nonce of another account is requested while the first one is in flight
+	sender, err := pool.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, pool.Accounts()[1], sender.From)
+
+	close(release)
+	sender = <-done
+	assert.Equal(t, pool.Accounts()[0], sender.From)
+	assert.Equal(t, uint64(1), sender.Nonce)
+}
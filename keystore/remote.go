@@ -0,0 +1,416 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+const (
+	remoteSignerPublicKeysPath = "/api/v1/eth1/publicKeys"
+	remoteSignerSignPath       = "/api/v1/eth1/sign/"
+)
+
+// RemoteSigner signs with keys held by a Web3Signer-compatible HTTP service. Signing payloads
+// are sent to it, signatures are checked to recover to the account before use.
+type RemoteSigner struct {
+	baseURL *url.URL
+	client  *http.Client
+	token   string
+}
+
+type remoteSignerOptions struct {
+	TLSConfig   *tls.Config
+	BearerToken string
+	Timeout     time.Duration
+}
+
+type RemoteSignerOption func(o *remoteSignerOptions) error
+
+// RemoteSignerBearerToken sets the token sent in Authorization header of each request.
+func RemoteSignerBearerToken(token string) RemoteSignerOption {
+	return func(o *remoteSignerOptions) error {
+		o.BearerToken = strings.TrimSpace(token)
+		return nil
+	}
+}
+
+// RemoteSignerTLS sets CA certificates to verify the signer with, and the client certificate
+// for mutual TLS. Empty paths are ignored, system CAs are used if caFile is empty.
+func RemoteSignerTLS(caFile, certFile, keyFile string) RemoteSignerOption {
+	return func(o *remoteSignerOptions) error {
+		tlsConfig := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+
+		if len(caFile) > 0 {
+			caPEM, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				err = errors.Wrap(err, "failed to read signer CA file")
+				return err
+			}
+
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+				return errors.Errorf("no PEM certificates found in %s", caFile)
+			}
+		}
+
+		if len(certFile) > 0 || len(keyFile) > 0 {
+			if len(certFile) == 0 || len(keyFile) == 0 {
+				return errors.New("both client certificate and key files must be specified")
+			}
+
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				err = errors.Wrap(err, "failed to load client certificate")
+				return err
+			}
+
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		o.TLSConfig = tlsConfig
+		return nil
+	}
+}
+
+// RemoteSignerTimeout limits the duration of each request to the signer.
+func RemoteSignerTimeout(dur time.Duration) RemoteSignerOption {
+	return func(o *remoteSignerOptions) error {
+		if dur > time.Millisecond {
+			o.Timeout = dur
+		}
+
+		return nil
+	}
+}
+
+// NewRemoteSigner returns a client of the signer at the base URL, e.g. https://web3signer:9000.
+func NewRemoteSigner(signerURL string, options ...RemoteSignerOption) (*RemoteSigner, error) {
+	opts := &remoteSignerOptions{
+		Timeout: 30 * time.Second,
+	}
+
+	for _, optFn := range options {
+		if err := optFn(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	baseURL, err := url.ParseRequestURI(signerURL)
+	if err != nil {
+		err = errors.Wrap(err, "failed to parse signer URL")
+		return nil, err
+	} else if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, errors.Errorf("unsupported signer URL scheme: %s", baseURL.Scheme)
+	}
+
+	baseURL.Path = strings.TrimSuffix(baseURL.Path, "/")
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.TLSConfig != nil {
+		transport.TLSClientConfig = opts.TLSConfig
+	}
+
+	s := &RemoteSigner{
+		baseURL: baseURL,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+		},
+		token: opts.BearerToken,
+	}
+
+	return s, nil
+}
+
+// PublicKey finds the identifier of the account key among public keys served by the signer.
+func (s *RemoteSigner) PublicKey(ctx context.Context, account common.Address) (string, error) {
+	var publicKeys []string
+	if err := s.do(ctx, http.MethodGet, remoteSignerPublicKeysPath, nil, &publicKeys); err != nil {
+		err = errors.Wrap(err, "failed to list signer public keys")
+		return "", err
+	}
+
+	for _, publicKey := range publicKeys {
+		pubBytes, err := hexutil.Decode(publicKey)
+		if err != nil {
+			continue
+		}
+
+		if len(pubBytes) == 64 {
+			// Web3Signer omits the 0x04 prefix of uncompressed keys
+			pubBytes = append([]byte{4}, pubBytes...)
+		}
+
+		pubKey, err := crypto.UnmarshalPubkey(pubBytes)
+		if err != nil {
+			continue
+		}
+
+		if crypto.PubkeyToAddress(*pubKey) == account {
+			return publicKey, nil
+		}
+	}
+
+	return "", errors.Errorf("account %s not found on the signer", account.Hex())
+}
+
+// Sign asks the signer to sign keccak256 hash of the data with the key, V of the signature is 0 or 1.
+func (s *RemoteSigner) Sign(ctx context.Context, publicKey string, data []byte) ([]byte, error) {
+	req := map[string]string{
+		"data": hexutil.Encode(data),
+	}
+
+	var res string
+	if err := s.do(ctx, http.MethodPost, remoteSignerSignPath+url.PathEscape(publicKey), req, &res); err != nil {
+		err = errors.Wrap(err, "failed to sign with remote signer")
+		return nil, err
+	}
+
+	sig, err := hexutil.Decode(strings.TrimSpace(res))
+	if err != nil {
+		err = errors.Wrap(err, "failed to decode signature")
+		return nil, err
+	} else if len(sig) != crypto.SignatureLength {
+		return nil, errors.Errorf("invalid signature length: %d", len(sig))
+	}
+
+	return NormalizeSignatureV(sig), nil
+}
+
+// SignerFn returns a SignerFn that signs transactions of the account with the remote signer.
+func (s *RemoteSigner) SignerFn(ctx context.Context, chainID uint64, account common.Address) (SignerFn, error) {
+	publicKey, err := s.PublicKey(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	chainIDInt := new(big.Int).SetUint64(chainID)
+	txSigner := types.LatestSignerForChainID(chainIDInt)
+
+	signerFn := func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if from != account {
+			return nil, bind.ErrNotAuthorized
+		}
+
+		payload, err := txSigningPayload(chainIDInt, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		sigHash := txSigner.Hash(tx)
+		if crypto.Keccak256Hash(payload) != sigHash {
+			return nil, errors.Errorf("unsupported tx type %d", tx.Type())
+		}
+
+		sig, err := s.Sign(context.Background(), publicKey, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifySignature(sigHash[:], sig, account); err != nil {
+			return nil, err
+		}
+
+		return tx.WithSignature(txSigner, sig)
+	}
+
+	return signerFn, nil
+}
+
+// PersonalSignFn returns a PersonalSignFn that signs EIP-191 text messages of the account with the remote signer.
+func (s *RemoteSigner) PersonalSignFn(ctx context.Context, account common.Address) (PersonalSignFn, error) {
+	publicKey, err := s.PublicKey(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	signFn := func(from common.Address, data []byte) (sig []byte, err error) {
+		if from != account {
+			return nil, errors.New("from address mismatch")
+		}
+
+		msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(data), data)
+		sig, err = s.Sign(context.Background(), publicKey, []byte(msg))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifySignature(accounts.TextHash(data), sig, account); err != nil {
+			return nil, err
+		}
+
+		return sig, nil
+	}
+
+	return signFn, nil
+}
+
+// TypedDataSignFn returns a TypedDataSignFn that signs EIP-712 typed data of the account with the remote signer.
+func (s *RemoteSigner) TypedDataSignFn(ctx context.Context, account common.Address) (TypedDataSignFn, error) {
+	publicKey, err := s.PublicKey(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	signFn := func(from common.Address, typedData apitypes.TypedData) (sig []byte, err error) {
+		if from != account {
+			return nil, errors.New("from address mismatch")
+		}
+
+		hash, rawData, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			err = errors.Wrap(err, "failed to hash typed data")
+			return nil, err
+		}
+
+		sig, err = s.Sign(context.Background(), publicKey, []byte(rawData))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifySignature(hash, sig, account); err != nil {
+			return nil, err
+		}
+
+		return sig, nil
+	}
+
+	return signFn, nil
+}
+
+func (s *RemoteSigner) do(ctx context.Context, method, path string, body, res interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(data)
+	}
+
+	reqURL := *s.baseURL
+	reqURL.Path += path
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if len(s.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	// Web3Signer returns signatures as plain text, strings may be JSON-encoded by other servers
+	if str, ok := res.(*string); ok && !bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*str = string(data)
+		return nil
+	}
+
+	return json.Unmarshal(data, res)
+}
+
+// txSigningPayload returns RLP-encoded fields of the tx, keccak256 of it is the hash signed.
+func txSigningPayload(chainID *big.Int, tx *types.Transaction) ([]byte, error) {
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return rlp.EncodeToBytes([]interface{}{
+			tx.Nonce(),
+			tx.GasPrice(),
+			tx.Gas(),
+			tx.To(),
+			tx.Value(),
+			tx.Data(),
+			chainID, uint(0), uint(0),
+		})
+
+	case types.AccessListTxType:
+		payload, err := rlp.EncodeToBytes([]interface{}{
+			chainID,
+			tx.Nonce(),
+			tx.GasPrice(),
+			tx.Gas(),
+			tx.To(),
+			tx.Value(),
+			tx.Data(),
+			tx.AccessList(),
+		})
+
+		return append([]byte{tx.Type()}, payload...), err
+
+	case types.DynamicFeeTxType:
+		payload, err := rlp.EncodeToBytes([]interface{}{
+			chainID,
+			tx.Nonce(),
+			tx.GasTipCap(),
+			tx.GasFeeCap(),
+			tx.Gas(),
+			tx.To(),
+			tx.Value(),
+			tx.Data(),
+			tx.AccessList(),
+		})
+
+		return append([]byte{tx.Type()}, payload...), err
+
+	default:
+		return nil, errors.Errorf("unsupported tx type %d", tx.Type())
+	}
+}
+
+func verifySignature(hash, sig []byte, account common.Address) error {
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		err = errors.Wrap(err, "failed to recover signer")
+		return err
+	}
+
+	if signer := crypto.PubkeyToAddress(*pubKey); signer != account {
+		return errors.Errorf("signed by %s instead of %s", signer.Hex(), account.Hex())
+	}
+
+	return nil
+}
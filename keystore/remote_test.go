@@ -0,0 +1,218 @@
+package keystore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStubWeb3Signer serves eth1 endpoints of Web3Signer, signing with signKey for publicKey of key.
+func newStubWeb3Signer(t *testing.T, key, signKey *ecdsa.PrivateKey, token string) *httptest.Server {
+	publicKey := hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey)[1:])
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(remoteSignerPublicKeysPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]string{publicKey})
+	})
+
+	mux.HandleFunc(remoteSignerSignPath, func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, remoteSignerSignPath) != publicKey {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sig, err := crypto.Sign(crypto.Keccak256(req.Data), signKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sig[crypto.RecoveryIDOffset] += 27
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(hexutil.Encode(sig)))
+	})
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeServerCA(t *testing.T, srv *httptest.Server) string {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: srv.Certificate().Raw,
+	})
+
+	require.NoError(t, ioutil.WriteFile(caFile, caPEM, 0600))
+	return caFile
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	srv := newStubWeb3Signer(t, key, key, "secret")
+	signer, err := NewRemoteSigner(
+		srv.URL,
+		RemoteSignerBearerToken("secret"),
+		RemoteSignerTLS(writeServerCA(t, srv), "", ""),
+	)
+	require.NoError(t, err)
+
+	signerFn, err := signer.SignerFn(context.Background(), 1337, account)
+	require.NoError(t, err)
+
+	to := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	txs := []*types.Transaction{
+		types.NewTx(&types.LegacyTx{
+			Nonce:    1,
+			GasPrice: big.NewInt(50),
+			Gas:      21000,
+			To:       &to,
+			Value:    big.NewInt(10),
+		}),
+		types.NewTx(&types.AccessListTx{
+			ChainID:  big.NewInt(1337),
+			Nonce:    2,
+			GasPrice: big.NewInt(50),
+			Gas:      50000,
+			To:       &to,
+			AccessList: types.AccessList{{
+				Address:     to,
+				StorageKeys: []common.Hash{{1}},
+			}},
+		}),
+		types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(1337),
+			Nonce:     3,
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(100),
+			Gas:       100000,
+			Data:      []byte{0x60, 0x80},
+		}),
+	}
+
+	for _, tx := range txs {
+		signedTx, err := signerFn(account, tx)
+		require.NoError(t, err)
+
+		sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1337)), signedTx)
+		require.NoError(t, err)
+		assert.Equal(t, account, sender)
+	}
+
+	personalSignFn, err := signer.PersonalSignFn(context.Background(), account)
+	require.NoError(t, err)
+
+	msg := []byte("hello")
+	sig, err := personalSignFn(account, msg)
+	require.NoError(t, err)
+
+	expectedSig, err := crypto.Sign(accounts.TextHash(msg), key)
+	require.NoError(t, err)
+	assert.Equal(t, expectedSig, sig)
+
+	typedDataSignFn, err := signer.TypedDataSignFn(context.Background(), account)
+	require.NoError(t, err)
+
+	typedData := testTypedData(account)
+	sig, err = typedDataSignFn(account, typedData)
+	require.NoError(t, err)
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	expectedSig, err = crypto.Sign(hash, key)
+	require.NoError(t, err)
+	assert.Equal(t, expectedSig, sig)
+
+	_, err = signer.SignerFn(context.Background(), 1337, common.HexToAddress("0x01"))
+	assert.Error(t, err)
+}
+
+func TestRemoteSignerRejectsWrongSignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	srv := newStubWeb3Signer(t, key, otherKey, "secret")
+	signer, err := NewRemoteSigner(
+		srv.URL,
+		RemoteSignerBearerToken("secret"),
+		RemoteSignerTLS(writeServerCA(t, srv), "", ""),
+	)
+	require.NoError(t, err)
+
+	signerFn, err := signer.SignerFn(context.Background(), 1, account)
+	require.NoError(t, err)
+
+	to := common.HexToAddress("0x33832d3A5e359A0689088c832755461dDaD5d41B")
+	_, err = signerFn(account, types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(50),
+		Gas:      21000,
+		To:       &to,
+	}))
+	assert.Error(t, err)
+}
+
+func TestRemoteSignerAuth(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	srv := newStubWeb3Signer(t, key, key, "secret")
+
+	// unknown CA
+	signer, err := NewRemoteSigner(srv.URL, RemoteSignerBearerToken("secret"))
+	require.NoError(t, err)
+	_, err = signer.SignerFn(context.Background(), 1, account)
+	assert.Error(t, err)
+
+	// wrong token
+	signer, err = NewRemoteSigner(
+		srv.URL,
+		RemoteSignerBearerToken("guess"),
+		RemoteSignerTLS(writeServerCA(t, srv), "", ""),
+	)
+	require.NoError(t, err)
+	_, err = signer.SignerFn(context.Background(), 1, account)
+	assert.Error(t, err)
+}
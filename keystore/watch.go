@@ -0,0 +1,100 @@
+package keystore
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/xlab/suplog"
+)
+
+const (
+	watchDebounce     = 100 * time.Millisecond
+	watchPollInterval = 2 * time.Second
+)
+
+// Watch rescans keystore paths when key files are added, changed or removed, until ctx is done.
+// If filesystem notifications are not available, paths are polled.
+func (ks *keyStore) Watch(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.WithError(err).Warningln("failed to init keystore watcher, polling for changes")
+		ks.poll(ctx)
+		return
+	}
+	defer watcher.Close()
+
+	ks.addWatches(watcher)
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if ev.Op != fsnotify.Chmod {
+				debounce.Reset(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.WithError(err).Warningln("keystore watcher error")
+		case <-debounce.C:
+			ks.reloadPathsCache()
+
+			// new subdirectories need watches too
+			ks.addWatches(watcher)
+		}
+	}
+}
+
+func (ks *keyStore) poll(ctx context.Context) {
+	t := time.NewTicker(watchPollInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			ks.reloadPathsCache()
+		}
+	}
+}
+
+func (ks *keyStore) addWatches(watcher *fsnotify.Watcher) {
+	for _, keystorePath := range ks.Paths() {
+		if !ks.recursive {
+			if err := watcher.Add(keystorePath); err != nil {
+				log.WithField("keystore", keystorePath).WithError(err).Warningln("failed to watch keystore")
+			}
+
+			continue
+		}
+
+		_ = filepath.WalkDir(keystorePath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			} else if path != keystorePath && nonKeyFile(d.Name()) {
+				return filepath.SkipDir
+			}
+
+			if err := watcher.Add(path); err != nil {
+				log.WithField("path", path).WithError(err).Warningln("failed to watch keystore")
+			}
+
+			return nil
+		})
+	}
+}
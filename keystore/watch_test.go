@@ -0,0 +1,76 @@
+package keystore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyStoreScanTolerant(t *testing.T) {
+	dir := t.TempDir()
+	subDir := filepath.Join(dir, "team")
+	require.NoError(t, os.Mkdir(subDir, 0700))
+
+	src, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	account, err := src.NewKey(subDir, "pass", LightScryptParams)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "garbage.json"), []byte("{not json"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("keys"), 0600))
+
+	ks, err := New(dir)
+	require.NoError(t, err)
+	assert.Empty(t, ks.Accounts())
+
+	ks, err = NewWithOptions([]string{dir}, OptionRecursive(true))
+	require.NoError(t, err)
+	assert.Equal(t, account, ks.Accounts()[0])
+	assert.Len(t, ks.Accounts(), 1)
+
+	_, err = ks.PrivateKey(account, "pass")
+	assert.NoError(t, err)
+}
+
+func TestKeyStoreWatch(t *testing.T) {
+	dir := t.TempDir()
+
+	ks, err := NewWithOptions([]string{dir}, OptionRecursive(true))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ks.Watch(ctx)
+
+	// keys are created elsewhere, as by another process
+	src, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	subDir := filepath.Join(dir, "team")
+	require.NoError(t, os.Mkdir(subDir, 0700))
+	time.Sleep(2 * watchDebounce)
+
+	account, err := src.NewKey(subDir, "pass", LightScryptParams)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := ks.PrivateKey(account, "pass")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	ks.LockAll()
+	wallets := src.Wallets()
+	require.Len(t, wallets, 1)
+	require.NoError(t, os.Remove(wallets[0].Path))
+
+	assert.Eventually(t, func() bool {
+		_, err := ks.PrivateKey(account, "pass")
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
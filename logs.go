@@ -31,6 +31,7 @@ func onLogs(cmd *cli.Cmd) {
 			deployer.OptionBuildCacheDir(*buildCacheDir),
 			deployer.OptionSolcAllowedPaths(*solAllowedPaths),
 			deployer.OptionEnableCoverage(*coverage),
+			deployer.OptionCoverageStrategy(deployer.CoverageStrategy(*coverStrategy)),
 		)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to init deployer")
@@ -64,7 +65,14 @@ func onLogs(cmd *cli.Cmd) {
 		fmt.Println(string(cmdOut))
 
 		if *coverage {
-			logsOpts.CoverageAgent.ReportHTML(nil, *contractName)
+			// the report is opened in a browser, unless it goes into --cover-html directory
+			if len(*coverHTML) == 0 {
+				if err := logsOpts.CoverageAgent.ReportHTML(nil, *contractName); err != nil {
+					log.WithError(err).Warningln("failed to report coverage in HTML")
+				}
+			}
+
+			reportCoverage(logsOpts.CoverageAgent, *contractName)
 		}
 	}
 }
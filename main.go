@@ -29,26 +29,79 @@ func main() {
 		&callTimeout,
 		&gasPrice,
 		&gasLimit,
+		&gasMultiplier,
+		&gasBuffer,
 		&buildCacheDir,
 		&noCache,
 		&coverage,
+		&coverStrategy,
+		&coverLCOV,
+		&coverHTML,
+		&coverMin,
+		&coverThresholds,
+		&coverDiff,
+		&coverDiffBase,
+		&gasProfile,
+		&gasProfileTop,
 		&logLevel,
 	)
 
 	readEthereumKeyOptions(
 		&keystoreDir,
+		&keystoreRecursive,
 		&from,
 		&fromPassphrase,
 		&fromPrivKey,
+		&fromPrivKeyFile,
+		&fromPrivKeyFD,
 		&useLedger,
+		&clefEndpoint,
+		&signerURL,
+		&signerToken,
+		&signerTLSCA,
+		&signerTLSCert,
+		&signerTLSKey,
+		&mnemonic,
+		&mnemonicFile,
+		&hdPath,
+		&hdIndex,
+		&ledgerPath,
+		&ledgerAccounts,
+		&poolAccounts,
+		&poolSize,
 	)
 
+	signerOpts = signerOptions{
+		KeystoreDir:       keystoreDir,
+		KeystoreRecursive: keystoreRecursive,
+		From:              from,
+		FromPassphrase:    fromPassphrase,
+		FromPrivKey:       fromPrivKey,
+		FromPrivKeyFile:   fromPrivKeyFile,
+		FromPrivKeyFD:     fromPrivKeyFD,
+		UseLedger:         useLedger,
+		ClefEndpoint:      clefEndpoint,
+		SignerURL:         signerURL,
+		SignerToken:       signerToken,
+		SignerTLSCA:       signerTLSCA,
+		SignerTLSCert:     signerTLSCert,
+		SignerTLSKey:      signerTLSKey,
+		Mnemonic:          mnemonic,
+		MnemonicFile:      mnemonicFile,
+		HDPath:            hdPath,
+		HDIndex:           hdIndex,
+		LedgerPath:        ledgerPath,
+		LedgerAccounts:    ledgerAccounts,
+	}
+
 	app.Action = func() {
 		fmt.Println("You should use either deploy, tx or logs command. See --help for more info.")
 	}
 
 	app.Before = func() {
 		log.DefaultLogger.SetLevel(toLogLevel(*logLevel))
+		warnRawPrivateKeyArg(os.Args[1:])
+		enableGasProfile()
 	}
 
 	app.Command("build", "Builds given contract and cached build artefacts. Optional step.", onBuild)
@@ -56,6 +109,15 @@ func main() {
 	app.Command("tx", "Creates a transaction for particular contract method. Uses build cache.", onTx)
 	app.Command("call", "Calls method of a particular contract. Uses build cache.", onCall)
 	app.Command("logs", "Loads logs of a particular event from contract.", onLogs)
+	app.Command("estimate", "Estimates gas and cost of a deployment or transaction without sending it.", onEstimate)
+	app.Command("trace", "Traces a transaction and prints decoded call tree. Uses ABIs from build cache.", onTrace)
+	app.Command("fund", "Tops up balances of --pool or --pool-size accounts from the from account.", onFund)
+	app.Command("keys", "Manages encrypted keys in --keystore-dir without Geth.", onKeys)
+	app.Command("sign", "Signs a message or EIP-712 typed data with the from account.", onSign)
+	app.Command("verify-signature", "Checks that the message or EIP-712 typed data was signed by the address.", onVerifySignature)
+	app.Command("coverage", "Reports coverage from merged LCOV tracefiles, e.g. of changed lines.", onCoverage)
+	app.Command("cover-check", "Checks merged LCOV tracefiles against coverage thresholds.", onCoverCheck)
+	app.Command("console", "Starts an interactive console bound to the contract. Builds it once.", onConsole)
 
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	cli "github.com/jawher/mow.cli"
@@ -26,12 +28,23 @@ var (
 	txTimeout   *string
 	callTimeout *string
 
-	gasPrice      *int
-	gasLimit      *int
-	buildCacheDir *string
-	noCache       *bool
-	coverage      *bool
-	logLevel      *string
+	gasPrice        *int
+	gasLimit        *string
+	gasMultiplier   *float64
+	gasBuffer       *int
+	buildCacheDir   *string
+	noCache         *bool
+	coverage        *bool
+	coverStrategy   *string
+	coverLCOV       *string
+	coverHTML       *string
+	coverMin        *float64
+	coverThresholds *string
+	coverDiff       *string
+	coverDiffBase   *string
+	gasProfile      *bool
+	gasProfileTop   *int
+	logLevel        *string
 )
 
 func readGlobalOptions(
@@ -48,10 +61,21 @@ func readGlobalOptions(
 	callTimeout **string,
 
 	gasPrice **int,
-	gasLimit **int,
+	gasLimit **string,
+	gasMultiplier **float64,
+	gasBuffer **int,
 	buildCacheDir **string,
 	noCache **bool,
 	coverage **bool,
+	coverStrategy **string,
+	coverLCOV **string,
+	coverHTML **string,
+	coverMin **float64,
+	coverThresholds **string,
+	coverDiff **string,
+	coverDiffBase **string,
+	gasProfile **bool,
+	gasProfileTop **int,
 	logLevel **string,
 ) {
 	*solcPath = app.String(cli.StringOpt{
@@ -118,11 +142,25 @@ func readGlobalOptions(
 		Value:  -1, // estimate
 	})
 
-	*gasLimit = app.Int(cli.IntOpt{
+	*gasLimit = app.String(cli.StringOpt{
 		Name:   "L gas-limit",
-		Desc:   "Set the maximum gas for tx.",
+		Desc:   "Set the maximum gas for tx, or 'auto' to estimate gas for each tx and deployment.",
 		EnvVar: "DEPLOYER_TX_GAS_LIMIT",
-		Value:  5000000,
+		Value:  "5000000",
+	})
+
+	*gasMultiplier = app.Float64(cli.Float64Opt{
+		Name:   "gas-multiplier",
+		Desc:   "Multiply estimated gas by this factor when gas limit is 'auto'.",
+		EnvVar: "DEPLOYER_TX_GAS_MULTIPLIER",
+		Value:  1.2,
+	})
+
+	*gasBuffer = app.Int(cli.IntOpt{
+		Name:   "gas-buffer",
+		Desc:   "Add this amount of gas on top of the multiplied estimate when gas limit is 'auto'.",
+		EnvVar: "DEPLOYER_TX_GAS_BUFFER",
+		Value:  0,
 	})
 
 	*buildCacheDir = app.String(cli.StringOpt{
@@ -141,11 +179,74 @@ func readGlobalOptions(
 
 	*coverage = app.Bool(cli.BoolOpt{
 		Name:   "cover",
-		Desc:   "Enables code coverage orchestration",
+		Desc:   "Enables code coverage orchestration. Ternary branches are covered only with --cover-strategy trace.",
 		EnvVar: "DEPLOYER_ENABLE_COVERAGE",
 		Value:  false,
 	})
 
+	*coverStrategy = app.String(cli.StringOpt{
+		Name:   "cover-strategy",
+		Desc:   "Coverage collection strategy: 'instrument' compiles coverage markers into the contract, 'trace' uses source maps and debug tracing of the node, required to cover ternary branches.",
+		EnvVar: "DEPLOYER_COVERAGE_STRATEGY",
+		Value:  "instrument",
+	})
+
+	*coverLCOV = app.String(cli.StringOpt{
+		Name:   "cover-lcov",
+		Desc:   "Write coverage data in LCOV format into the specified file.",
+		EnvVar: "DEPLOYER_COVERAGE_LCOV",
+		Value:  "",
+	})
+
+	*coverHTML = app.String(cli.StringOpt{
+		Name:   "cover-html",
+		Desc:   "Write HTML coverage report into the specified directory: index.html with coverage per file, linking to a page per file. No browser is opened.",
+		EnvVar: "DEPLOYER_COVERAGE_HTML",
+		Value:  "",
+	})
+
+	*coverMin = app.Float64(cli.Float64Opt{
+		Name:   "cover-min",
+		Desc:   "Fail with non-zero exit code if total statement coverage in percent is below this value.",
+		EnvVar: "DEPLOYER_COVERAGE_MIN",
+		Value:  0,
+	})
+
+	*coverThresholds = app.String(cli.StringOpt{
+		Name:   "cover-thresholds",
+		Desc:   "Path to JSON config with minimal coverage per file and per contract, e.g. {\"files\": {\"Counter.sol\": 90}, \"contracts\": {\"Counter\": 80}}.",
+		EnvVar: "DEPLOYER_COVERAGE_THRESHOLDS",
+		Value:  "",
+	})
+
+	*coverDiff = app.String(cli.StringOpt{
+		Name:   "cover-diff",
+		Desc:   "Path to unified diff of .sol sources, or '-' for stdin. Changed lines not covered are reported and marked in the HTML report.",
+		EnvVar: "DEPLOYER_COVERAGE_DIFF",
+		Value:  "",
+	})
+
+	*coverDiffBase = app.String(cli.StringOpt{
+		Name:   "cover-diff-base",
+		Desc:   "Git ref to diff .sol sources of the working tree against, instead of --cover-diff.",
+		EnvVar: "DEPLOYER_COVERAGE_DIFF_BASE",
+		Value:  "",
+	})
+
+	*gasProfile = app.Bool(cli.BoolOpt{
+		Name:   "gas-profile",
+		Desc:   "Attributes gas used to statements and functions, prints hot spots and adds a gas heat map to the HTML report. Enables coverage with the trace strategy.",
+		EnvVar: "DEPLOYER_GAS_PROFILE",
+		Value:  false,
+	})
+
+	*gasProfileTop = app.Int(cli.IntOpt{
+		Name:   "gas-profile-top",
+		Desc:   "Number of top statements and functions by gas to print, 0 prints all.",
+		EnvVar: "DEPLOYER_GAS_PROFILE_TOP",
+		Value:  20,
+	})
+
 	*logLevel = app.String(cli.StringOpt{
 		Name:   "l log-level",
 		Desc:   "Available levels: error, warn, info, debug.",
@@ -169,6 +270,34 @@ func toLogLevel(s string) log.Level {
 	}
 }
 
+// gasLimitValue parses --gas-limit, zero value means the gas limit will be estimated.
+func gasLimitValue(s string) uint64 {
+	limit, err := parseGasLimit(s)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to parse gas limit, expected a number or 'auto'")
+	}
+
+	return limit
+}
+
+// parseGasLimit reads the gas limit, 'auto' or empty value is zero, so the gas limit is estimated.
+func parseGasLimit(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "auto" {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func gasBufferValue(v int) uint64 {
+	if v < 0 {
+		log.Fatalln("gas buffer must not be negative")
+	}
+
+	return uint64(v)
+}
+
 func duration(s string, defaults time.Duration) time.Duration {
 	dur, err := time.ParseDuration(s)
 	if err != nil {
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"strings"
@@ -11,29 +14,66 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	ethcmn "github.com/ethereum/go-ethereum/common"
-	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	cli "github.com/jawher/mow.cli"
 	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
 	"golang.org/x/term"
 
 	"github.com/InjectiveLabs/etherman/keystore"
 )
 
 var (
-	keystoreDir    *string
-	from           *string
-	fromPassphrase *string
-	fromPrivKey    *string
-	useLedger      *bool
+	keystoreDir       *string
+	keystoreRecursive *bool
+	from              *string
+	fromPassphrase    *string
+	fromPrivKey       *string
+	fromPrivKeyFile   *string
+	fromPrivKeyFD     *int
+	useLedger         *bool
+	clefEndpoint      *string
+	signerURL         *string
+	signerToken       *string
+	signerTLSCA       *string
+	signerTLSCert     *string
+	signerTLSKey      *string
+	mnemonic          *string
+	mnemonicFile      *string
+	hdPath            *string
+	hdIndex           *int
+	ledgerPath        *string
+	ledgerAccounts    *int
+	poolAccounts      *string
+	poolSize          *int
+
+	// signerOpts groups key options of the from account, built once in main
+	signerOpts signerOptions
 )
 
 func readEthereumKeyOptions(
 	keystoreDir **string,
+	keystoreRecursive **bool,
 	from **string,
 	fromPassphrase **string,
 	fromPrivKey **string,
+	fromPrivKeyFile **string,
+	fromPrivKeyFD **int,
 	useLedger **bool,
+	clefEndpoint **string,
+	signerURL **string,
+	signerToken **string,
+	signerTLSCA **string,
+	signerTLSCert **string,
+	signerTLSKey **string,
+	mnemonic **string,
+	mnemonicFile **string,
+	hdPath **string,
+	hdIndex **int,
+	ledgerPath **string,
+	ledgerAccounts **int,
+	poolAccounts **string,
+	poolSize **int,
 ) {
 	*keystoreDir = app.String(cli.StringOpt{
 		Name:   "keystore-dir",
@@ -41,6 +81,13 @@ func readEthereumKeyOptions(
 		EnvVar: "DEPLOYER_KEYSTORE_DIR",
 	})
 
+	*keystoreRecursive = app.Bool(cli.BoolOpt{
+		Name:   "keystore-recursive",
+		Desc:   "Also look for key files in subdirectories of the keystore dir.",
+		EnvVar: "DEPLOYER_KEYSTORE_RECURSIVE",
+		Value:  false,
+	})
+
 	*from = app.String(cli.StringOpt{
 		Name:   "F from",
 		Desc:   "Specify the from address. If specified, must exist in keystore, ledger or match the privkey.",
@@ -59,152 +106,634 @@ func readEthereumKeyOptions(
 		EnvVar: "DEPLOYER_FROM_PK",
 	})
 
+	*fromPrivKeyFile = app.String(cli.StringOpt{
+		Name:   "from-pk-file",
+		Desc:   "Read the private key from a file: hex, age file encrypted with a passphrase, or V3 key file.",
+		EnvVar: "DEPLOYER_FROM_PK_FILE",
+	})
+
+	*fromPrivKeyFD = app.Int(cli.IntOpt{
+		Name:   "from-pk-fd",
+		Desc:   "Read the private key from this file descriptor, in any format of --from-pk-file.",
+		EnvVar: "DEPLOYER_FROM_PK_FD",
+		Value:  -1,
+	})
+
 	*useLedger = app.Bool(cli.BoolOpt{
 		Name:   "ledger",
 		Desc:   "Use the Ethereum app on hardware ledger to sign transactions.",
 		EnvVar: "DEPLOYER_USE_LEDGER",
 		Value:  false,
 	})
+
+	*clefEndpoint = app.String(cli.StringOpt{
+		Name:   "clef",
+		Desc:   "Sign with Clef or another external signer at this HTTP URL or IPC socket path, keys stay in the signer.",
+		EnvVar: "DEPLOYER_CLEF",
+	})
+
+	*signerURL = app.String(cli.StringOpt{
+		Name:   "signer-url",
+		Desc:   "Sign with a Web3Signer-compatible remote signer at this base URL, keys stay in the signer.",
+		EnvVar: "DEPLOYER_SIGNER_URL",
+	})
+
+	*signerToken = app.String(cli.StringOpt{
+		Name:   "signer-token",
+		Desc:   "Bearer token for requests to --signer-url.",
+		EnvVar: "DEPLOYER_SIGNER_TOKEN",
+	})
+
+	*signerTLSCA = app.String(cli.StringOpt{
+		Name:   "signer-tls-ca",
+		Desc:   "PEM file with CA certificates to verify --signer-url with, instead of system ones.",
+		EnvVar: "DEPLOYER_SIGNER_TLS_CA",
+	})
+
+	*signerTLSCert = app.String(cli.StringOpt{
+		Name:   "signer-tls-cert",
+		Desc:   "PEM file with client certificate for mutual TLS with --signer-url.",
+		EnvVar: "DEPLOYER_SIGNER_TLS_CERT",
+	})
+
+	*signerTLSKey = app.String(cli.StringOpt{
+		Name:   "signer-tls-key",
+		Desc:   "PEM file with private key of --signer-tls-cert.",
+		EnvVar: "DEPLOYER_SIGNER_TLS_KEY",
+	})
+
+	*mnemonic = app.String(cli.StringOpt{
+		Name:   "mnemonic",
+		Desc:   "BIP-39 mnemonic to derive the key from, see --hd-path and --hd-index.",
+		EnvVar: "DEPLOYER_MNEMONIC",
+	})
+
+	*mnemonicFile = app.String(cli.StringOpt{
+		Name:   "mnemonic-file",
+		Desc:   "Path to a file with BIP-39 mnemonic, instead of --mnemonic.",
+		EnvVar: "DEPLOYER_MNEMONIC_FILE",
+	})
+
+	*hdPath = app.String(cli.StringOpt{
+		Name:   "hd-path",
+		Desc:   "BIP-44 base derivation path of mnemonic accounts, the index is appended to it.",
+		EnvVar: "DEPLOYER_HD_PATH",
+		Value:  keystore.DefaultHDPath,
+	})
+
+	*hdIndex = app.Int(cli.IntOpt{
+		Name:   "hd-index",
+		Desc:   "Index of the account derived from the mnemonic.",
+		EnvVar: "DEPLOYER_HD_INDEX",
+		Value:  0,
+	})
+
+	*ledgerPath = app.String(cli.StringOpt{
+		Name:   "ledger-path",
+		Desc:   "Base derivation path of Ledger accounts with the index appended, or 'live' for Ledger Live layout m/44'/60'/N'/0/0.",
+		EnvVar: "DEPLOYER_LEDGER_PATH",
+		Value:  keystore.DefaultHDPath,
+	})
+
+	*ledgerAccounts = app.Int(cli.IntOpt{
+		Name:   "ledger-accounts",
+		Desc:   "Number of Ledger accounts to derive when looking for the from address.",
+		EnvVar: "DEPLOYER_LEDGER_ACCOUNTS",
+		Value:  keystore.DefaultLedgerAccounts,
+	})
+
+	*poolAccounts = app.String(cli.StringOpt{
+		Name:   "pool",
+		Desc:   "Comma-separated keystore accounts to send from in turns, or 'all' for all but --from. Unlocked with the from passphrase.",
+		EnvVar: "DEPLOYER_POOL",
+	})
+
+	*poolSize = app.Int(cli.IntOpt{
+		Name:   "pool-size",
+		Desc:   "Number of mnemonic accounts after --hd-index to send from in turns.",
+		EnvVar: "DEPLOYER_POOL_SIZE",
+		Value:  0,
+	})
+}
+
+// signerOptions are key options of the from account, see readEthereumKeyOptions. Fields point
+// to option values, so the struct is built once before options are parsed.
+type signerOptions struct {
+	KeystoreDir       *string
+	KeystoreRecursive *bool
+	From              *string
+	FromPassphrase    *string
+	FromPrivKey       *string
+	FromPrivKeyFile   *string
+	FromPrivKeyFD     *int
+	UseLedger         *bool
+	ClefEndpoint      *string
+	SignerURL         *string
+	SignerToken       *string
+	SignerTLSCA       *string
+	SignerTLSCert     *string
+	SignerTLSKey      *string
+	Mnemonic          *string
+	MnemonicFile      *string
+	HDPath            *string
+	HDIndex           *int
+	LedgerPath        *string
+	LedgerAccounts    *int
 }
 
 var emptyEthAddress = ethcmn.Address{}
 
-func initEthereumAccountsManager(
-	chainID uint64,
-	keystoreDir *string,
-	from *string,
-	fromPassphrase *string,
-	fromPrivKey *string,
-	useLedger *bool,
-) (
+func initEthereumAccountsManager(chainID uint64, opts signerOptions) (
 	fromAddress ethcmn.Address,
 	signerFn bind.SignerFn,
 	err error,
 ) {
+	signer, err := initEthereumSigner(chainID, opts)
+	if err != nil {
+		return emptyEthAddress, nil, err
+	}
+
+	return signer.From, signer.SignerFn, nil
+}
+
+// ethSigner signs transactions and messages of the from account, with keys of the selected source.
+// SignerFn is nil if chain ID is zero, i.e. the signer is used for messages only.
+type ethSigner struct {
+	From            ethcmn.Address
+	SignerFn        bind.SignerFn
+	PersonalSignFn  keystore.PersonalSignFn
+	TypedDataSignFn keystore.TypedDataSignFn
+}
+
+// keySource is where the key of the from account comes from, see signerOptions.keySource.
+type keySource int
+
+const (
+	keySourceNone keySource = iota
+	keySourceLedger
+	keySourceClef
+	keySourceRemote
+	keySourcePrivateKey
+	keySourceMnemonic
+	keySourceKeystore
+)
+
+// keySource selects the source of the from key, in the order of precedence of the options:
+// Ledger, Clef, remote signer, private key, mnemonic, keystore.
+func (opts signerOptions) keySource() keySource {
 	switch {
-	case *useLedger:
-		if from == nil {
+	case *opts.UseLedger:
+		return keySourceLedger
+	case len(*opts.ClefEndpoint) > 0:
+		return keySourceClef
+	case len(*opts.SignerURL) > 0:
+		return keySourceRemote
+	case hasPrivateKey(opts.FromPrivKey, opts.FromPrivKeyFile, opts.FromPrivKeyFD):
+		return keySourcePrivateKey
+	case len(*opts.Mnemonic) > 0 || len(*opts.MnemonicFile) > 0:
+		return keySourceMnemonic
+	case len(*opts.KeystoreDir) > 0:
+		return keySourceKeystore
+	default:
+		return keySourceNone
+	}
+}
+
+func initEthereumSigner(chainID uint64, opts signerOptions) (*ethSigner, error) {
+	switch opts.keySource() {
+	case keySourceLedger:
+		if !ethcmn.IsHexAddress(*opts.From) {
 			err := errors.New("cannot use Ledger without from address specified")
-			return emptyEthAddress, nil, err
+			return nil, err
 		}
 
-		fromAddress = ethcmn.HexToAddress(*from)
-		if fromAddress == (ethcmn.Address{}) {
-			err = errors.Wrap(err, "failed to parse Ethereum from address")
-			return emptyEthAddress, nil, err
+		fromAddress := ethcmn.HexToAddress(*opts.From)
+
+		ledgerWallet, pathFn, err := openLedgerWallet(opts.LedgerPath)
+		if err != nil {
+			return nil, err
 		}
 
-		ledgerBackend, err := usbwallet.NewLedgerHub()
+		// the wallet stays open, so it's connected once for all signatures
+		ledgerSigner, err := keystore.NewLedgerSigner(
+			ledgerWallet,
+			fromAddress,
+			pathFn,
+			*opts.LedgerAccounts,
+			keystore.LedgerSignerTransport(keystore.LedgerHIDTransport(ledgerWallet.URL())),
+		)
 		if err != nil {
-			err = errors.Wrap(err, "failed to connect with Ethereum app on Ledger device")
-			return emptyEthAddress, nil, err
+			_ = ledgerWallet.Close()
+			return nil, err
 		}
 
-		signerFn = func(from ethcmn.Address, tx *ethtypes.Transaction) (*ethtypes.Transaction, error) {
-			acc := accounts.Account{
-				Address: from,
-			}
+		signer := &ethSigner{
+			From:            fromAddress,
+			PersonalSignFn:  ledgerSigner.PersonalSignFn(),
+			TypedDataSignFn: ledgerSigner.TypedDataSignFn(),
+		}
+
+		if chainID > 0 {
+			signer.SignerFn = ledgerSigner.SignerFn(chainID)
+		}
+
+		return signer, nil
+
+	case keySourceClef:
+		if !ethcmn.IsHexAddress(*opts.From) {
+			err := errors.New("cannot use external signer without from address specified")
+			return nil, err
+		}
+
+		fromAddress := ethcmn.HexToAddress(*opts.From)
+
+		externalSigner, err := keystore.NewExternalSigner(*opts.ClefEndpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		signer := &ethSigner{
+			From:            fromAddress,
+			PersonalSignFn:  externalSigner.PersonalSignFn(fromAddress),
+			TypedDataSignFn: externalSigner.TypedDataSignFn(fromAddress),
+		}
+
+		if chainID > 0 {
+			signer.SignerFn = externalSigner.SignerFn(chainID, fromAddress)
+		}
+
+		return signer, nil
+
+	case keySourceRemote:
+		if !ethcmn.IsHexAddress(*opts.From) {
+			err := errors.New("cannot use remote signer without from address specified")
+			return nil, err
+		}
+
+		fromAddress := ethcmn.HexToAddress(*opts.From)
+
+		remoteSigner, err := keystore.NewRemoteSigner(
+			*opts.SignerURL,
+			keystore.RemoteSignerBearerToken(*opts.SignerToken),
+			keystore.RemoteSignerTLS(*opts.SignerTLSCA, *opts.SignerTLSCert, *opts.SignerTLSKey),
+		)
+		if err != nil {
+			return nil, err
+		}
 
-			wallets := ledgerBackend.Wallets()
-			for _, w := range wallets {
-				if err := w.Open(""); err != nil {
-					err = errors.Wrap(err, "failed to connect to wallet on Ledger device")
-					return nil, err
-				}
-
-				if !w.Contains(acc) {
-					if err := w.Close(); err != nil {
-						err = errors.Wrap(err, "failed to disconnect the wallet on Ledger device")
-						return nil, err
-					}
-
-					continue
-				}
-
-				tx, err = w.SignTx(acc, tx, new(big.Int).SetUint64(chainID))
-				_ = w.Close()
-				return tx, err
+		var signerFn bind.SignerFn
+		if chainID > 0 {
+			signerFn, err = remoteSigner.SignerFn(context.Background(), chainID, fromAddress)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to init remote signer for %s", fromAddress)
+				return nil, err
 			}
+		}
 
-			return nil, errors.Errorf("account %s not found on Ledger", from.String())
+		personalSignFn, err := remoteSigner.PersonalSignFn(context.Background(), fromAddress)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to init remote signer for %s", fromAddress)
+			return nil, err
 		}
 
-		return fromAddress, signerFn, nil
+		typedDataSignFn, err := remoteSigner.TypedDataSignFn(context.Background(), fromAddress)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to init remote signer for %s", fromAddress)
+			return nil, err
+		}
 
-	case len(*fromPrivKey) > 0:
-		pkHex := strings.TrimPrefix(*fromPrivKey, "0x")
-		ethPk, err := ethcrypto.HexToECDSA(pkHex)
+		signer := &ethSigner{
+			From:            fromAddress,
+			SignerFn:        signerFn,
+			PersonalSignFn:  personalSignFn,
+			TypedDataSignFn: typedDataSignFn,
+		}
+
+		return signer, nil
+
+	case keySourcePrivateKey:
+		ethPk, err := loadPrivateKey(opts.FromPrivKey, opts.FromPrivKeyFile, opts.FromPrivKeyFD, opts.FromPassphrase)
 		if err != nil {
-			err = errors.Wrap(err, "failed to hex-decode Ethereum ECDSA Private Key")
-			return emptyEthAddress, nil, err
+			return nil, err
 		}
 
 		ethAddressFromPk := ethcrypto.PubkeyToAddress(ethPk.PublicKey)
 
-		if len(*from) > 0 {
-			addr := ethcmn.HexToAddress(*from)
+		if len(*opts.From) > 0 {
+			addr := ethcmn.HexToAddress(*opts.From)
 			if addr == (ethcmn.Address{}) {
-				err = errors.Wrap(err, "failed to parse Ethereum from address")
-				return emptyEthAddress, nil, err
+				err = errors.New("failed to parse Ethereum from address")
+				return nil, err
 			} else if addr != ethAddressFromPk {
-				err = errors.Wrap(err, "Ethereum from address does not match address from ECDSA Private Key")
-				return emptyEthAddress, nil, err
+				err = errors.New("Ethereum from address does not match address from ECDSA Private Key")
+				return nil, err
 			}
 		}
 
-		txOpts, err := bind.NewKeyedTransactorWithChainID(ethPk, new(big.Int).SetUint64(chainID))
+		return privateKeySigner(chainID, ethPk)
+
+	case keySourceMnemonic:
+		ethPk, err := deriveMnemonicKey(opts.Mnemonic, opts.MnemonicFile, opts.HDPath, opts.HDIndex)
 		if err != nil {
-			err = errors.New("failed to init NewKeyedTransactorWithChainID")
-			return emptyEthAddress, nil, err
+			return nil, err
+		}
+
+		ethAddressFromPk := ethcrypto.PubkeyToAddress(ethPk.PublicKey)
+
+		if len(*opts.From) > 0 {
+			addr := ethcmn.HexToAddress(*opts.From)
+			if addr == (ethcmn.Address{}) {
+				err = errors.New("failed to parse Ethereum from address")
+				return nil, err
+			} else if addr != ethAddressFromPk {
+				err = errors.Errorf("Ethereum from address does not match address derived at index %d", *opts.HDIndex)
+				return nil, err
+			}
 		}
 
-		return txOpts.From, txOpts.Signer, nil
+		return privateKeySigner(chainID, ethPk)
 
-	case len(*keystoreDir) > 0:
-		if from == nil {
+	case keySourceKeystore:
+		if opts.From == nil {
 			err := errors.New("cannot use Ethereum keystore without from address specified")
-			return emptyEthAddress, nil, err
+			return nil, err
 		}
 
-		fromAddress = ethcmn.HexToAddress(*from)
+		fromAddress := ethcmn.HexToAddress(*opts.From)
 		if fromAddress == (ethcmn.Address{}) {
-			err = errors.Wrap(err, "failed to parse Ethereum from address")
-			return emptyEthAddress, nil, err
+			err := errors.New("failed to parse Ethereum from address")
+			return nil, err
 		}
 
-		if info, err := os.Stat(*keystoreDir); err != nil || !info.IsDir() {
+		if info, err := os.Stat(*opts.KeystoreDir); err != nil || !info.IsDir() {
 			err = errors.New("failed to locate keystore dir")
-			return emptyEthAddress, nil, err
+			return nil, err
 		}
 
-		ks, err := keystore.New(*keystoreDir)
+		ks, err := keystore.NewWithOptions(
+			[]string{*opts.KeystoreDir},
+			keystore.OptionRecursive(*opts.KeystoreRecursive),
+		)
 		if err != nil {
 			err = errors.Wrap(err, "failed to load keystore")
-			return emptyEthAddress, nil, err
+			return nil, err
 		}
 
 		var pass string
-		if len(*fromPassphrase) > 0 {
-			pass = *fromPassphrase
+		if len(*opts.FromPassphrase) > 0 {
+			pass = *opts.FromPassphrase
 		} else {
 			pass, err = ethPassFromStdin()
 			if err != nil {
-				return emptyEthAddress, nil, err
+				return nil, err
 			}
 		}
 
-		signerFn, err := ks.SignerFn(chainID, fromAddress, pass)
+		var signerFn bind.SignerFn
+		if chainID > 0 {
+			signerFn, err = ks.SignerFn(chainID, fromAddress, pass)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to load key for %s", fromAddress)
+				return nil, err
+			}
+		}
+
+		// the key is cached after unlocking, so it's decrypted once
+		personalSignFn, err := ks.PersonalSignFn(fromAddress, pass)
 		if err != nil {
 			err = errors.Wrapf(err, "failed to load key for %s", fromAddress)
-			return emptyEthAddress, nil, err
+			return nil, err
 		}
 
-		return fromAddress, signerFn, nil
+		typedDataSignFn, err := ks.TypedDataSignFn(fromAddress, pass)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to load key for %s", fromAddress)
+			return nil, err
+		}
+
+		signer := &ethSigner{
+			From:            fromAddress,
+			SignerFn:        signerFn,
+			PersonalSignFn:  personalSignFn,
+			TypedDataSignFn: typedDataSignFn,
+		}
+
+		return signer, nil
 
 	default:
 		err := errors.New("insufficient ethereum key details provided")
-		return emptyEthAddress, nil, err
+		return nil, err
+	}
+}
+
+func privateKeySigner(chainID uint64, ethPk *ecdsa.PrivateKey) (*ethSigner, error) {
+	var signerFn bind.SignerFn
+	if chainID > 0 {
+		txOpts, err := bind.NewKeyedTransactorWithChainID(ethPk, new(big.Int).SetUint64(chainID))
+		if err != nil {
+			err = errors.New("failed to init NewKeyedTransactorWithChainID")
+			return nil, err
+		}
+
+		signerFn = txOpts.Signer
+	}
+
+	personalSignFn, err := keystore.PrivateKeyPersonalSignFn(ethPk)
+	if err != nil {
+		return nil, err
+	}
+
+	typedDataSignFn, err := keystore.PrivateKeyTypedDataSignFn(ethPk)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &ethSigner{
+		From:            ethcrypto.PubkeyToAddress(ethPk.PublicKey),
+		SignerFn:        signerFn,
+		PersonalSignFn:  personalSignFn,
+		TypedDataSignFn: typedDataSignFn,
+	}
+
+	return signer, nil
+}
+
+// openLedgerWallet connects to the first Ledger device, paths of its accounts follow --ledger-path.
+func openLedgerWallet(ledgerPath *string) (accounts.Wallet, keystore.LedgerPathFn, error) {
+	pathFn, err := keystore.LedgerPath(*ledgerPath)
+	if err != nil {
+		return nil, nil, err
 	}
+
+	ledgerBackend, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		err = errors.Wrap(err, "failed to connect with Ethereum app on Ledger device")
+		return nil, nil, err
+	}
+
+	ledgerWallet, err := keystore.OpenLedgerWallet(ledgerBackend.Wallets())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ledgerWallet, pathFn, nil
+}
+
+// resolveFromAddress returns the sender address without unlocking any keys,
+// for commands that don't sign anything (e.g. gas estimation). The key source is
+// selected as in initEthereumSigner, other sources resolve to --from address.
+func resolveFromAddress(opts signerOptions) (ethcmn.Address, error) {
+	switch opts.keySource() {
+	case keySourcePrivateKey:
+		ethPk, err := loadPrivateKey(opts.FromPrivKey, opts.FromPrivKeyFile, opts.FromPrivKeyFD, opts.FromPassphrase)
+		if err != nil {
+			return emptyEthAddress, err
+		}
+
+		return ethcrypto.PubkeyToAddress(ethPk.PublicKey), nil
+
+	case keySourceMnemonic:
+		ethPk, err := deriveMnemonicKey(opts.Mnemonic, opts.MnemonicFile, opts.HDPath, opts.HDIndex)
+		if err != nil {
+			return emptyEthAddress, err
+		}
+
+		return ethcrypto.PubkeyToAddress(ethPk.PublicKey), nil
+	}
+
+	if len(*opts.From) == 0 {
+		return emptyEthAddress, nil
+	} else if !ethcmn.IsHexAddress(*opts.From) {
+		err := errors.New("failed to parse Ethereum from address")
+		return emptyEthAddress, err
+	}
+
+	return ethcmn.HexToAddress(*opts.From), nil
+}
+
+func hasPrivateKey(fromPrivKey *string, fromPrivKeyFile *string, fromPrivKeyFD *int) bool {
+	return len(*fromPrivKey) > 0 || len(*fromPrivKeyFile) > 0 || *fromPrivKeyFD >= 0
+}
+
+// loadPrivateKey reads the key of --from-pk, --from-pk-file or --from-pk-fd. Encrypted keys
+// are decrypted with --from-passphrase, if empty then stdin is used.
+func loadPrivateKey(
+	fromPrivKey *string,
+	fromPrivKeyFile *string,
+	fromPrivKeyFD *int,
+	fromPassphrase *string,
+) (*ecdsa.PrivateKey, error) {
+	passphraseFn := func() (string, error) {
+		if len(*fromPassphrase) > 0 {
+			return *fromPassphrase, nil
+		}
+
+		return ethPassFromStdin()
+	}
+
+	switch {
+	case len(*fromPrivKey) > 0:
+		return keystore.ParsePrivateKey(*fromPrivKey)
+
+	case len(*fromPrivKeyFile) > 0:
+		f, err := os.Open(*fromPrivKeyFile)
+		if err != nil {
+			err = errors.Wrap(err, "failed to open private key file")
+			return nil, err
+		}
+		defer f.Close()
+
+		return keystore.ReadPrivateKey(f, passphraseFn)
+
+	default:
+		f := os.NewFile(uintptr(*fromPrivKeyFD), "from-pk-fd")
+		if f == nil {
+			err := errors.Errorf("invalid file descriptor: %d", *fromPrivKeyFD)
+			return nil, err
+		}
+		defer f.Close()
+
+		return keystore.ReadPrivateKey(f, passphraseFn)
+	}
+}
+
+// warnRawPrivateKeyArg warns if a raw private key is passed as command line argument,
+// where it's visible in shell history and the process list. Arguments after -- are not options.
+func warnRawPrivateKeyArg(args []string) (warned bool) {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+
+		if rawPrivateKeyArg(arg) {
+			log.Warningln("private key passed via command line ends up in shell history, use --from-pk-file or --from-pk-fd")
+			return true
+		}
+	}
+
+	return false
+}
+
+// rawPrivateKeyArg matches --from-pk and -P, with the key in the same or the next argument. There are
+// no global short bool options, so -P can't be grouped after others: mow.cli gives the rest of a group
+// to the first option taking a value, e.g. -lP is log level P.
+func rawPrivateKeyArg(arg string) bool {
+	if arg == "--from-pk" || strings.HasPrefix(arg, "--from-pk=") {
+		return true
+	}
+
+	return strings.HasPrefix(arg, "-P")
+}
+
+// readMnemonic returns the mnemonic from the option or reads it from the file.
+func readMnemonic(mnemonic *string, mnemonicFile *string) (string, error) {
+	if len(*mnemonic) > 0 {
+		return *mnemonic, nil
+	}
+
+	data, err := ioutil.ReadFile(*mnemonicFile)
+	if err != nil {
+		err = errors.Wrap(err, "failed to read mnemonic file")
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// deriveMnemonicKey derives the private key of the account at --hd-index under --hd-path.
+func deriveMnemonicKey(
+	mnemonic *string,
+	mnemonicFile *string,
+	hdPath *string,
+	hdIndex *int,
+) (*ecdsa.PrivateKey, error) {
+	phrase, err := readMnemonic(mnemonic, mnemonicFile)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := keystore.NewHDWallet(phrase, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if *hdIndex < 0 {
+		err = errors.New("HD index must not be negative")
+		return nil, err
+	}
+
+	path, err := keystore.ParseHDPath(*hdPath, uint32(*hdIndex))
+	if err != nil {
+		return nil, err
+	}
+
+	ethPk, err := wallet.Derive(path)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to derive key at %s", path.String())
+		return nil, err
+	}
+
+	return ethPk, nil
 }
 
 func ethPassFromStdin() (string, error) {
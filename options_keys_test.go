@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+
+	ethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/etherman/keystore"
+)
+
+const (
+	testMnemonic        = "test test test test test test test test test test test junk"
+	testMnemonicAddress = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+
+	// second account of the test mnemonic
+	testSignerPrivKey = "0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d"
+	testSignerAddress = "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+)
+
+// newTestSignerOptions returns options with no key source set.
+func newTestSignerOptions() signerOptions {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	flag := func(b bool) *bool { return &b }
+
+	return signerOptions{
+		KeystoreDir:       str(""),
+		KeystoreRecursive: flag(false),
+		From:              str(""),
+		FromPassphrase:    str(""),
+		FromPrivKey:       str(""),
+		FromPrivKeyFile:   str(""),
+		FromPrivKeyFD:     num(-1),
+		UseLedger:         flag(false),
+		ClefEndpoint:      str(""),
+		SignerURL:         str(""),
+		SignerToken:       str(""),
+		SignerTLSCA:       str(""),
+		SignerTLSCert:     str(""),
+		SignerTLSKey:      str(""),
+		Mnemonic:          str(""),
+		MnemonicFile:      str(""),
+		HDPath:            str(keystore.DefaultHDPath),
+		HDIndex:           num(0),
+		LedgerPath:        str(keystore.DefaultHDPath),
+		LedgerAccounts:    num(1),
+	}
+}
+
+func TestResolveFromAddress(t *testing.T) {
+	opts := newTestSignerOptions()
+
+	addr, err := resolveFromAddress(opts)
+	require.NoError(t, err)
+	assert.Equal(t, emptyEthAddress, addr)
+
+	*opts.From = testSignerAddress
+	addr, err = resolveFromAddress(opts)
+	require.NoError(t, err)
+	assert.Equal(t, ethcmn.HexToAddress(testSignerAddress), addr)
+
+	*opts.From = ""
+	*opts.Mnemonic = testMnemonic
+	addr, err = resolveFromAddress(opts)
+	require.NoError(t, err)
+	assert.Equal(t, ethcmn.HexToAddress(testMnemonicAddress), addr)
+
+	// the private key takes precedence over the mnemonic, the same way as for signing
+	*opts.FromPrivKey = testSignerPrivKey
+	assert.Equal(t, keySourcePrivateKey, opts.keySource())
+
+	addr, err = resolveFromAddress(opts)
+	require.NoError(t, err)
+	assert.Equal(t, ethcmn.HexToAddress(testSignerAddress), addr)
+
+	signer, err := initEthereumSigner(0, opts)
+	require.NoError(t, err)
+	assert.Equal(t, addr, signer.From)
+
+	// remote sources use the from address
+	*opts.From = testMnemonicAddress
+	*opts.SignerURL = "https://signer.local"
+	assert.Equal(t, keySourceRemote, opts.keySource())
+
+	addr, err = resolveFromAddress(opts)
+	require.NoError(t, err)
+	assert.Equal(t, ethcmn.HexToAddress(testMnemonicAddress), addr)
+}
+
+func TestRawPrivateKeyArg(t *testing.T) {
+	testCases := []struct {
+		arg      string
+		expected bool
+	}{
+		{"-P", true},
+		{"-P" + testSignerPrivKey, true},
+		{"--from-pk", true},
+		{"--from-pk=" + testSignerPrivKey, true},
+		{"--from-pk-file", false},
+		{"--from-pk-file=key.txt", false},
+		{"--from-pk-fd=3", false},
+		{"-F", false},
+		{"-lP", false},
+		{"-NPool", false},
+		{"Pool", false},
+		{"MyP", false},
+		{"--name=P", false},
+		{"-", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, rawPrivateKeyArg(tc.arg), tc.arg)
+	}
+}
+
+func TestWarnRawPrivateKeyArg(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"-P", testSignerPrivKey, "deploy"}, true},
+		{[]string{"-P" + testSignerPrivKey, "deploy"}, true},
+		{[]string{"--from-pk=" + testSignerPrivKey, "deploy"}, true},
+		{[]string{"-E", "http://localhost:8545", "--from-pk", testSignerPrivKey, "deploy"}, true},
+
+		// option values merely containing P
+		{[]string{"-N", "PoolToken", "-S", "contracts/P.sol", "deploy"}, false},
+		{[]string{"-NPoolToken", "--from-pk-file", "key.txt", "deploy"}, false},
+
+		// arguments after -- are not options
+		{[]string{"--from-pk-file", "key.txt", "tx", "--", "0x33832d3A5e359A0689088c832755461dDaD5d41B", "set", "-P"}, false},
+		{nil, false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, warnRawPrivateKeyArg(tc.args), "%v", tc.args)
+	}
+}
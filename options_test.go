@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGasLimit(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected uint64
+		fails    bool
+	}{
+		{"auto", 0, false},
+		{"", 0, false},
+		{" auto ", 0, false},
+		{"5000000", 5000000, false},
+		{"0", 0, false},
+		{"18446744073709551615", 18446744073709551615, false},
+		{"18446744073709551616", 0, true},
+		{"-1", 0, true},
+		{"1e6", 0, true},
+		{"AUTO", 0, true},
+	}
+
+	for _, tc := range testCases {
+		limit, err := parseGasLimit(tc.value)
+		if tc.fails {
+			assert.Error(t, err, tc.value)
+			continue
+		}
+
+		assert.NoError(t, err, tc.value)
+		assert.Equal(t, tc.expected, limit, tc.value)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	ethcmn "github.com/ethereum/go-ethereum/common"
+	cli "github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/etherman/deployer"
+	"github.com/InjectiveLabs/etherman/keystore"
+)
+
+func hasSignerPool() bool {
+	return len(*poolAccounts) > 0 || *poolSize > 0
+}
+
+// initSignerPool loads accounts of --pool from keystore, or derives --pool-size accounts from the mnemonic.
+// Without pool options, the pool contains the from account only.
+func initSignerPool(
+	client *deployer.Client,
+	chainID uint64,
+	fromAddress ethcmn.Address,
+	signerFn keystore.SignerFn,
+) (*keystore.SignerPool, error) {
+	pendingNonceFn := func(ctx context.Context, account ethcmn.Address) (uint64, error) {
+		nonceCtx, cancelFn := context.WithTimeout(ctx, duration(*rpcTimeout, defaultRPCTimeout))
+		defer cancelFn()
+
+		return client.PendingNonceAt(nonceCtx, account)
+	}
+
+	switch {
+	case len(*poolAccounts) > 0:
+		if len(*keystoreDir) == 0 {
+			err := errors.New("--pool requires --keystore-dir")
+			return nil, err
+		}
+
+		ks, err := keystore.NewWithOptions(
+			[]string{*keystoreDir},
+			keystore.OptionRecursive(*keystoreRecursive),
+		)
+		if err != nil {
+			err = errors.Wrap(err, "failed to load keystore")
+			return nil, err
+		}
+
+		var accounts []ethcmn.Address
+		if *poolAccounts == "all" {
+			for _, account := range ks.Accounts() {
+				if account != fromAddress {
+					accounts = append(accounts, account)
+				}
+			}
+		} else {
+			for _, address := range strings.Split(*poolAccounts, ",") {
+				address = strings.TrimSpace(address)
+				if !ethcmn.IsHexAddress(address) {
+					err := errors.Errorf("wrong pool address: %s", address)
+					return nil, err
+				}
+
+				accounts = append(accounts, ethcmn.HexToAddress(address))
+			}
+		}
+
+		if len(accounts) == 0 {
+			err := errors.New("no pool accounts found in keystore")
+			return nil, err
+		}
+
+		pass := *fromPassphrase
+		if len(pass) == 0 {
+			pass, err = ethPassFromStdin()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return keystore.NewKeystoreSignerPool(ks, chainID, accounts, pass, pendingNonceFn)
+
+	case *poolSize > 0:
+		if len(*mnemonic) == 0 && len(*mnemonicFile) == 0 {
+			err := errors.New("--pool-size requires --mnemonic or --mnemonic-file")
+			return nil, err
+		}
+
+		phrase, err := readMnemonic(mnemonic, mnemonicFile)
+		if err != nil {
+			return nil, err
+		}
+
+		wallet, err := keystore.NewHDWallet(phrase, "")
+		if err != nil {
+			return nil, err
+		}
+
+		// the from account is at --hd-index, so pool accounts follow it
+		return keystore.NewHDSignerPool(wallet, *hdPath, uint32(*hdIndex)+1, *poolSize, chainID, pendingNonceFn)
+
+	default:
+		pool := keystore.NewSignerPool(pendingNonceFn)
+		if err := pool.Add(fromAddress, signerFn); err != nil {
+			return nil, err
+		}
+
+		return pool, nil
+	}
+}
+
+func onFund(cmd *cli.Cmd) {
+	amountArg := cmd.StringOpt("amount", "1000000000000000000", "Balance in wei to top up each pool account to.")
+	minBalanceArg := cmd.StringOpt("min-balance", "", "Skip accounts with at least this balance in wei, defaults to amount.")
+	await := cmd.BoolOpt("await", true, "Await confirmation of the transfers.")
+
+	cmd.Spec = "[--amount] [--min-balance] [--await]"
+
+	cmd.Action = func() {
+		if !hasSignerPool() {
+			log.Fatalln("no pool accounts to fund, specify --pool or --pool-size")
+		}
+
+		amount, ok := new(big.Int).SetString(*amountArg, 10)
+		if !ok {
+			log.Fatalln("failed to parse amount flag")
+		}
+
+		minBalance := amount
+		if len(*minBalanceArg) > 0 {
+			if minBalance, ok = new(big.Int).SetString(*minBalanceArg, 10); !ok {
+				log.Fatalln("failed to parse min-balance flag")
+			}
+		}
+
+		d, err := deployer.New(
+			deployer.OptionRPCTimeout(duration(*rpcTimeout, defaultRPCTimeout)),
+			deployer.OptionTxTimeout(duration(*txTimeout, defaultTxTimeout)),
+
+			// only options applicable to transfers
+			deployer.OptionEVMRPCEndpoint(*evmEndpoint),
+			deployer.OptionGasPrice(big.NewInt(int64(*gasPrice))),
+			deployer.OptionGasLimit(gasLimitValue(*gasLimit)),
+			deployer.OptionGasEstimateAdjustment(*gasMultiplier, gasBufferValue(*gasBuffer)),
+		)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to init deployer")
+		}
+
+		client, err := d.Backend()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		chainCtx, cancelFn := context.WithTimeout(context.Background(), duration(*rpcTimeout, defaultRPCTimeout))
+		defer cancelFn()
+
+		chainID, err := client.ChainID(chainCtx)
+		if err != nil {
+			log.WithError(err).Fatalln("failed get valid chain ID")
+		}
+
+		fromAddress, signerFn, err := initEthereumAccountsManager(chainID.Uint64(), signerOpts)
+		if err != nil {
+			log.WithError(err).Fatalln("failed init SignerFn")
+		}
+
+		pool, err := initSignerPool(client, chainID.Uint64(), fromAddress, signerFn)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to init signer pool")
+		}
+
+		type transfer struct {
+			to    ethcmn.Address
+			value *big.Int
+		}
+
+		var transfers []transfer
+		for _, account := range pool.Accounts() {
+			balanceCtx, cancelFn := context.WithTimeout(context.Background(), duration(*rpcTimeout, defaultRPCTimeout))
+			balance, err := client.BalanceAt(balanceCtx, account, nil)
+			cancelFn()
+			if err != nil {
+				log.WithField("account", account.Hex()).WithError(err).Fatalln("failed to get balance")
+			}
+
+			if balance.Cmp(minBalance) >= 0 || balance.Cmp(amount) >= 0 {
+				log.WithField("account", account.Hex()).Debugln("balance is sufficient", balance.String())
+				continue
+			}
+
+			transfers = append(transfers, transfer{
+				to:    account,
+				value: new(big.Int).Sub(amount, balance),
+			})
+		}
+
+		if len(transfers) == 0 {
+			log.Infoln("all pool accounts are funded")
+			return
+		}
+
+		nonceCtx, cancelFn := context.WithTimeout(context.Background(), duration(*rpcTimeout, defaultRPCTimeout))
+		defer cancelFn()
+
+		nonce, err := client.PendingNonceAt(nonceCtx, fromAddress)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to get pending nonce")
+		}
+
+		// transfers are sent in a row with sequential nonces, so awaiting the last one awaits all
+		for i, t := range transfers {
+			txHash, err := d.Transfer(context.Background(), deployer.TransferOpts{
+				From:     fromAddress,
+				SignerFn: signerFn,
+				To:       t.to,
+				Value:    t.value,
+				Nonce:    new(big.Int).SetUint64(nonce + uint64(i)),
+				Await:    *await && i == len(transfers)-1,
+			})
+			if err != nil {
+				log.WithField("account", t.to.Hex()).Fatalln(err)
+			}
+
+			log.WithField("account", t.to.Hex()).Infoln("sent", t.value.String(), "wei")
+			fmt.Println(txHash.Hex())
+		}
+	}
+}
+
+// sendFromPool sends the transaction count times with a worker per pool account,
+// so transactions of an account don't wait for confirmations of others.
+func sendFromPool(
+	d deployer.Deployer,
+	pool *keystore.SignerPool,
+	txOpts deployer.ContractTxOpts,
+	methodName string,
+	methodInputMapper deployer.AbiMethodInputMapperFunc,
+	count int,
+) {
+	jobs := make(chan int, count)
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	var (
+		wg     sync.WaitGroup
+		failed int32
+	)
+
+	for i := 0; i < pool.Len(); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range jobs {
+				sender, err := pool.Next(context.Background())
+				if err != nil {
+					log.WithError(err).Errorln("failed to get pool sender")
+					atomic.AddInt32(&failed, 1)
+					continue
+				}
+
+				opts := txOpts
+				opts.From = sender.From
+				opts.SignerFn = sender.SignerFn
+				opts.Nonce = new(big.Int).SetUint64(sender.Nonce)
+
+				txHash, _, err := d.Tx(context.Background(), opts, methodName, methodInputMapper)
+				if err != nil {
+					// the nonce is handed out again, so later transactions of the account don't get stuck
+					pool.ResetNonce(sender.From, sender.Nonce)
+
+					log.WithField("from", sender.From.Hex()).WithError(err).Errorln("failed to send transaction")
+					atomic.AddInt32(&failed, 1)
+					continue
+				}
+
+				log.WithField("from", sender.From.Hex()).Debugln("sent tx", txHash.Hex())
+				fmt.Println(txHash.Hex())
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if failed > 0 {
+		log.Fatalf("%d of %d transactions failed", failed, count)
+	}
+}
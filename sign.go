@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	cli "github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/etherman/keystore"
+)
+
+func onSign(cmd *cli.Cmd) {
+	cmd.Command("message", "Signs the message with EIP-191 prefix, as personal_sign.", onSignMessage)
+	cmd.Command("typed-data", "Signs EIP-712 typed data from JSON file, as eth_signTypedData_v4.", onSignTypedData)
+}
+
+func onSignMessage(cmd *cli.Cmd) {
+	isHex := cmd.BoolOpt("hex", false, "Message is hex-encoded bytes, not text.")
+	message := cmd.StringArg("MESSAGE", "", "Message to sign.")
+
+	cmd.Spec = "[--hex] MESSAGE"
+
+	cmd.Action = func() {
+		data, err := messageBytes(*message, *isHex)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		signer := initMessageSigner()
+
+		sig, err := signer.PersonalSignFn(signer.From, data)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to sign message")
+		}
+
+		fmt.Println(hexutil.Encode(keystore.LegacySignatureV(sig)))
+	}
+}
+
+func onSignTypedData(cmd *cli.Cmd) {
+	typedDataFile := cmd.StringArg("FILE", "", "Path to JSON with types, primaryType, domain and message.")
+
+	cmd.Spec = "FILE"
+
+	cmd.Action = func() {
+		typedData, err := readTypedData(*typedDataFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to hash typed data")
+		}
+
+		log.Debugln("typed data hash", hexutil.Encode(hash))
+
+		signer := initMessageSigner()
+
+		sig, err := signer.TypedDataSignFn(signer.From, typedData)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to sign typed data")
+		}
+
+		fmt.Println(hexutil.Encode(keystore.LegacySignatureV(sig)))
+	}
+}
+
+func onVerifySignature(cmd *cli.Cmd) {
+	isHex := cmd.BoolOpt("hex", false, "Message is hex-encoded bytes, not text.")
+	isTypedData := cmd.BoolOpt("typed-data", false, "Message is a path to EIP-712 typed data JSON.")
+	address := cmd.StringArg("ADDRESS", "", "Address of the expected signer.")
+	signature := cmd.StringArg("SIGNATURE", "", "Signature in hex, V can be 0/1 or 27/28.")
+	message := cmd.StringArg("MESSAGE", "", "Message that was signed.")
+
+	cmd.Spec = "[--hex | --typed-data] ADDRESS SIGNATURE MESSAGE"
+
+	cmd.Action = func() {
+		if !ethcmn.IsHexAddress(*address) {
+			log.Fatalln("failed to parse signer address")
+		}
+
+		sig, err := hexutil.Decode(*signature)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to decode signature")
+		} else if len(sig) != ethcrypto.SignatureLength {
+			log.Fatalf("invalid signature length: %d", len(sig))
+		}
+
+		var hash []byte
+		if *isTypedData {
+			typedData, err := readTypedData(*message)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			hash, _, err = apitypes.TypedDataAndHash(typedData)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to hash typed data")
+			}
+		} else {
+			data, err := messageBytes(*message, *isHex)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			hash = accounts.TextHash(data)
+		}
+
+		pubKey, err := ethcrypto.SigToPub(hash, keystore.NormalizeSignatureV(sig))
+		if err != nil {
+			log.WithError(err).Fatalln("failed to recover signer")
+		}
+
+		signer := ethcrypto.PubkeyToAddress(*pubKey)
+		if signer != ethcmn.HexToAddress(*address) {
+			log.Fatalf("invalid signature: signed by %s", signer.Hex())
+		}
+
+		fmt.Printf("valid signature of %s\n", signer.Hex())
+	}
+}
+
+func initMessageSigner() *ethSigner {
+	// zero chain ID skips init of transaction signers
+	signer, err := initEthereumSigner(0, signerOpts)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to init signer")
+	}
+
+	return signer
+}
+
+func messageBytes(message string, isHex bool) ([]byte, error) {
+	if !isHex {
+		return []byte(message), nil
+	}
+
+	data, err := hexutil.Decode(message)
+	if err != nil {
+		err = errors.Wrap(err, "failed to decode hex message")
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func readTypedData(path string) (apitypes.TypedData, error) {
+	var typedData apitypes.TypedData
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = errors.Wrap(err, "failed to read typed data file")
+		return typedData, err
+	}
+
+	if err := json.Unmarshal(data, &typedData); err != nil {
+		err = errors.Wrap(err, "failed to parse typed data JSON")
+		return typedData, err
+	}
+
+	return typedData, nil
+}
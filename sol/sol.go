@@ -21,19 +21,34 @@ type Contract struct {
 	Name            string
 	SourcePath      string
 	AllPaths        []string
+	FileIndex       int
 	CompilerVersion string
 	Address         common.Address
 	Coverage        bool
 	Statements      [][]int
-
-	ABI []byte
-	Bin string
+	Branches        []Branch
+	Functions       []Function
+
+	// CoverageEventIDs maps names of all contracts of the coverage build onto IDs of their
+	// coverage events, inherited code emits events of the contract where it's defined.
+	CoverageEventIDs map[string]uint64
+
+	// TraceCoverage is set when coverage is collected by mapping execution traces
+	// onto source maps, rather than from markers compiled into the bytecode.
+	TraceCoverage bool
+
+	ABI           []byte
+	Bin           string
+	BinRuntime    string
+	SrcMap        string
+	SrcMapRuntime string
 }
 
 type Compiler interface {
 	SetAllowPaths(paths []string) Compiler
 	Compile(prefix, path string, optimize int) (map[string]*Contract, error)
 	CompileWithCoverage(prefix, path string) (map[string]*Contract, error)
+	CompileWithSourceMaps(prefix, path string) (map[string]*Contract, error)
 }
 
 func NewSolCompiler(solcPath string) (Compiler, error) {
@@ -71,8 +86,11 @@ func (s *solCompiler) SetAllowPaths(paths []string) Compiler {
 }
 
 type solcContract struct {
-	ABI json.RawMessage `json:"abi"`
-	Bin string          `json:"bin"`
+	ABI           json.RawMessage `json:"abi"`
+	Bin           string          `json:"bin"`
+	BinRuntime    string          `json:"bin-runtime,omitempty"`
+	SrcMap        string          `json:"srcmap,omitempty"`
+	SrcMapRuntime string          `json:"srcmap-runtime,omitempty"`
 }
 
 type solcSource struct {
@@ -87,11 +105,68 @@ type solcOutput struct {
 }
 
 func (s *solCompiler) Compile(prefix, path string, optimize int) (map[string]*Contract, error) {
+	result, err := s.compile(prefix, path, optimize, "bin,abi,ast")
+	if err != nil {
+		return nil, err
+	}
+
+	return result.toContracts(false)
+}
+
+// CompileWithSourceMaps compiles sources without the optimizer, so source maps of the bytecode
+// are as precise as possible. Statements are collected from the AST, but sources are left intact.
+func (s *solCompiler) CompileWithSourceMaps(prefix, path string) (map[string]*Contract, error) {
+	result, err := s.compile(prefix, path, 0, "bin,bin-runtime,srcmap,srcmap-runtime,abi,ast")
+	if err != nil {
+		return nil, err
+	}
+
+	contracts, err := result.toContracts(true)
+	if err != nil {
+		return nil, err
+	}
+
+	contractStatements := make([][]int, 0)
+	contractBranches := make([]Branch, 0)
+	contractFunctions := make([]Function, 0)
+	for _, filePath := range result.SourceList {
+		source, ok := result.Sources[filePath]
+		if !ok || len(source.AST) == 0 {
+			continue
+		}
+
+		// markers are never compiled, only used to collect the statements
+		_, coverage, err := addCoverageMarkers(source.AST)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to collect statements of %s source", filePath)
+			return nil, err
+		}
+
+		contractStatements = append(contractStatements, coverage.Statements...)
+		contractBranches = append(contractBranches, coverage.Branches...)
+		contractFunctions = append(contractFunctions, coverage.Functions...)
+	}
+
+	for _, contract := range contracts {
+		// file indexes in source maps refer to the source list
+		contract.AllPaths = result.SourceList
+		contract.FileIndex = fileIndex(result.SourceList, contract.SourcePath)
+		contract.Coverage = true
+		contract.TraceCoverage = true
+		contract.Statements = contractStatements
+		contract.Branches = contractBranches
+		contract.Functions = contractFunctions
+	}
+
+	return contracts, nil
+}
+
+func (s *solCompiler) compile(prefix, path string, optimize int, outputs string) (*solcOutput, error) {
 	args := []string{s.solcPath}
 	if len(s.allowPaths) > 0 {
 		args = append(args, "--allow-paths", strings.Join(s.allowPaths, ","))
 	}
-	args = append(args, "--combined-json", "bin,abi,ast", filepath.Join(prefix, path))
+	args = append(args, "--combined-json", outputs, filepath.Join(prefix, path))
 	if optimize > 0 {
 		args = append(args, "--optimize", fmt.Sprintf("--optimize-runs=%d", optimize))
 	}
@@ -124,6 +199,10 @@ func (s *solCompiler) Compile(prefix, path string, optimize int) (map[string]*Co
 		return nil, err
 	}
 
+	return &result, nil
+}
+
+func (result *solcOutput) toContracts(withSourceMaps bool) (map[string]*Contract, error) {
 	contractPathsByName := make(map[string]string, len(result.SourceList))
 	contractNamesOrdered := make([]string, len(result.SourceList))
 	contractFilePaths := make([]string, 0, len(result.SourceList))
@@ -174,6 +253,13 @@ func (s *solCompiler) Compile(prefix, path string, optimize int) (map[string]*Co
 			ABI: []byte(c.ABI),
 			Bin: c.Bin,
 		}
+		contracts[name].FileIndex = fileIndex(contractFilePaths, sourcePath)
+
+		if withSourceMaps {
+			contracts[name].BinRuntime = c.BinRuntime
+			contracts[name].SrcMap = c.SrcMap
+			contracts[name].SrcMapRuntime = c.SrcMapRuntime
+		}
 	}
 
 	return contracts, nil
@@ -215,51 +301,35 @@ func (s *solCompiler) CompileWithCoverage(prefix, path string) (map[string]*Cont
 		return nil, err
 	}
 
-	contractPathsByName := make(map[string]string, len(result.SourceList))
-	contractNamesOrdered := make([]string, len(result.SourceList))
-	contractFilePaths := make([]string, 0, len(result.SourceList))
-
-	for id := range result.Contracts {
-		name, sourcePath, err := idToNameAndSourcePath(id)
-		if err != nil {
-			return nil, err
-		}
-
-		contractPathsByName[name] = sourcePath
-	}
-
-	for name, sourcePath := range contractPathsByName {
-		for idx, src := range result.SourceList {
-			if src == sourcePath {
-				contractNamesOrdered[idx] = name
-				break
-			}
-		}
-	}
-
-	seenPaths := make(map[string]struct{}, len(contractPathsByName))
+	// file indexes in src references of the AST refer to the source list
+	contractFilePaths := result.SourceList
 
-	contractStatements := make([][]int, 0, len(contractFilePaths))
-	for fileIdx, contractName := range contractNamesOrdered {
-		filePath := contractPathsByName[contractName]
-
-		if _, ok := seenPaths[filePath]; ok {
-			err := errors.Errorf("multiple contracts in the same file is a big no-no. Please refactor %s", filePath)
-			return nil, err
-		} else {
-			seenPaths[filePath] = struct{}{}
+	contractStatements := make([][]int, 0)
+	contractBranches := make([]Branch, 0)
+	contractFunctions := make([]Function, 0)
+	contractEventIDs := make(map[string]uint64)
+	for _, filePath := range contractFilePaths {
+		source, ok := result.Sources[filePath]
+		if !ok || len(source.AST) == 0 {
+			continue
 		}
 
-		source := result.Sources[filePath]
-
-		modifiedAST, statements, err := addCoverageMarkers(fileIdx, contractName, source.AST)
+		modifiedAST, coverage, err := addCoverageMarkers(source.AST)
 		if err != nil {
 			err = errors.Wrapf(err, "failed to orchestrate %s source with coverage markers", filePath)
 			return nil, err
 		}
 
-		contractFilePaths = append(contractFilePaths, filePath)
-		contractStatements = append(contractStatements, statements...)
+		if n := countConditionals(coverage.Branches); n > 0 {
+			log.Warningf("%s: %d ternary branches are not instrumented and stay unhit, use --cover-strategy trace to cover them", filePath, n)
+		}
+
+		contractStatements = append(contractStatements, coverage.Statements...)
+		contractBranches = append(contractBranches, coverage.Branches...)
+		contractFunctions = append(contractFunctions, coverage.Functions...)
+		for name, eventDefinitionID := range coverage.EventIDs {
+			contractEventIDs[name] = eventDefinitionID
+		}
 
 		escapedPath := strings.Replace(filePath, ".", "\\.", -1)
 		out, err = sjson.SetBytes(out, fmt.Sprintf("sources.%s.AST", escapedPath), modifiedAST)
@@ -330,9 +400,14 @@ func (s *solCompiler) CompileWithCoverage(prefix, path string) (map[string]*Cont
 			Name:            name,
 			SourcePath:      sourcePath,
 			AllPaths:        contractFilePaths,
+			FileIndex:       fileIndex(contractFilePaths, sourcePath),
 			CompilerVersion: finalResult.Version,
 			Coverage:        true,
 			Statements:      contractStatements,
+			Branches:        contractBranches,
+			Functions:       contractFunctions,
+
+			CoverageEventIDs: contractEventIDs,
 
 			ABI: []byte(c.ABI),
 			Bin: c.Bin,
@@ -342,6 +417,17 @@ func (s *solCompiler) CompileWithCoverage(prefix, path string) (map[string]*Cont
 	return contracts, nil
 }
 
+// fileIndex finds the source path in the list, returns -1 if it's missing.
+func fileIndex(paths []string, path string) int {
+	for idx, p := range paths {
+		if p == path {
+			return idx
+		}
+	}
+
+	return -1
+}
+
 func idToNameAndSourcePath(id string) (name, sourcePath string, err error) {
 	idParts := strings.Split(id, ":")
 	if len(idParts) == 1 {
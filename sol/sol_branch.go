@@ -0,0 +1,242 @@
+package sol
+
+import (
+	"encoding/json"
+
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+)
+
+type BranchKind string
+
+const (
+	BranchKindIf          BranchKind = "if"
+	BranchKindConditional BranchKind = "conditional"
+	BranchKindRequire     BranchKind = "require"
+	BranchKindAssert      BranchKind = "assert"
+)
+
+// Branch is a source location with two outcomes. Arm 0 is the true arm of if statements
+// and conditionals, or the pass of require and assert. Arm 1 is the false arm or the failure.
+type Branch struct {
+	Kind   BranchKind `json:"kind"`
+	Start  int        `json:"start"`
+	Length int        `json:"length"`
+	File   int        `json:"file"`
+
+	// Jump is {start, length} of the node that compiles into the deciding JUMPI,
+	// used to resolve branch outcomes from execution traces.
+	Jump [2]int `json:"jump"`
+}
+
+const branchArmShift = 32
+
+// EncodeBranchMarkerFile packs the arm into the file argument of a coverage marker,
+// so branch markers share the coverage event with statement markers.
+func EncodeBranchMarkerFile(file, arm int) uint64 {
+	return uint64(file) | uint64(arm+1)<<branchArmShift
+}
+
+// DecodeMarkerFile splits the file argument of a coverage marker, arm is -1 for statement
+// and function markers, function is set for function entry markers.
+func DecodeMarkerFile(value uint64) (file, arm int, function bool) {
+	file = int(value & (1<<branchArmShift - 1))
+	arm = int((value>>branchArmShift)&0xffff) - 1
+	function = value&functionMarkerFlag != 0
+
+	return file, arm, function
+}
+
+var astConditionals, _ = gojq.Parse(`..| select(.nodeType? == "Conditional")`)
+
+// getStatementBranch detects if statements and require/assert calls, which are branches on their own.
+func getStatementBranch(statement map[string]interface{}) (branch Branch, ok bool) {
+	src, _ := statement["src"].(string)
+	start, length, file, err := srcToLocation(src)
+	if err != nil || start < 0 {
+		return branch, false
+	}
+
+	branch = Branch{
+		Start:  start,
+		Length: length,
+		File:   file,
+		Jump:   [2]int{start, length},
+	}
+
+	switch statement["nodeType"] {
+	case "IfStatement":
+		branch.Kind = BranchKindIf
+		return branch, true
+	case "ExpressionStatement":
+		expression, _ := statement["expression"].(map[string]interface{})
+		if expression == nil || expression["nodeType"] != "FunctionCall" {
+			return branch, false
+		}
+
+		callee, _ := expression["expression"].(map[string]interface{})
+		if callee == nil {
+			return branch, false
+		}
+
+		switch callee["name"] {
+		case "require":
+			branch.Kind = BranchKindRequire
+		case "assert":
+			branch.Kind = BranchKindAssert
+		default:
+			return branch, false
+		}
+
+		// the condition is checked within the call expression, without the trailing semicolon
+		callSrc, _ := expression["src"].(string)
+		if callStart, callLength, _, err := srcToLocation(callSrc); err == nil {
+			branch.Jump = [2]int{callStart, callLength}
+		}
+
+		return branch, true
+	}
+
+	return branch, false
+}
+
+// orchestrateIfStatement puts branch markers at the beginning of both arms of the if statement,
+// a missing false arm gets a block with the marker only. Arms of chained else-ifs are orchestrated too.
+func orchestrateIfStatement(
+	statement map[string]interface{},
+	eventDefinitionID uint64,
+) (out map[string]interface{}, branches []Branch, err error) {
+	branch, ok := getStatementBranch(statement)
+	if !ok || branch.Kind != BranchKindIf {
+		return statement, nil, nil
+	}
+
+	branches = append(branches, branch)
+
+	if falseBody, ok := statement["falseBody"].(map[string]interface{}); ok && falseBody["nodeType"] == "IfStatement" {
+		orchestrated, nestedBranches, err := orchestrateIfStatement(falseBody, eventDefinitionID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		statement["falseBody"] = orchestrated
+		branches = append(branches, nestedBranches...)
+	}
+
+	for arm, key := range []string{"trueBody", "falseBody"} {
+		markerAST := newCoverageMarker(
+			eventDefinitionID,
+			uint64(branch.Start),
+			uint64(branch.Length),
+			EncodeBranchMarkerFile(branch.File, arm),
+		)
+
+		var marker interface{}
+		if err := json.Unmarshal(markerAST, &marker); err != nil {
+			err = errors.Wrap(err, "failed to unmarshal branch marker")
+			return nil, nil, err
+		}
+
+		body, _ := statement[key].(map[string]interface{})
+		if body != nil && body["nodeType"] == "Block" {
+			bodyStatements, _ := body["statements"].([]interface{})
+			body["statements"] = append([]interface{}{marker}, bodyStatements...)
+			continue
+		}
+
+		block := map[string]interface{}{
+			"id":         randN(),
+			"nodeType":   "Block",
+			"src":        "-1:-1:-1",
+			"statements": []interface{}{marker},
+		}
+		if body != nil {
+			// a sole reverting statement reports the arm with the coverage error, as the marker is reverted too
+			body, _, err = orchestrateRevertStatement(
+				body,
+				eventDefinitionID,
+				uint64(branch.Start),
+				uint64(branch.Length),
+				EncodeBranchMarkerFile(branch.File, arm),
+			)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			block["src"] = body["src"]
+			block["statements"] = []interface{}{marker, body}
+		}
+
+		statement[key] = block
+	}
+
+	return statement, branches, nil
+}
+
+// newBranchPassMarker is placed right after require and assert statements, it's emitted only
+// when the condition holds.
+func newBranchPassMarker(branch Branch, eventDefinitionID uint64) json.RawMessage {
+	return newCoverageMarker(
+		eventDefinitionID,
+		uint64(branch.Start),
+		uint64(branch.Length),
+		EncodeBranchMarkerFile(branch.File, 0),
+	)
+}
+
+// collectConditionalBranches finds ternary expressions, those cannot have markers inside,
+// so they're resolved from execution traces only and stay unhit with the instrument strategy.
+func collectConditionalBranches(ast json.RawMessage) (branches []Branch, err error) {
+	var in interface{}
+	if err = json.Unmarshal(ast, &in); err != nil {
+		return nil, err
+	}
+
+	iter := astConditionals.Run(in)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			err = errors.Wrap(err, "failed to parse JSON")
+			return nil, err
+		}
+
+		conditional, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		src, _ := conditional["src"].(string)
+		start, length, file, err := srcToLocation(src)
+		if err != nil {
+			err = errors.Wrap(err, "failed to parse conditional src reference")
+			return nil, err
+		} else if start < 0 {
+			continue
+		}
+
+		branches = append(branches, Branch{
+			Kind:   BranchKindConditional,
+			Start:  start,
+			Length: length,
+			File:   file,
+			Jump:   [2]int{start, length},
+		})
+	}
+
+	return branches, nil
+}
+
+// countConditionals returns the number of ternary branches, which are not instrumented with markers.
+func countConditionals(branches []Branch) (n int) {
+	for _, branch := range branches {
+		if branch.Kind == BranchKindConditional {
+			n++
+		}
+	}
+
+	return n
+}
@@ -10,7 +10,6 @@ import (
 	"strings"
 	"text/template"
 
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/itchyny/gojq"
 	"github.com/pkg/errors"
 	"github.com/tidwall/sjson"
@@ -23,28 +22,68 @@ var (
 	astBlocksKeys, _           = gojq.Parse(`..| select(.nodeType? == "Block")`)
 	astBlocksPaths, _          = gojq.Parse(`path(..| select(.nodeType? == "Block"))`)
 	astContractNodePaths, _    = gojq.Parse(`path(.nodes[] | select(.nodeType == "ContractDefinition" and .contractKind != "interface"))`)
+	astContractNodeNames, _    = gojq.Parse(`.nodes[] | select(.nodeType == "ContractDefinition" and .contractKind != "interface") | .name`)
 	astInnerBlocks, _          = gojq.Parse(`. | select(.nodeType == "Block")`)
 )
 
-func addCoverageMarkers(fileIdx int, contractName string, ast json.RawMessage) (out json.RawMessage, statements [][]int, err error) {
-	eventDefinitionID := randN()
+// sourceCoverage holds coverage items collected from a single source unit.
+type sourceCoverage struct {
+	Statements [][]int
+	Branches   []Branch
+	Functions  []Function
 
-	contractPaths, err := contractDefinitionPaths(ast)
+	// EventIDs maps names of contracts defined in the source onto IDs of their coverage events
+	EventIDs map[string]uint64
+}
+
+// addCoverageMarkers orchestrates all contracts of the source unit, every ContractDefinition gets its own
+// coverage event and ID constant, so markers in its functions emit the event of the enclosing contract.
+func addCoverageMarkers(ast json.RawMessage) (out json.RawMessage, coverage *sourceCoverage, err error) {
+	contractPaths, contractNames, err := contractDefinitions(ast)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	for _, path := range contractPaths {
-		coverageEventIDAST := newCoverageEventID(contractName, eventDefinitionID+uint64(fileIdx))
+	// functions are collected before markers are added, bodies get entry markers once orchestrated
+	functionBodyPaths, functions, err := getFunctions(ast)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ternaries can't have markers inside, so they're collected from the original AST
+	conditionals, err := collectConditionalBranches(ast)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coverage = &sourceCoverage{
+		Functions: functions,
+		EventIDs:  make(map[string]uint64, len(contractPaths)),
+	}
+
+	eventIDs := make(map[string]uint64, len(contractPaths))
+	for idx, path := range contractPaths {
+		eventDefinitionID := randN()
+		eventIDs[path] = eventDefinitionID
+		coverage.EventIDs[contractNames[idx]] = eventDefinitionID
+
+		coverageEventIDAST := newCoverageEventID(contractNames[idx], eventDefinitionID)
 
 		// append ___coverage event definition onto AST node of a ContractDefinition
-		eventDefinitionAST := newEventDefinition(eventDefinitionID + uint64(fileIdx))
+		eventDefinitionAST := newEventDefinition(eventDefinitionID)
 		ast, err = sjson.SetBytes(ast, path+".nodes.-1", eventDefinitionAST)
 		if err != nil {
 			err = errors.Wrap(err, "sjson failed to parse value")
 			return nil, nil, err
 		}
 
+		// append ___coverage_revert error definition, it wraps reverts of orchestrated statements
+		ast, err = sjson.SetBytes(ast, path+".nodes.-1", newErrorDefinition(eventDefinitionID))
+		if err != nil {
+			err = errors.Wrap(err, "sjson failed to parse value")
+			return nil, nil, err
+		}
+
 		// append ___coverage_id constant onto AST node of every contract definition
 		ast, err = sjson.SetBytes(ast, path+".nodes.-1", coverageEventIDAST)
 		if err != nil {
@@ -53,9 +92,21 @@ func addCoverageMarkers(fileIdx int, contractName string, ast json.RawMessage) (
 		}
 	}
 
+	// enclosingEventID finds the coverage event of the contract where the AST path belongs to,
+	// free functions outside of contracts can't emit events and are left intact.
+	enclosingEventID := func(path string) (uint64, bool) {
+		for contractPath, eventDefinitionID := range eventIDs {
+			if strings.HasPrefix(path, contractPath+".") {
+				return eventDefinitionID, true
+			}
+		}
+
+		return 0, false
+	}
+
 	stateMutabilities, err := getStateMutabilities(ast)
 	if err != nil {
-		return nil, statements, err
+		return nil, nil, err
 	}
 
 	for path, value := range stateMutabilities {
@@ -65,7 +116,7 @@ func addCoverageMarkers(fileIdx int, contractName string, ast json.RawMessage) (
 
 		ast, err = sjson.SetBytes(ast, path, "nonpayable")
 		if err != nil {
-			return nil, statements, err
+			return nil, nil, err
 		}
 	}
 
@@ -74,34 +125,64 @@ func addCoverageMarkers(fileIdx int, contractName string, ast json.RawMessage) (
 		return nil, nil, err
 	}
 
-	statements = make([][]int, 0, len(blocksMap))
+	coverage.Statements = make([][]int, 0, len(blocksMap))
 
 	for _, path := range pathsSortedByDepth {
-		var statementsSrc [][]int
+		eventDefinitionID, ok := enclosingEventID(path)
+		if !ok {
+			continue
+		}
 
 		block := blocksMap[path]
-		block, statementsSrc, err = orchestrateBlock(block, eventDefinitionID, fileIdx)
+		block, statements, branches, err := orchestrateBlock(block, eventDefinitionID)
 		if err != nil {
-			return nil, statements, err
+			return nil, nil, err
 		}
 
-		statements = append(statements, statementsSrc...)
+		coverage.Statements = append(coverage.Statements, statements...)
+		coverage.Branches = append(coverage.Branches, branches...)
+
+		if fn, ok := functionBodyPaths[path]; ok {
+			block, err = prependFunctionMarker(block, fn, eventDefinitionID)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
 
 		ast, err = sjson.SetBytes(ast, path, block)
 		if err != nil {
-			return nil, statements, err
+			return nil, nil, err
+		}
+	}
+
+	coverage.Branches = uniqueBranches(append(coverage.Branches, conditionals...))
+
+	return ast, coverage, nil
+}
+
+// uniqueBranches drops duplicates, since nested blocks are orchestrated more than once.
+func uniqueBranches(branches []Branch) []Branch {
+	seen := make(map[Branch]struct{}, len(branches))
+	unique := make([]Branch, 0, len(branches))
+
+	for _, branch := range branches {
+		if _, ok := seen[branch]; ok {
+			continue
 		}
+
+		seen[branch] = struct{}{}
+		unique = append(unique, branch)
 	}
 
-	return ast, statements, nil
+	return unique
 }
 
 type Values []interface{}
 
-func contractDefinitionPaths(ast json.RawMessage) (paths []string, err error) {
+func contractDefinitions(ast json.RawMessage) (paths, names []string, err error) {
 	var in interface{}
 	if err = json.Unmarshal(ast, &in); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	iter := astContractNodePaths.Run(in)
@@ -113,19 +194,43 @@ func contractDefinitionPaths(ast json.RawMessage) (paths []string, err error) {
 
 		if err, ok := v.(error); ok {
 			err = errors.Wrap(err, "failed to parse JSON")
-			return nil, err
+			return nil, nil, err
 		}
 
 		paths = append(paths, joinPath(v))
 	}
 
-	return paths, nil
+	iter = astContractNodeNames.Run(in)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			err = errors.Wrap(err, "failed to parse JSON")
+			return nil, nil, err
+		}
+
+		name, _ := v.(string)
+		names = append(names, name)
+	}
+
+	if len(paths) != len(names) {
+		err = errors.New("contract definitions mismatch in the AST")
+		return nil, nil, err
+	}
+
+	return paths, names, nil
 }
 
-func orchestrateBlock(blockAST json.RawMessage, eventDefinitionID uint64, fileIdx int) (out json.RawMessage, statements [][]int, err error) {
+func orchestrateBlock(
+	blockAST json.RawMessage,
+	eventDefinitionID uint64,
+) (out json.RawMessage, statements [][]int, branches []Branch, err error) {
 	var block map[string]interface{}
 	if err = json.Unmarshal(blockAST, &block); err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	list, ok := block["statements"].([]interface{})
@@ -142,7 +247,7 @@ func orchestrateBlock(blockAST json.RawMessage, eventDefinitionID uint64, fileId
 		// re-add statements with correct orchestration
 		start, end, file, err := getStatementSrcLocation(statementAST)
 		if err != nil {
-			return nil, statements, err
+			return nil, statements, branches, err
 		}
 
 		statements = append(statements, []int{start, end, file})
@@ -151,19 +256,21 @@ func orchestrateBlock(blockAST json.RawMessage, eventDefinitionID uint64, fileId
 		{
 			pathsSortedByDepth, blocksMap, err := getBlocks(statementAST)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 
 			for _, blockPath := range pathsSortedByDepth {
 				var statementsSrc [][]int
+				var branchesSrc []Branch
 
 				innerBlock := blocksMap[blockPath]
-				innerBlock, statementsSrc, err = orchestrateBlock(innerBlock, eventDefinitionID, fileIdx)
+				innerBlock, statementsSrc, branchesSrc, err = orchestrateBlock(innerBlock, eventDefinitionID)
 				if err != nil {
-					return nil, statements, err
+					return nil, statements, branches, err
 				}
 
 				statements = append(statements, statementsSrc...)
+				branches = append(branches, branchesSrc...)
 
 				if blockPath == "" {
 					// statement is a block itself
@@ -175,40 +282,74 @@ func orchestrateBlock(blockAST json.RawMessage, eventDefinitionID uint64, fileId
 				}
 
 				if err != nil {
-					return nil, statements, err
+					return nil, statements, branches, err
 				}
 			}
 		}
 
-		// now proceed with statement
-		newStatementAST, isRequire, err := orchestrateRequireStatement(statementAST)
-		if err != nil {
-			return nil, statements, err
+		// branch markers go into both arms of if statements
+		var statementNode map[string]interface{}
+		if err := json.Unmarshal(statementAST, &statementNode); err != nil {
+			return nil, statements, branches, err
 		}
 
-		if !isRequire {
-			marker := newCoverageMarker(eventDefinitionID+uint64(fileIdx), uint64(start), uint64(end), uint64(file))
-			blockAST, err = sjson.SetBytes(blockAST, fmt.Sprintf("statements.%d", statementIdx), marker)
+		branch, isBranch := getStatementBranch(statementNode)
+		if isBranch && branch.Kind == BranchKindIf {
+			orchestrated, ifBranches, err := orchestrateIfStatement(statementNode, eventDefinitionID)
 			if err != nil {
-				return nil, statements, err
+				return nil, statements, branches, err
 			}
-			statementIdx++
 
-			blockAST, err = sjson.SetBytes(blockAST, fmt.Sprintf("statements.%d", statementIdx), newStatementAST)
+			branches = append(branches, ifBranches...)
+
+			v, _ := json.Marshal(orchestrated)
+			statementAST = json.RawMessage(v)
+		} else if isBranch {
+			branches = append(branches, branch)
+		}
+
+		// now proceed with statement, reverting statements carry their location in the coverage error
+		markerFile := uint64(file)
+		if isBranch && branch.Kind == BranchKindRequire {
+			markerFile = EncodeBranchMarkerFile(file, 1)
+		}
+
+		orchestrated, isReverting, err := orchestrateRevertStatement(statementNode, eventDefinitionID, uint64(start), uint64(end), markerFile)
+		if err != nil {
+			return nil, statements, branches, err
+		} else if isReverting {
+			v, _ := json.Marshal(orchestrated)
+			statementAST = json.RawMessage(v)
+		}
+
+		// require and assert are counted by their pass markers, reverts by the coverage error
+		if !isReverting && !(isBranch && branch.Kind == BranchKindAssert) {
+			marker := newCoverageMarker(eventDefinitionID, uint64(start), uint64(end), uint64(file))
+			blockAST, err = sjson.SetBytes(blockAST, fmt.Sprintf("statements.%d", statementIdx), marker)
 			if err != nil {
-				return nil, statements, err
+				return nil, statements, branches, err
 			}
 			statementIdx++
-		} else { // is require and we have new orchestrated statement
-			blockAST, err = sjson.SetBytes(blockAST, fmt.Sprintf("statements.%d", statementIdx), newStatementAST)
+		}
+
+		blockAST, err = sjson.SetBytes(blockAST, fmt.Sprintf("statements.%d", statementIdx), statementAST)
+		if err != nil {
+			return nil, statements, branches, err
+		}
+		statementIdx++
+
+		if isBranch && (branch.Kind == BranchKindRequire || branch.Kind == BranchKindAssert) {
+			// reached only if the condition holds
+			marker := newBranchPassMarker(branch, eventDefinitionID)
+			blockAST, err = sjson.SetBytes(blockAST, fmt.Sprintf("statements.%d", statementIdx), marker)
 			if err != nil {
-				return nil, statements, err
+				return nil, statements, branches, err
 			}
 			statementIdx++
 		}
 	}
 
-	return blockAST, statements, nil
+	return blockAST, statements, branches, nil
 }
 
 func getBlocks(ast json.RawMessage) (pathsSortedByDepth []string, blocks map[string]json.RawMessage, err error) {
@@ -371,74 +512,6 @@ func getStatementSrcLocation(ast json.RawMessage) (start, end, file int, err err
 	return
 }
 
-func orchestrateRequireStatement(ast json.RawMessage) (out json.RawMessage, isRequire bool, err error) {
-	var statement map[string]interface{}
-	if err = json.Unmarshal(ast, &statement); err != nil {
-		err = errors.Wrap(err, "failed to unmarshal statement AST")
-		return
-	}
-
-	out = ast
-
-	expression, ok := statement["expression"].(map[string]interface{})
-	if !ok {
-		return
-	}
-
-	nodeType, ok := expression["nodeType"].(string)
-	if !ok || nodeType != "FunctionCall" {
-		return
-	}
-
-	subExpression := expression["expression"].(map[string]interface{})
-	if !ok {
-		return
-	}
-
-	if subExpressionName, ok := subExpression["name"].(string); !ok || subExpressionName != "require" {
-		return
-	}
-
-	isRequire = true
-
-	expressionArguments := expression["arguments"].([]interface{})
-	if !ok {
-		return
-	}
-
-	expressionArgument2, ok := expressionArguments[1].(map[string]interface{})
-	if !ok {
-		return
-	}
-
-	textValue, ok := expressionArgument2["value"].(string)
-	if !ok {
-		return
-	}
-
-	statementSrc, ok := statement["src"].(string)
-	if !ok {
-		return
-	}
-
-	start, end, file, err := srcToLocation(statementSrc)
-	if err != nil {
-		return
-	}
-
-	textValue = fmt.Sprintf("%s @coverage,%d,%d,%d", textValue, start, end, file)
-	textValueHash := crypto.Keccak256Hash([]byte(textValue)).Bytes()
-	ast, _ = sjson.SetBytes(ast, "expression.arguments.1.typeDescriptions.typeString", fmt.Sprintf("t_stringliteral_%x", textValueHash))
-	ast, _ = sjson.SetBytes(ast, "expression.arguments.1.typeDescriptions.typeIdentifier", fmt.Sprintf("literal_string \"%s\"", textValue))
-	ast, _ = sjson.SetBytes(ast, "expression.arguments.1.value", textValue)
-	ast, _ = sjson.SetBytes(ast, "expression.arguments.1.hexValue", fmt.Sprintf("%x", textValue))
-	ast, _ = sjson.SetBytes(ast, "expression.expression.argumentTypes.1.typeString", fmt.Sprintf("t_stringliteral_%x", textValueHash))
-	ast, _ = sjson.SetBytes(ast, "expression.expression.argumentTypes.1.typeIdentifier", fmt.Sprintf("literal_string \"%s\"", textValue))
-	out = ast
-
-	return
-}
-
 func getStateMutabilities(ast json.RawMessage) (map[string]string, error) {
 	var in interface{}
 	if err := json.Unmarshal(ast, &in); err != nil {
@@ -0,0 +1,137 @@
+package sol
+
+import (
+	"encoding/json"
+
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+)
+
+// Function is a function or modifier definition with a body, Kind follows the
+// solc AST: function, constructor, fallback, receive or modifier.
+type Function struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Contract string `json:"contract"`
+	Start    int    `json:"start"`
+	Length   int    `json:"length"`
+	File     int    `json:"file"`
+}
+
+const functionMarkerFlag = 1 << 48
+
+// EncodeFunctionMarkerFile flags the file argument of a coverage marker placed on function entry.
+func EncodeFunctionMarkerFile(file int) uint64 {
+	return uint64(file) | functionMarkerFlag
+}
+
+var (
+	astFunctionsKeys, _ = gojq.Parse(`.nodes[] | select(.nodeType? == "ContractDefinition") | .name as $contract |
+		.nodes[] | select((.nodeType == "FunctionDefinition" or .nodeType == "ModifierDefinition") and .body != null) |
+		{contract: $contract, name: .name, kind: (.kind // "modifier"), src: .src}`)
+	astFunctionsPaths, _ = gojq.Parse(`path(.nodes[] | select(.nodeType? == "ContractDefinition") |
+		.nodes[] | select((.nodeType == "FunctionDefinition" or .nodeType == "ModifierDefinition") and .body != null))`)
+)
+
+// getFunctions collects functions and modifiers of all contracts in the source unit,
+// keyed by path of their body blocks.
+func getFunctions(ast json.RawMessage) (bodyPaths map[string]Function, functions []Function, err error) {
+	var in interface{}
+	if err = json.Unmarshal(ast, &in); err != nil {
+		return nil, nil, err
+	}
+
+	var stage0 []interface{}
+	var stage1 []interface{}
+
+	iter := astFunctionsKeys.Run(in)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			err = errors.Wrap(err, "failed to parse JSON")
+			return nil, nil, err
+		}
+
+		stage0 = append(stage0, v)
+	}
+
+	iter = astFunctionsPaths.Run(in)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			err = errors.Wrap(err, "failed to parse JSON")
+			return nil, nil, err
+		}
+
+		stage1 = append(stage1, v)
+	}
+
+	bodyPaths = make(map[string]Function, len(stage0))
+	for i := range stage0 {
+		definition, ok := stage0[i].(map[string]interface{})
+		if !ok || i >= len(stage1) {
+			continue
+		}
+
+		src, _ := definition["src"].(string)
+		start, length, file, err := srcToLocation(src)
+		if err != nil {
+			err = errors.Wrap(err, "failed to parse function src reference")
+			return nil, nil, err
+		} else if start < 0 {
+			continue
+		}
+
+		fn := Function{
+			Start:  start,
+			Length: length,
+			File:   file,
+		}
+		fn.Contract, _ = definition["contract"].(string)
+		fn.Kind, _ = definition["kind"].(string)
+		fn.Name, _ = definition["name"].(string)
+		if len(fn.Name) == 0 {
+			// constructor, fallback and receive are unnamed
+			fn.Name = fn.Kind
+		}
+
+		functions = append(functions, fn)
+		bodyPaths[joinPath(stage1[i])+".body"] = fn
+	}
+
+	return bodyPaths, functions, nil
+}
+
+// prependFunctionMarker puts the function entry marker before all statements of the body block.
+func prependFunctionMarker(blockAST json.RawMessage, fn Function, eventDefinitionID uint64) (json.RawMessage, error) {
+	var block map[string]interface{}
+	if err := json.Unmarshal(blockAST, &block); err != nil {
+		return nil, err
+	}
+
+	markerAST := newCoverageMarker(
+		eventDefinitionID,
+		uint64(fn.Start),
+		uint64(fn.Length),
+		EncodeFunctionMarkerFile(fn.File),
+	)
+
+	var marker interface{}
+	if err := json.Unmarshal(markerAST, &marker); err != nil {
+		err = errors.Wrap(err, "failed to unmarshal function marker")
+		return nil, err
+	}
+
+	statements, _ := block["statements"].([]interface{})
+	block["statements"] = append([]interface{}{marker}, statements...)
+
+	return json.Marshal(block)
+}
@@ -0,0 +1,455 @@
+package sol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// CoverageRevertErrorName is the name of the custom error that wraps reverts of orchestrated
+// statements. It carries the marker location and the original revert data.
+func CoverageRevertErrorName(eventDefinitionID uint64) string {
+	return fmt.Sprintf("___coverage_revert_%d", eventDefinitionID)
+}
+
+// orchestrateRevertStatement rewrites statements that may revert, so the revert carries the coverage
+// error with the statement location instead of the original data, which is wrapped into it:
+//
+//	require(cond)               -> if (!cond) revert ___coverage_revert(start, end, file, "")
+//	require(cond, "msg")        -> if (!cond) revert ___coverage_revert(..., abi.encodeWithSignature("Error(string)", "msg"))
+//	require(cond, Err(args))    -> if (!cond) revert ___coverage_revert(..., abi.encodeWithSelector(Err.selector, args))
+//	revert("msg")               -> revert ___coverage_revert(..., abi.encodeWithSignature("Error(string)", "msg"))
+//	revert Err(args)            -> revert ___coverage_revert(..., abi.encodeWithSelector(Err.selector, args))
+//
+// Other statements are returned as is, with isReverting unset.
+func orchestrateRevertStatement(
+	statement map[string]interface{},
+	eventDefinitionID uint64,
+	start, end, file uint64,
+) (out map[string]interface{}, isReverting bool, err error) {
+	var condition map[string]interface{}
+	var revertData string
+
+	switch statement["nodeType"] {
+	case "RevertStatement":
+		errorCall, _ := statement["errorCall"].(map[string]interface{})
+		if revertData, isReverting = encodeErrorCall(errorCall); !isReverting {
+			return statement, false, nil
+		}
+	case "ExpressionStatement":
+		expression, _ := statement["expression"].(map[string]interface{})
+		if expression == nil || expression["nodeType"] != "FunctionCall" {
+			return statement, false, nil
+		}
+
+		callee, _ := expression["expression"].(map[string]interface{})
+		if callee == nil || callee["nodeType"] != "Identifier" {
+			return statement, false, nil
+		}
+
+		arguments, _ := expression["arguments"].([]interface{})
+
+		switch callee["name"] {
+		case "require":
+			if len(arguments) == 0 {
+				return statement, false, nil
+			}
+
+			condition, _ = arguments[0].(map[string]interface{})
+			arguments = arguments[1:]
+		case "revert":
+		default:
+			return statement, false, nil
+		}
+
+		if revertData, isReverting = encodeRevertReason(arguments); !isReverting {
+			return statement, false, nil
+		}
+	default:
+		return statement, false, nil
+	}
+
+	var revertAST json.RawMessage
+	if condition == nil {
+		revertAST = newCoverageRevert(eventDefinitionID, start, end, file, revertData, statement["src"])
+	} else {
+		conditionAST, err := json.Marshal(condition)
+		if err != nil {
+			err = errors.Wrap(err, "failed to marshal require condition")
+			return nil, false, err
+		}
+
+		revertAST = newNodeFromTemplate(coverageRequireTemplate, coverageRequireArgs{
+			Src:       statement["src"],
+			Condition: string(conditionAST),
+			Revert:    string(newCoverageRevert(eventDefinitionID, start, end, file, revertData, "-1:-1:-1")),
+		})
+	}
+
+	if err := json.Unmarshal(revertAST, &out); err != nil {
+		err = errors.Wrap(err, "failed to unmarshal coverage revert")
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
+
+// encodeRevertReason packs optional reason string of require and revert calls, the same way as solc does.
+func encodeRevertReason(arguments []interface{}) (data string, ok bool) {
+	switch len(arguments) {
+	case 0:
+		return string(newStringLiteral("")), true
+	case 1:
+		reason, _ := arguments[0].(map[string]interface{})
+		if reason == nil {
+			return "", false
+		}
+
+		if isErrorCall(reason) {
+			return encodeErrorCall(reason)
+		}
+
+		reasonAST, err := json.Marshal(reason)
+		if err != nil {
+			return "", false
+		}
+
+		return string(newNodeFromTemplate(abiEncodeTemplate, abiEncodeArgs{
+			Method:    "encodeWithSignature",
+			Arguments: []string{string(newStringLiteral("Error(string)")), string(reasonAST)},
+		})), true
+	}
+
+	return "", false
+}
+
+// isErrorCall checks that the call expression constructs a custom error, e.g. require(cond, Err()).
+func isErrorCall(call map[string]interface{}) bool {
+	if call["nodeType"] != "FunctionCall" {
+		return false
+	}
+
+	callee, _ := call["expression"].(map[string]interface{})
+	if callee == nil {
+		return false
+	}
+
+	typeDescriptions, _ := callee["typeDescriptions"].(map[string]interface{})
+	typeIdentifier, _ := typeDescriptions["typeIdentifier"].(string)
+
+	return strings.HasPrefix(typeIdentifier, "t_function_error")
+}
+
+// encodeErrorCall turns custom error construction into abi.encodeWithSelector call. Named arguments
+// are not supported, as their order may differ from the error definition.
+func encodeErrorCall(errorCall map[string]interface{}) (data string, ok bool) {
+	if errorCall == nil || errorCall["nodeType"] != "FunctionCall" {
+		return "", false
+	} else if names, _ := errorCall["names"].([]interface{}); len(names) > 0 {
+		return "", false
+	}
+
+	callee, _ := errorCall["expression"].(map[string]interface{})
+	if callee == nil {
+		return "", false
+	}
+
+	calleeAST, err := json.Marshal(callee)
+	if err != nil {
+		return "", false
+	}
+
+	encodeArgs := abiEncodeArgs{
+		Method: "encodeWithSelector",
+		Arguments: []string{
+			string(newNodeFromTemplate(selectorAccessTemplate, selectorAccessArgs{
+				Expression: string(calleeAST),
+			})),
+		},
+	}
+
+	arguments, _ := errorCall["arguments"].([]interface{})
+	for _, argument := range arguments {
+		argumentAST, err := json.Marshal(argument)
+		if err != nil {
+			return "", false
+		}
+
+		encodeArgs.Arguments = append(encodeArgs.Arguments, string(argumentAST))
+	}
+
+	return string(newNodeFromTemplate(abiEncodeTemplate, encodeArgs)), true
+}
+
+type CoverageRevertArgs struct {
+	EventDefinitionID uint64
+	Src               interface{}
+	Start, End, File  uint64
+	Data              string
+}
+
+func newCoverageRevert(eventDefinitionID uint64, start, end, file uint64, data string, src interface{}) json.RawMessage {
+	return newNodeFromTemplate(coverageRevertTemplate, CoverageRevertArgs{
+		EventDefinitionID: eventDefinitionID,
+		Src:               src,
+		Start:             start,
+		End:               end,
+		File:              file,
+		Data:              data,
+	})
+}
+
+type errorDefinitionArgs struct {
+	EventDefinitionID uint64
+	Parameters        []errorParameter
+}
+
+type errorParameter struct {
+	Name, Type string
+}
+
+// newErrorDefinition declares the coverage error along with the coverage event of the contract.
+func newErrorDefinition(eventDefinitionID uint64) json.RawMessage {
+	return newNodeFromTemplate(errorDefinitionTemplate, errorDefinitionArgs{
+		EventDefinitionID: eventDefinitionID,
+		Parameters: []errorParameter{
+			{Name: "start", Type: "uint64"},
+			{Name: "end", Type: "uint64"},
+			{Name: "file", Type: "uint64"},
+			{Name: "data", Type: "bytes"},
+		},
+	})
+}
+
+func newStringLiteral(value string) json.RawMessage {
+	return newNodeFromTemplate(stringLiteralTemplate, stringLiteralArgs{
+		Value:    value,
+		HexValue: fmt.Sprintf("%x", value),
+	})
+}
+
+type coverageRequireArgs struct {
+	Src       interface{}
+	Condition string
+	Revert    string
+}
+
+type abiEncodeArgs struct {
+	Method    string
+	Arguments []string
+}
+
+type selectorAccessArgs struct {
+	Expression string
+}
+
+type stringLiteralArgs struct {
+	Value    string
+	HexValue string
+}
+
+// newNodeFromTemplate renders AST node, every {{id}} call gets a new random node ID.
+func newNodeFromTemplate(tpl *template.Template, args interface{}) json.RawMessage {
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, args); err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+var astTemplateFuncs = template.FuncMap{
+	"id":    randN,
+	"hex":   uint64ToAstHex,
+	"json":  jsonValue,
+	"comma": func(idx int) bool { return idx > 0 },
+}
+
+func jsonValue(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+var coverageRequireTemplate = template.Must(template.New("coverageRequire").Funcs(astTemplateFuncs).Parse(`{
+  "condition": {
+    "id": {{id}},
+    "isConstant": false,
+    "isLValue": false,
+    "isPure": false,
+    "lValueRequested": false,
+    "nodeType": "UnaryOperation",
+    "operator": "!",
+    "prefix": true,
+    "src": "-1:-1:-1",
+    "subExpression": {{.Condition}}
+  },
+  "falseBody": null,
+  "id": {{id}},
+  "nodeType": "IfStatement",
+  "src": {{json .Src}},
+  "trueBody": {{.Revert}}
+}`))
+
+var coverageRevertTemplate = template.Must(template.New("coverageRevert").Funcs(astTemplateFuncs).Parse(`{
+  "errorCall": {
+    "arguments": [
+      {
+        "hexValue": "{{hex .Start}}",
+        "id": {{id}},
+        "isConstant": false,
+        "isLValue": false,
+        "isPure": true,
+        "kind": "number",
+        "lValueRequested": false,
+        "nodeType": "Literal",
+        "src": "-1:-1:-1",
+        "value": "{{.Start}}"
+      },
+      {
+        "hexValue": "{{hex .End}}",
+        "id": {{id}},
+        "isConstant": false,
+        "isLValue": false,
+        "isPure": true,
+        "kind": "number",
+        "lValueRequested": false,
+        "nodeType": "Literal",
+        "src": "-1:-1:-1",
+        "value": "{{.End}}"
+      },
+      {
+        "hexValue": "{{hex .File}}",
+        "id": {{id}},
+        "isConstant": false,
+        "isLValue": false,
+        "isPure": true,
+        "kind": "number",
+        "lValueRequested": false,
+        "nodeType": "Literal",
+        "src": "-1:-1:-1",
+        "value": "{{.File}}"
+      },
+      {{.Data}}
+    ],
+    "expression": {
+      "id": {{id}},
+      "name": "___coverage_revert_{{.EventDefinitionID}}",
+      "nodeType": "Identifier",
+      "overloadedDeclarations": [],
+      "src": "-1:-1:-1"
+    },
+    "id": {{id}},
+    "isConstant": false,
+    "isLValue": false,
+    "isPure": false,
+    "kind": "functionCall",
+    "lValueRequested": false,
+    "nameLocations": [],
+    "names": [],
+    "nodeType": "FunctionCall",
+    "src": "-1:-1:-1",
+    "tryCall": false
+  },
+  "id": {{id}},
+  "nodeType": "RevertStatement",
+  "src": {{json .Src}}
+}`))
+
+var abiEncodeTemplate = template.Must(template.New("abiEncode").Funcs(astTemplateFuncs).Parse(`{
+  "arguments": [
+    {{range $idx, $arg := .Arguments}}{{if comma $idx}},{{end}}{{$arg}}{{end}}
+  ],
+  "expression": {
+    "expression": {
+      "id": {{id}},
+      "name": "abi",
+      "nodeType": "Identifier",
+      "overloadedDeclarations": [],
+      "src": "-1:-1:-1"
+    },
+    "id": {{id}},
+    "isConstant": false,
+    "isLValue": false,
+    "isPure": true,
+    "lValueRequested": false,
+    "memberLocation": "-1:-1:-1",
+    "memberName": "{{.Method}}",
+    "nodeType": "MemberAccess",
+    "src": "-1:-1:-1"
+  },
+  "id": {{id}},
+  "isConstant": false,
+  "isLValue": false,
+  "isPure": false,
+  "kind": "functionCall",
+  "lValueRequested": false,
+  "nameLocations": [],
+  "names": [],
+  "nodeType": "FunctionCall",
+  "src": "-1:-1:-1",
+  "tryCall": false
+}`))
+
+var selectorAccessTemplate = template.Must(template.New("selectorAccess").Funcs(astTemplateFuncs).Parse(`{
+  "expression": {{.Expression}},
+  "id": {{id}},
+  "isConstant": false,
+  "isLValue": false,
+  "isPure": true,
+  "lValueRequested": false,
+  "memberLocation": "-1:-1:-1",
+  "memberName": "selector",
+  "nodeType": "MemberAccess",
+  "src": "-1:-1:-1"
+}`))
+
+var stringLiteralTemplate = template.Must(template.New("stringLiteral").Funcs(astTemplateFuncs).Parse(`{
+  "hexValue": "{{.HexValue}}",
+  "id": {{id}},
+  "isConstant": false,
+  "isLValue": false,
+  "isPure": true,
+  "kind": "string",
+  "lValueRequested": false,
+  "nodeType": "Literal",
+  "src": "-1:-1:-1",
+  "value": {{json .Value}}
+}`))
+
+var errorDefinitionTemplate = template.Must(template.New("errorDefinition").Funcs(astTemplateFuncs).Parse(`{
+  "id": {{id}},
+  "name": "___coverage_revert_{{.EventDefinitionID}}",
+  "nameLocation": "-1:-1:-1",
+  "nodeType": "ErrorDefinition",
+  "parameters": {
+    "id": {{id}},
+    "nodeType": "ParameterList",
+    "parameters": [{{range $idx, $param := .Parameters}}{{if comma $idx}},{{end}}
+      {
+        "constant": false,
+        "id": {{id}},
+        "indexed": false,
+        "mutability": "mutable",
+        "name": "{{$param.Name}}",
+        "nameLocation": "-1:-1:-1",
+        "nodeType": "VariableDeclaration",
+        "src": "-1:-1:-1",
+        "stateVariable": false,
+        "storageLocation": "default",
+        "typeName": {
+          "id": {{id}},
+          "name": "{{$param.Type}}",
+          "nodeType": "ElementaryTypeName",
+          "src": "-1:-1:-1"
+        },
+        "visibility": "internal"
+      }{{end}}
+    ],
+    "src": "-1:-1:-1"
+  },
+  "src": "-1:-1:-1"
+}`))
@@ -0,0 +1,94 @@
+package sol
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SourceMapEntry is a decompressed source map item of a single instruction,
+// see https://docs.soliditylang.org/en/latest/internals/source_mappings.html
+type SourceMapEntry struct {
+	Start         int
+	Length        int
+	File          int
+	Jump          byte
+	ModifierDepth int
+}
+
+// ParseSourceMap decompresses solc source map, returning one entry per instruction.
+func ParseSourceMap(srcMap string) ([]SourceMapEntry, error) {
+	if len(srcMap) == 0 {
+		return nil, nil
+	}
+
+	items := strings.Split(srcMap, ";")
+	entries := make([]SourceMapEntry, len(items))
+
+	var prev SourceMapEntry
+	for idx, item := range items {
+		entry := prev
+		fields := strings.Split(item, ":")
+
+		for fieldIdx, field := range fields {
+			if len(field) == 0 {
+				continue
+			}
+
+			if fieldIdx == 3 {
+				entry.Jump = field[0]
+				continue
+			} else if fieldIdx > 4 {
+				break
+			}
+
+			value, err := strconv.Atoi(field)
+			if err != nil {
+				err = errors.Wrapf(err, "wrong source map item %d: %s", idx, item)
+				return nil, err
+			}
+
+			switch fieldIdx {
+			case 0:
+				entry.Start = value
+			case 1:
+				entry.Length = value
+			case 2:
+				entry.File = value
+			case 4:
+				entry.ModifierDepth = value
+			}
+		}
+
+		entries[idx] = entry
+		prev = entry
+	}
+
+	return entries, nil
+}
+
+// InstructionIndexes maps every program counter of the bytecode onto index of the instruction,
+// as used by source maps. Positions of PUSH data are mapped to the PUSH instruction itself.
+func InstructionIndexes(code []byte) []int {
+	indexes := make([]int, len(code))
+
+	instruction := 0
+	for pc := 0; pc < len(code); instruction++ {
+		op := code[pc]
+		size := 1
+
+		// PUSH1..PUSH32
+		if op >= 0x60 && op <= 0x7f {
+			size += int(op-0x60) + 1
+		}
+
+		for i := pc; i < pc+size && i < len(code); i++ {
+			indexes[i] = instruction
+		}
+
+		pc += size
+	}
+
+	return indexes
+}
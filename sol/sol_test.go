@@ -1,6 +1,8 @@
 package sol
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -73,6 +75,273 @@ contract Greeter  {
 	assert.Equal(contracts["Greeter"].SourcePath, "test.sol")
 }
 
+func TestParseSourceMap(t *testing.T) {
+	assert := assert.New(t)
+
+	entries, err := ParseSourceMap("1:2:1;:9;2:1:2;;:::o;4::0:i:1")
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal([]SourceMapEntry{
+		{Start: 1, Length: 2, File: 1},
+		{Start: 1, Length: 9, File: 1},
+		{Start: 2, Length: 1, File: 2},
+		{Start: 2, Length: 1, File: 2},
+		{Start: 2, Length: 1, File: 2, Jump: 'o'},
+		{Start: 4, Length: 1, File: 0, Jump: 'i', ModifierDepth: 1},
+	}, entries)
+
+	// generated code has no file, fields are still inherited by following items
+	entries, err = ParseSourceMap("10:5:0:-;::-1;20;:3:::2")
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal([]SourceMapEntry{
+		{Start: 10, Length: 5, File: 0, Jump: '-'},
+		{Start: 10, Length: 5, File: -1, Jump: '-'},
+		{Start: 20, Length: 5, File: -1, Jump: '-'},
+		{Start: 20, Length: 3, File: -1, Jump: '-', ModifierDepth: 2},
+	}, entries)
+
+	_, err = ParseSourceMap("1:2:x")
+	assert.Error(err)
+}
+
+func TestInstructionIndexes(t *testing.T) {
+	assert := assert.New(t)
+
+	// PUSH1 0x80 PUSH1 0x40 MSTORE PUSH2 0x0102 STOP
+	code := []byte{0x60, 0x80, 0x60, 0x40, 0x52, 0x61, 0x01, 0x02, 0x00}
+	assert.Equal([]int{0, 0, 1, 1, 2, 3, 3, 3, 4}, InstructionIndexes(code))
+}
+
+func TestBranchMarkerFile(t *testing.T) {
+	assert := assert.New(t)
+
+	type marker struct {
+		file, arm int
+		function  bool
+	}
+	decode := func(value uint64) marker {
+		file, arm, function := DecodeMarkerFile(value)
+		return marker{file, arm, function}
+	}
+
+	assert.Equal(marker{3, -1, false}, decode(3))
+	assert.Equal(marker{3, 0, false}, decode(EncodeBranchMarkerFile(3, 0)))
+	assert.Equal(marker{3, 1, false}, decode(EncodeBranchMarkerFile(3, 1)))
+	assert.Equal(marker{0, 1, false}, decode(EncodeBranchMarkerFile(0, 1)))
+	assert.Equal(marker{2, -1, true}, decode(EncodeFunctionMarkerFile(2)))
+}
+
+func TestOrchestrateIfStatement(t *testing.T) {
+	assert := assert.New(t)
+
+	// if (a) { x(); } else if (b) y();
+	var statement map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"nodeType": "IfStatement",
+		"src": "10:40:0",
+		"trueBody": {"nodeType": "Block", "src": "16:8:0", "statements": []},
+		"falseBody": {
+			"nodeType": "IfStatement",
+			"src": "30:20:1",
+			"trueBody": {"nodeType": "ExpressionStatement", "src": "40:4:1"}
+		}
+	}`), &statement)
+	if !assert.NoError(err) {
+		return
+	}
+
+	out, branches, err := orchestrateIfStatement(statement, 7)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal([]Branch{
+		{Kind: BranchKindIf, Start: 10, Length: 40, File: 0, Jump: [2]int{10, 40}},
+		{Kind: BranchKindIf, Start: 30, Length: 20, File: 1, Jump: [2]int{30, 20}},
+	}, branches)
+
+	armMarker := func(body interface{}, file, arm int) {
+		statements := body.(map[string]interface{})["statements"].([]interface{})
+		if !assert.NotEmpty(statements) {
+			return
+		}
+
+		marker, err := json.Marshal(statements[0])
+		if assert.NoError(err) {
+			assert.Contains(string(marker), fmt.Sprintf(`"value":"%d"`, EncodeBranchMarkerFile(file, arm)))
+		}
+	}
+
+	armMarker(out["trueBody"], 0, 0)
+
+	// the else-if is wrapped into a block with the false arm marker first
+	falseBody := out["falseBody"].(map[string]interface{})
+	armMarker(falseBody, 0, 1)
+
+	nested := falseBody["statements"].([]interface{})[1].(map[string]interface{})
+	assert.Equal("IfStatement", nested["nodeType"])
+	armMarker(nested["trueBody"], 1, 0)
+	armMarker(nested["falseBody"], 1, 1)
+
+	// a single statement arm keeps the statement after the marker
+	assert.Len(nested["trueBody"].(map[string]interface{})["statements"], 2)
+	assert.Equal("40:4:1", nested["trueBody"].(map[string]interface{})["src"])
+}
+
+func TestGetFunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	ast := json.RawMessage(`{
+		"nodeType": "SourceUnit",
+		"nodes": [
+			{"nodeType": "PragmaDirective"},
+			{
+				"nodeType": "ContractDefinition",
+				"name": "Greeter",
+				"nodes": [
+					{"nodeType": "VariableDeclaration", "src": "20:10:0"},
+					{"nodeType": "FunctionDefinition", "kind": "constructor", "name": "", "src": "40:30:0", "body": {"nodeType": "Block", "statements": []}},
+					{"nodeType": "ModifierDefinition", "name": "onlyOwner", "src": "80:20:0", "body": {"nodeType": "Block", "statements": []}},
+					{"nodeType": "FunctionDefinition", "kind": "function", "name": "greet", "src": "110:40:0", "body": null},
+					{"nodeType": "FunctionDefinition", "kind": "function", "name": "kill", "src": "160:50:0", "body": {"nodeType": "Block", "statements": []}}
+				]
+			}
+		]
+	}`)
+
+	bodyPaths, functions, err := getFunctions(ast)
+	if !assert.NoError(err) {
+		return
+	}
+
+	constructor := Function{Kind: "constructor", Name: "constructor", Contract: "Greeter", Start: 40, Length: 30, File: 0}
+	modifier := Function{Kind: "modifier", Name: "onlyOwner", Contract: "Greeter", Start: 80, Length: 20, File: 0}
+	kill := Function{Kind: "function", Name: "kill", Contract: "Greeter", Start: 160, Length: 50, File: 0}
+
+	// functions without body are skipped
+	assert.Equal([]Function{constructor, modifier, kill}, functions)
+	assert.Equal(map[string]Function{
+		"nodes.1.nodes.1.body": constructor,
+		"nodes.1.nodes.2.body": modifier,
+		"nodes.1.nodes.4.body": kill,
+	}, bodyPaths)
+
+	block, err := prependFunctionMarker(json.RawMessage(`{"nodeType": "Block", "statements": [{"nodeType": "Return"}]}`), kill, 7)
+	if !assert.NoError(err) {
+		return
+	}
+
+	var out map[string]interface{}
+	if !assert.NoError(json.Unmarshal(block, &out)) {
+		return
+	}
+
+	statements := out["statements"].([]interface{})
+	if assert.Len(statements, 2) {
+		marker, _ := json.Marshal(statements[0])
+		assert.Contains(string(marker), fmt.Sprintf(`"value":"%d"`, EncodeFunctionMarkerFile(0)))
+		assert.Equal("Return", statements[1].(map[string]interface{})["nodeType"])
+	}
+}
+
+func TestOrchestrateRevertStatement(t *testing.T) {
+	assert := assert.New(t)
+
+	condition := `{"nodeType": "Identifier", "name": "ok", "src": "8:2:0"}`
+	reason := `{"nodeType": "Literal", "kind": "string", "value": "nope", "src": "12:6:0"}`
+	errorCall := func(names string) string {
+		return `{
+			"nodeType": "FunctionCall",
+			"names": [` + names + `],
+			"expression": {"nodeType": "Identifier", "name": "Unauthorized", "typeDescriptions": {"typeIdentifier": "t_function_error_pure$__$returns$__$"}},
+			"arguments": [{"nodeType": "Literal", "kind": "number", "value": "1"}]
+		}`
+	}
+	requireCall := func(arguments ...string) string {
+		raw := make([]json.RawMessage, len(arguments))
+		for idx, arg := range arguments {
+			raw[idx] = json.RawMessage(arg)
+		}
+		args, _ := json.Marshal(raw)
+
+		return `{
+			"nodeType": "ExpressionStatement",
+			"src": "0:20:0",
+			"expression": {
+				"nodeType": "FunctionCall",
+				"expression": {"nodeType": "Identifier", "name": "require"},
+				"arguments": ` + string(args) + `
+			}
+		}`
+	}
+
+	testCases := []struct {
+		name      string
+		statement string
+		reverting bool
+		contains  []string
+	}{{
+		name:      "require",
+		statement: requireCall(condition),
+		reverting: true,
+		contains:  []string{`"IfStatement"`, `"operator":"!"`, `"name":"ok"`, `"___coverage_revert_7"`},
+	}, {
+		name:      "require with reason",
+		statement: requireCall(condition, reason),
+		reverting: true,
+		contains:  []string{`"IfStatement"`, `"memberName":"encodeWithSignature"`, `"value":"Error(string)"`, `"value":"nope"`},
+	}, {
+		name:      "require with custom error",
+		statement: requireCall(condition, errorCall("")),
+		reverting: true,
+		contains:  []string{`"IfStatement"`, `"memberName":"encodeWithSelector"`, `"memberName":"selector"`, `"name":"Unauthorized"`},
+	}, {
+		name:      "revert with custom error",
+		statement: `{"nodeType": "RevertStatement", "src": "0:20:0", "errorCall": ` + errorCall("") + `}`,
+		reverting: true,
+		contains:  []string{`"nodeType":"RevertStatement"`, `"___coverage_revert_7"`, `"memberName":"encodeWithSelector"`},
+	}, {
+		name:      "revert with named args",
+		statement: `{"nodeType": "RevertStatement", "src": "0:20:0", "errorCall": ` + errorCall(`"code"`) + `}`,
+	}, {
+		name:      "other call",
+		statement: `{"nodeType": "ExpressionStatement", "src": "0:20:0", "expression": {"nodeType": "FunctionCall", "expression": {"nodeType": "Identifier", "name": "transfer"}}}`,
+	}, {
+		name:      "return",
+		statement: `{"nodeType": "Return", "src": "0:20:0"}`,
+	}}
+
+	for _, tc := range testCases {
+		var statement map[string]interface{}
+		if !assert.NoError(json.Unmarshal([]byte(tc.statement), &statement), tc.name) {
+			continue
+		}
+
+		out, reverting, err := orchestrateRevertStatement(statement, 7, 10, 20, 0)
+		if !assert.NoError(err, tc.name) {
+			continue
+		}
+
+		assert.Equal(tc.reverting, reverting, tc.name)
+		if !tc.reverting {
+			assert.Equal(statement, out, tc.name)
+			continue
+		}
+
+		data, _ := json.Marshal(out)
+		assert.Contains(string(data), `"src":"0:20:0"`, tc.name)
+		assert.Contains(string(data), `"value":"10"`, tc.name)
+		for _, s := range tc.contains {
+			assert.Contains(string(data), s, tc.name)
+		}
+	}
+}
+
 func cleanup() {
 	os.Remove("test.sol")
 }
@@ -87,3 +356,138 @@ func orPanic(err error) {
 		panic(err)
 	}
 }
+
+func TestConditionalBranches(t *testing.T) {
+	assert := assert.New(t)
+
+	// contract C { function f(bool a) public pure returns (uint) { return a ? 1 : 2; } }
+	ast := json.RawMessage(`{
+		"nodeType": "SourceUnit",
+		"nodes": [{
+			"nodeType": "ContractDefinition",
+			"contractKind": "contract",
+			"name": "C",
+			"src": "0:90:0",
+			"nodes": [{
+				"nodeType": "FunctionDefinition",
+				"kind": "function",
+				"name": "f",
+				"src": "15:70:0",
+				"stateMutability": "pure",
+				"body": {
+					"nodeType": "Block",
+					"src": "55:30:0",
+					"statements": [{
+						"nodeType": "Return",
+						"src": "57:20:0",
+						"expression": {
+							"nodeType": "Conditional",
+							"src": "64:12:0",
+							"condition": {"nodeType": "Identifier", "name": "a", "src": "64:1:0"},
+							"trueExpression": {"nodeType": "Literal", "value": "1", "src": "68:1:0"},
+							"falseExpression": {"nodeType": "Literal", "value": "2", "src": "72:1:0"}
+						}
+					}]
+				}
+			}]
+		}]
+	}`)
+
+	conditional := Branch{Kind: BranchKindConditional, Start: 64, Length: 12, File: 0, Jump: [2]int{64, 12}}
+
+	branches, err := collectConditionalBranches(ast)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal([]Branch{conditional}, branches)
+
+	// ternaries are reported with both strategies, while instrumented code has no markers for them
+	out, coverage, err := addCoverageMarkers(ast)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal([]Branch{conditional}, coverage.Branches)
+	assert.Equal(1, countConditionals(coverage.Branches))
+	assert.NotContains(string(out), fmt.Sprintf(`"value":"%d"`, EncodeBranchMarkerFile(0, 0)))
+}
+
+func TestAddCoverageMarkersContracts(t *testing.T) {
+	assert := assert.New(t)
+
+	// interface I {} contract Base { function a() {} } contract Token is Base { function b() {} }
+	functionNode := func(name, src, statementSrc string) string {
+		return `{
+			"nodeType": "FunctionDefinition",
+			"kind": "function",
+			"name": "` + name + `",
+			"src": "` + src + `",
+			"body": {
+				"nodeType": "Block",
+				"src": "` + src + `",
+				"statements": [{"nodeType": "ExpressionStatement", "src": "` + statementSrc + `"}]
+			}
+		}`
+	}
+
+	ast := json.RawMessage(`{
+		"nodeType": "SourceUnit",
+		"nodes": [
+			{"nodeType": "ContractDefinition", "contractKind": "interface", "name": "I", "src": "0:10:1", "nodes": []},
+			{"nodeType": "ContractDefinition", "contractKind": "contract", "name": "Base", "src": "20:40:1", "nodes": [` +
+		functionNode("a", "30:20:1", "40:5:1") + `]},
+			{"nodeType": "ContractDefinition", "contractKind": "contract", "name": "Token", "src": "70:40:1", "nodes": [` +
+		functionNode("b", "80:20:1", "90:5:1") + `]}
+		]
+	}`)
+
+	out, coverage, err := addCoverageMarkers(ast)
+	if !assert.NoError(err) {
+		return
+	}
+
+	// interfaces get no coverage event
+	if !assert.Len(coverage.EventIDs, 2) {
+		return
+	}
+	assert.NotEqual(coverage.EventIDs["Base"], coverage.EventIDs["Token"])
+
+	// statement locations keep the file index of the src reference
+	assert.Equal([][]int{{40, 5, 1}, {90, 5, 1}}, coverage.Statements)
+
+	var unit struct {
+		Nodes []map[string]interface{} `json:"nodes"`
+	}
+	if !assert.NoError(json.Unmarshal(out, &unit)) || !assert.Len(unit.Nodes, 3) {
+		return
+	}
+
+	assert.Empty(unit.Nodes[0]["nodes"])
+
+	for _, contract := range unit.Nodes[1:] {
+		name := contract["name"].(string)
+		eventID := coverage.EventIDs[name]
+
+		var names []string
+		for _, node := range contract["nodes"].([]interface{}) {
+			if nodeName, ok := node.(map[string]interface{})["name"].(string); ok {
+				names = append(names, nodeName)
+			}
+		}
+
+		assert.Contains(names, fmt.Sprintf("___coverage_%d", eventID), name)
+		assert.Contains(names, CoverageRevertErrorName(eventID), name)
+		assert.Contains(names, "___coverage_id_"+name, name)
+
+		// markers of the contract emit its own event only
+		body, _ := json.Marshal(contract["nodes"].([]interface{})[0])
+		for other, otherID := range coverage.EventIDs {
+			reference := fmt.Sprintf(`"referencedDeclaration":%d`, otherID)
+			if other == name {
+				assert.Contains(string(body), reference, name)
+			} else {
+				assert.NotContains(string(body), reference, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	cli "github.com/jawher/mow.cli"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/etherman/deployer"
+)
+
+func onTrace(cmd *cli.Cmd) {
+	txHash := cmd.StringArg("TX_HASH", "", "Transaction hash to trace.")
+	jsonOut := cmd.BoolOpt("json", false, "Print decoded call tree as JSON.")
+
+	cmd.Spec = "[--json] TX_HASH"
+
+	cmd.Action = func() {
+		d, err := deployer.New(
+			deployer.OptionRPCTimeout(duration(*rpcTimeout, defaultRPCTimeout)),
+			deployer.OptionCallTimeout(duration(*callTimeout, defaultCallTimeout)),
+
+			// only options applicable to trace
+			deployer.OptionEVMRPCEndpoint(*evmEndpoint),
+			deployer.OptionNoCache(*noCache),
+			deployer.OptionBuildCacheDir(*buildCacheDir),
+			deployer.OptionSolcAllowedPaths(*solAllowedPaths),
+		)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to init deployer")
+		}
+
+		root, err := d.Trace(
+			context.Background(),
+			deployer.ContractTraceOpts{
+				SolSource:    *solSource,
+				ContractName: *contractName,
+			},
+			common.HexToHash(*txHash),
+		)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if *jsonOut {
+			v, _ := json.MarshalIndent(root, "", "\t")
+			fmt.Println(string(v))
+			return
+		}
+
+		printCallFrame(root, 0)
+	}
+}
+
+func printCallFrame(frame *deployer.CallFrame, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	target := frame.To.Hex()
+	if len(frame.Contract) > 0 {
+		target = fmt.Sprintf("%s(%s)", frame.Contract, frame.To.Hex())
+	}
+
+	call := fmt.Sprintf("%x", []byte(frame.Input))
+	if len(frame.Method) > 0 {
+		call = fmt.Sprintf("%s(%s)", frame.Method, formatNamedValues(frame.InputAbi, frame.Args))
+	} else if len(call) > 10 {
+		call = "0x" + call[:8] + "…"
+	} else if len(call) > 0 {
+		call = "0x" + call
+	}
+
+	line := fmt.Sprintf("%s[%d] %s %s.%s", indent, frame.GasUsed, frame.Type, target, call)
+	if frame.Value != nil && frame.Value.Sign() > 0 {
+		line += fmt.Sprintf(" {value: %s}", frame.Value)
+	}
+	fmt.Println(line)
+
+	for _, child := range frame.Calls {
+		printCallFrame(child, depth+1)
+	}
+
+	switch {
+	case len(frame.Error) > 0 && len(frame.RevertReason) > 0:
+		fmt.Printf("%s  ✗ %s\n", indent, frame.RevertReason)
+	case len(frame.Error) > 0:
+		fmt.Printf("%s  ✗ %s\n", indent, frame.Error)
+	case len(frame.Outputs) > 0:
+		fmt.Printf("%s  ← %s\n", indent, formatNamedValues(frame.OutputAbi, frame.Outputs))
+	case len(frame.Output) > 0 && len(frame.Method) == 0:
+		fmt.Printf("%s  ← 0x%x\n", indent, []byte(frame.Output))
+	}
+}
+
+// formatNamedValues prints decoded values in ABI order of the arguments.
+func formatNamedValues(args abi.Arguments, values map[string]interface{}) string {
+	names := deployer.ArgumentNames(args)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		if value, ok := values[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%v", name, value))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
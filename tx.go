@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"os"
 
 	"github.com/InjectiveLabs/etherman/deployer"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -20,8 +22,10 @@ func onTx(cmd *cli.Cmd) {
 	methodArgs := cmd.StringsArg("ARGS", []string{}, "Method transaction arguments. Will be ABI-encoded.")
 	valueArg := cmd.StringOpt("value", "0", "Value to be sent along with the transaction")
 	await := cmd.BoolOpt("await", true, "Await transaction confirmation from the RPC.")
+	dryRun := cmd.BoolOpt("dry-run", false, "Simulate transaction at pending state and print the result. Nothing is signed or sent.")
+	repeat := cmd.IntOpt("repeat", 1, "Send the transaction this many times, from --pool or --pool-size accounts in turns.")
 
-	cmd.Spec = "[--bytecode | --await | --value] ADDRESS METHOD [ARGS...]"
+	cmd.Spec = "[--bytecode | --await | --dry-run] [--value] [--repeat] ADDRESS METHOD [ARGS...]"
 
 	cmd.Action = func() {
 		d, err := deployer.New(
@@ -32,16 +36,59 @@ func onTx(cmd *cli.Cmd) {
 			// only options applicable to tx
 			deployer.OptionEVMRPCEndpoint(*evmEndpoint),
 			deployer.OptionGasPrice(big.NewInt(int64(*gasPrice))),
-			deployer.OptionGasLimit(uint64(*gasLimit)),
+			deployer.OptionGasLimit(gasLimitValue(*gasLimit)),
+			deployer.OptionGasEstimateAdjustment(*gasMultiplier, gasBufferValue(*gasBuffer)),
 			deployer.OptionNoCache(*noCache),
 			deployer.OptionBuildCacheDir(*buildCacheDir),
 			deployer.OptionSolcAllowedPaths(*solAllowedPaths),
 			deployer.OptionEnableCoverage(*coverage),
+			deployer.OptionCoverageStrategy(deployer.CoverageStrategy(*coverStrategy)),
 		)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to init deployer")
 		}
 
+		value, ok := new(big.Int).SetString(*valueArg, 10)
+		if !ok {
+			log.Fatalln("failed to parse value flag")
+		}
+
+		methodInputMapper := func(args abi.Arguments) []interface{} {
+			mappedArgs, err := mapStringArgs(args, *methodArgs)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to map method args")
+				return nil
+			}
+
+			return mappedArgs
+		}
+
+		if *dryRun {
+			fromAddress, err := resolveFromAddress(signerOpts)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to get from address")
+			}
+
+			result, err := d.SimulateTx(
+				context.Background(),
+				deployer.ContractTxOpts{
+					From:         fromAddress,
+					SolSource:    *solSource,
+					ContractName: *contractName,
+					Contract:     common.HexToAddress(*contractAddress),
+					Value:        value,
+				},
+				*methodName,
+				methodInputMapper,
+			)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			printSimulationResult(result)
+			return
+		}
+
 		client, err := d.Backend()
 		if err != nil {
 			log.Fatalln(err)
@@ -55,25 +102,13 @@ func onTx(cmd *cli.Cmd) {
 			log.WithError(err).Fatalln("failed get valid chain ID")
 		}
 
-		fromAddress, signerFn, err := initEthereumAccountsManager(
-			chainID.Uint64(),
-			keystoreDir,
-			from,
-			fromPassphrase,
-			fromPrivKey,
-			useLedger,
-		)
+		fromAddress, signerFn, err := initEthereumAccountsManager(chainID.Uint64(), signerOpts)
 		if err != nil {
 			log.WithError(err).Fatalln("failed init SignerFn")
 		}
 
 		log.Debugln("sending from", fromAddress.Hex())
 
-		value, ok := new(big.Int).SetString(*valueArg, 10)
-		if !ok {
-			log.WithError(err).Fatalln("failed to parse value flag")
-		}
-
 		txOpts := deployer.ContractTxOpts{
 			From:         fromAddress,
 			SignerFn:     signerFn,
@@ -88,6 +123,19 @@ func onTx(cmd *cli.Cmd) {
 			txOpts.CoverageAgent = deployer.NewCoverageDataCollector(deployer.CoverageModeDefault)
 		}
 
+		if !*bytecodeOnly && (*repeat > 1 || hasSignerPool()) {
+			pool, err := initSignerPool(client, chainID.Uint64(), fromAddress, signerFn)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to init signer pool")
+			}
+
+			// coverage is not collected from concurrent transactions
+			txOpts.CoverageAgent = nil
+
+			sendFromPool(d, pool, txOpts, *methodName, methodInputMapper, *repeat)
+			return
+		}
+
 		log.Debugln("sending from", fromAddress.Hex())
 		log.Debugln("target contract", txOpts.Contract.Hex())
 
@@ -95,15 +143,7 @@ func onTx(cmd *cli.Cmd) {
 			context.Background(),
 			txOpts,
 			*methodName,
-			func(args abi.Arguments) []interface{} {
-				mappedArgs, err := mapStringArgs(args, *methodArgs)
-				if err != nil {
-					log.WithError(err).Fatalln("failed to map method args")
-					return nil
-				}
-
-				return mappedArgs
-			},
+			methodInputMapper,
 		)
 		if err != nil {
 			log.Fatalln(err)
@@ -119,5 +159,18 @@ func onTx(cmd *cli.Cmd) {
 		}
 
 		fmt.Println(txHash.Hex())
+
+		if txOpts.CoverageAgent != nil {
+			reportCoverage(txOpts.CoverageAgent, *contractName)
+		}
+	}
+}
+
+func printSimulationResult(result *deployer.SimulationResult) {
+	v, _ := json.MarshalIndent(result, "", "\t")
+	fmt.Println(string(v))
+
+	if result.Reverted {
+		os.Exit(1)
 	}
 }